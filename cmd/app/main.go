@@ -3,19 +3,26 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/fathersson/wb-demo-service/internal/auth"
 	"github.com/fathersson/wb-demo-service/internal/cache"
 	"github.com/fathersson/wb-demo-service/internal/config"
 	"github.com/fathersson/wb-demo-service/internal/db"
 	"github.com/fathersson/wb-demo-service/internal/kafka"
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/masking"
+	"github.com/fathersson/wb-demo-service/internal/metrics"
+	"github.com/fathersson/wb-demo-service/internal/ratelimit"
 	"github.com/fathersson/wb-demo-service/internal/repository"
 	"github.com/fathersson/wb-demo-service/internal/server"
+	"github.com/fathersson/wb-demo-service/internal/tracing"
 )
 
 func main() {
@@ -27,65 +34,215 @@ func main() {
 	// Загрузка конфигурации (.env/окружение), без неё приложение не стартует
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal("Ошибка загрузки конфигурации:", err)
+		fatal("Ошибка загрузки конфигурации", err)
+	}
+
+	// Структурированные логи в stdout с уровнем и форматом из конфига, персональные данные маскируются
+	logger.Setup(cfg.Log)
+
+	// Трейсинг: traceparent идёт из Kafka через обработку и SQL, спаны уходят в OTLP или файл
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		fatal("Не удалось настроить трейсинг", err)
 	}
 
 	// Подключение к PostgreSQL: открываем соединение, проверяем Ping, закрываем при выходе
 	database, err := db.Connect(&cfg.Database)
 	if err != nil {
-		log.Fatal("Не удалось подключиться к базе:", err)
+		fatal("Не удалось подключиться к базе", err)
 	}
 	defer database.Close()
-	log.Println("Соединение с базой данных установлено")
+	slog.Info("Соединение с базой данных установлено")
+
+	// Таблицы из internal/db/schema.sql: на старой базе создаются недостающие (outbox, processed_events), иначе каждое сохранение падало бы
+	if err := db.Migrate(ctx, database); err != nil {
+		fatal("Не удалось подготовить схему БД", err)
+	}
 
 	// Репозиторий поверх *sql.DB
 	postgres := repository.NewPostgresRepo(database)
 
-	// Инициализация (загрузка) in-memory кэша из БД при старте
-	orderCache, err := cache.NewCacheFromDB(postgres)
-	if err != nil {
-		log.Fatal("Ошибка загрузки кэша:", err)
-	}
+	// In-memory кэш, загружается из БД после старта HTTP, чтобы /healthz отвечал уже во время прогрева
+	orderCache := cache.NewCache()
 
 	// Kafka producer: в отдельной горутине генерирует валидные/битые сообщения до остановки контекста
-	writer := kafka.NewWriter(cfg.Kafka)
+	writer, err := kafka.NewWriter(cfg.Kafka)
+	if err != nil {
+		fatal("Ошибка создания Kafka writer", err)
+	}
+	defer writer.Close()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		kafka.Generator(writer, ctx)
 	}()
 
-	// Kafka consumer: читает, валидирует, сохраняет в БД и кэш, работает пока не остановится контекст
-	reader := kafka.NewReader(cfg.Kafka)
-	defer reader.Close()
+	// Outbox relay: публикует события order.stored из outbox в топик orders.stored (at-least-once)
+	storedWriter, err := kafka.NewTopicWriter(cfg.Kafka, cfg.Kafka.StoredTopic)
+	if err != nil {
+		fatal("Ошибка создания Kafka writer для outbox", err)
+	}
+	defer storedWriter.Close()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		kafka.ConsumeMessages(reader, postgres, orderCache, ctx)
+		kafka.OutboxRelay(storedWriter, postgres, ctx, cfg.Kafka.OutboxInterval)
 	}()
 
-	// HTTP сервер, хендлеры используют кэш и репозиторий
-	srv := server.NewServer(cfg.HttpServer, orderCache, postgres)
+	// Kafka consumer: пул воркеров читает, валидирует, сохраняет в БД и кэш, работает пока не остановится контекст
+	reader, err := kafka.NewReader(cfg.Kafka)
+	if err != nil {
+		fatal("Ошибка создания Kafka reader", err)
+	}
+	defer reader.Close()
+
+	// Форматы тела сообщений: JSON/Protobuf/Avro по content-type, топику или KAFKA_CODEC
+	codecs, err := kafka.NewCodecs(cfg.Kafka)
+	if err != nil {
+		fatal("Ошибка настройки форматов сообщений", err)
+	}
+
+	consumer := kafka.NewConsumer(reader, postgres, orderCache, cfg.Kafka, kafka.WithCodecs(codecs))
+
+	// Дедупликация: повторно доставленные события подтверждаются без сохранения, отметки живут KAFKA_DEDUP_TTL
+	if cfg.Kafka.DedupTTL > 0 {
+		consumer.Deduplicate(postgres)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kafka.DedupPurger(postgres, ctx, cfg.Kafka.DedupTTL)
+		}()
+	}
+
+	// Метрики для GET /metrics: консьюмер, генератор, kafka.Reader, HTTP, кэш и пул соединений с БД
+	registry := metrics.NewRegistry()
+	kafka.RegisterMetrics(registry, reader)
+	server.RegisterMetrics(registry)
+	orderCache.Register(registry)
+	db.RegisterMetrics(registry, database)
+
+	// Предохранитель БД: при недоступной базе консьюмер не читает Kafka, а пингует её до восстановления
+	var breaker *kafka.Breaker
+	if cfg.Kafka.BreakerThreshold > 0 {
+		breaker = kafka.NewBreaker(database, cfg.Kafka.BreakerThreshold, cfg.Kafka.BreakerProbeInterval)
+		breaker.Register(registry)
+		consumer.UseBreaker(breaker)
+	}
+
+	// Готовность для /readyz: БД отвечает на пинг, брокеры Kafka отдают метаданные топиков, кэш прогрет,
+	// консьюмер не остановлен предохранителем
+	admin, err := kafka.NewAdmin(cfg.Kafka)
+	if err != nil {
+		fatal("Ошибка создания Kafka admin", err)
+	}
+	readiness := server.NewReadiness(cfg.HttpServer.ReadyTimeout)
+	readiness.Add("postgres", database.PingContext)
+	readiness.Add("kafka", func(ctx context.Context) error {
+		return admin.Ping(ctx, cfg.Kafka.ConsumeTopics()...)
+	})
+	readiness.Add("cache", func(context.Context) error {
+		if !orderCache.Warm() {
+			return errors.New("кэш ещё загружается из БД")
+		}
+		return nil
+	})
+	if breaker != nil {
+		readiness.Add("kafka_consumer", breaker.Ready)
+	}
+
+	// Аутентификация API: ключи из AUTH_API_KEYS и JWT, JWKS загружается до старта HTTP
+	authenticator, err := auth.New(ctx, cfg.HttpServer.Auth)
+	if err != nil {
+		fatal("Ошибка настройки аутентификации", err)
+	}
+
+	// Маскирование полей заказа по ролям клиента (AUTH_FIELD_POLICY), без файла ответы не меняются
+	var fieldPolicy *masking.Policy
+	if cfg.HttpServer.Auth.FieldPolicy != "" {
+		fieldPolicy, err = masking.Load(cfg.HttpServer.Auth.FieldPolicy)
+		if err != nil {
+			fatal("Ошибка загрузки политики маскирования", err)
+		}
+	}
+
+	// Лимит частоты запросов по ключу API или IP клиента, X-Forwarded-For - только от доверенных прокси
+	limits, err := ratelimit.New(cfg.HttpServer.RateLimit)
+	if err != nil {
+		fatal("Ошибка настройки лимита запросов", err)
+	}
+	if limits != nil {
+		limits.Register(registry)
+	}
+
+	// Cache-Control по маршрутам, ETag и 304 отдаются всегда
+	cacheControl, err := cfg.HttpServer.ParseCacheControl()
+	if err != nil {
+		fatal("Ошибка настройки Cache-Control", err)
+	}
+
+	// Админский режим консьюмера: POST /admin/replay переигрывает диапазон топика тем же пайплайном,
+	// новые заказы сразу попадают в кэш, оффсеты группы не трогаются
+	replay := func(ctx context.Context, opts kafka.ReplayOptions, write bool) (kafka.ReplayReport, error) {
+		ranges, err := admin.ResolveRanges(ctx, opts)
+		if err != nil {
+			return kafka.ReplayReport{}, err
+		}
+		return consumer.Replay(ctx, admin.PartitionReader(opts.Topic), ranges, write)
+	}
+
+	// HTTP сервер, хендлеры используют кэш и репозиторий; /readyz и /metrics показывают состояние консьюмера, /admin/replay переигрывает топик
+	srv := server.NewServer(cfg.HttpServer, orderCache, postgres,
+		server.WithAuth(authenticator), server.WithFieldPolicy(fieldPolicy), server.WithRateLimit(limits),
+		server.WithCacheControl(cacheControl),
+		server.WithProbes(readiness), server.WithMetrics(registry), server.WithReplay(cfg.Kafka.Topic, replay))
 
 	// Запуск HTTP сервера в горутине, фатал при ошибке кроме штатного закрытия
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatal("Ошибка запуска сервера:", err)
+			fatal("Ошибка запуска сервера", err)
 		}
 	}()
 
-	// Ожидание сигнала, затем мягкая остановка HTTP и завершение горутин с таймаутом
+	// Прогрев кэша, консьюмер стартует после него, чтобы загрузка не перезаписала свежие заказы старыми
+	if err := orderCache.Load(postgres); err != nil {
+		fatal("Ошибка загрузки кэша", err)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		consumer.Run(ctx)
+	}()
+
+	// Ожидание сигнала: сначала /readyz отвечает 503 и балансировщик убирает инстанс,
+	// затем мягкая остановка HTTP и завершение горутин с таймаутом
 	<-ctx.Done()
-	ctxShutdown, cancel := context.WithTimeout(ctx, 5*time.Second)
+	readiness.Shutdown()
+	slog.Info("Сервис больше не готов принимать трафик, ждём перед остановкой HTTP", "delay", cfg.HttpServer.ShutdownDelay)
+	time.Sleep(cfg.HttpServer.ShutdownDelay)
+
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctxShutdown); err != nil {
-		log.Println("Ошибка завершения сервера с shutdown")
+		slog.Error("Ошибка завершения сервера с shutdown", logger.Err(err))
 	}
 
 	wg.Wait()
 
+	// Дописываем оставшиеся спаны уже после остановки консьюмера и HTTP
+	ctxTracing, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(ctxTracing); err != nil {
+		slog.Error("Ошибка остановки трейсинга", logger.Err(err))
+	}
+}
+
+// fatal пишет ошибку в лог и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, logger.Err(err))
+	os.Exit(1)
 }
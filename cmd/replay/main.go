@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/cache"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/db"
+	"github.com/fathersson/wb-demo-service/internal/kafka"
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+)
+
+// replay - переигрывание исторических сообщений Kafka после исправления валидации
+//
+// Примеры:
+//
+//	go run ./cmd/replay -from-time 2025-01-01T00:00:00Z            # dry-run: что было бы принято/отклонено
+//	go run ./cmd/replay -partitions 0,2 -from-offset 100 -write    # сохранить ранее отклонённые заказы
+//	go run ./cmd/replay -from-offset 0 -seek-group my_group        # перемотать группу, перечитает основной сервис
+func main() {
+	topic := flag.String("topic", "", "топик (по умолчанию KAFKA_TOPIC)")
+	partitionsFlag := flag.String("partitions", "", "партиции через запятую (по умолчанию все)")
+	fromOffset := flag.Int64("from-offset", -1, "начальный оффсет (по умолчанию первый доступный)")
+	fromTime := flag.String("from-time", "", "начать с первого сообщения не раньше времени (RFC3339)")
+	toOffset := flag.Int64("to-offset", -1, "конечный оффсет, не включительно (по умолчанию текущий конец)")
+	write := flag.Bool("write", false, "сохранять новые заказы в БД (по умолчанию dry-run)")
+	seekGroup := flag.String("seek-group", "", "вместо переигрывания перемотать consumer group на начало диапазона")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Загрузка конфигурации (.env/окружение)
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("Ошибка загрузки конфигурации", err)
+	}
+
+	// Логи - в stderr, в stdout уходит только отчёт
+	slog.SetDefault(logger.New(cfg.Log, os.Stderr))
+
+	opts := kafka.ReplayOptions{
+		Topic:      cfg.Kafka.Topic,
+		FromOffset: *fromOffset,
+		ToOffset:   *toOffset,
+	}
+	if *topic != "" {
+		opts.Topic = *topic
+	}
+	if opts.Partitions, err = parsePartitions(*partitionsFlag); err != nil {
+		fatal("Некорректный список партиций", err)
+	}
+	if *fromTime != "" {
+		if opts.FromTime, err = time.Parse(time.RFC3339, *fromTime); err != nil {
+			fatal("Некорректное время -from-time", err)
+		}
+	}
+
+	admin, err := kafka.NewAdmin(cfg.Kafka)
+	if err != nil {
+		fatal("Ошибка создания Kafka клиента", err)
+	}
+	ranges, err := admin.ResolveRanges(ctx, opts)
+	if err != nil {
+		fatal("Ошибка определения диапазонов", err)
+	}
+	for _, pr := range ranges {
+		slog.Info("Диапазон партиции", logger.KeyPartition, pr.Partition, "from", pr.From, "to", pr.To)
+	}
+
+	// Перемотка группы: основной сервис должен быть остановлен, после запуска он перечитает диапазон
+	if *seekGroup != "" {
+		if err := admin.SeekGroup(ctx, *seekGroup, opts.Topic, ranges); err != nil {
+			fatal("Ошибка перемотки группы", err)
+		}
+		slog.Info("Группа перемотана", "group", *seekGroup)
+		return
+	}
+
+	// Подключение к PostgreSQL, нужно и в dry-run для сравнения с сохранёнными заказами
+	database, err := db.Connect(&cfg.Database)
+	if err != nil {
+		fatal("Не удалось подключиться к базе", err)
+	}
+	defer database.Close()
+
+	// В режиме записи SaveOrder пишет и в outbox: недостающие таблицы создаются, dry-run базу не меняет
+	if *write {
+		if err := db.Migrate(ctx, database); err != nil {
+			fatal("Не удалось подготовить схему БД", err)
+		}
+	}
+
+	// Форматы тела сообщений те же, что у консьюмера
+	codecs, err := kafka.NewCodecs(cfg.Kafka)
+	if err != nil {
+		fatal("Ошибка настройки форматов сообщений", err)
+	}
+
+	postgres := repository.NewPostgresRepo(database)
+	consumer := kafka.NewConsumer(nil, postgres, cache.NewCache(), cfg.Kafka, kafka.WithCodecs(codecs))
+
+	if !*write {
+		slog.Info("Режим dry-run: в БД ничего не пишется")
+	}
+	report, err := consumer.Replay(ctx, admin.PartitionReader(opts.Topic), ranges, *write)
+	if err != nil {
+		slog.Error("Переигрывание прервано", logger.Err(err))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+}
+
+// parsePartitions разбирает список партиций "0,1,2"
+func parsePartitions(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var partitions []int
+	for _, part := range strings.Split(s, ",") {
+		p, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, nil
+}
+
+// fatal пишет ошибку в лог и завершает процесс
+func fatal(msg string, err error) {
+	slog.Error(msg, logger.Err(err))
+	os.Exit(1)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+)
+
+// Скоупы доступа к API, маршрут требует один из них
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+	ScopeAdmin       = "admin" // разрешает любой маршрут
+)
+
+// APIKeyHeader - заголовок со статическим ключом клиента
+const APIKeyHeader = "X-API-Key"
+
+// Способы аутентификации в Principal.Method
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrNoCredentials - запрос без ключа и токена
+	ErrNoCredentials = errors.New("нет ключа API или токена")
+	// ErrInvalidCredentials - ключ неизвестен, токен не прошёл проверку подписи или срока
+	ErrInvalidCredentials = errors.New("неверный ключ API или токен")
+)
+
+// Principal - аутентифицированный клиент: имя ключа или sub из токена, его скоупы и роли
+// Скоупы решают, какие маршруты доступны, роли - какие поля ответа видны (internal/masking)
+type Principal struct {
+	Subject string
+	Method  string
+	Scopes  []string
+	Roles   []string
+}
+
+// HasScope - есть ли у клиента скоуп; admin разрешает всё
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// Authenticator проверяет ключи API из конфига и JWT (HS256 по секрету, RS256 по JWKS)
+type Authenticator struct {
+	keys   []config.APIKey
+	secret []byte
+	jwks   *keySet // nil - RS256 не принимается
+	issuer string
+	aud    string
+	leeway time.Duration
+}
+
+// New создаёт проверку по конфигу; JWKS из файла или по URL загружается сразу
+// Выключенная аутентификация - nil: такой Authenticator пропускает все запросы
+func New(ctx context.Context, cfg config.AuthConfig) (*Authenticator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	keys, err := cfg.ParseAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	a := &Authenticator{keys: keys, secret: []byte(cfg.JWTSecret), issuer: cfg.Issuer, aud: cfg.Audience, leeway: cfg.Leeway}
+	switch {
+	case cfg.JWKSFile != "":
+		a.jwks = fileKeySet(cfg.JWKSFile)
+	case cfg.JWKSURL != "":
+		a.jwks = urlKeySet(cfg.JWKSURL, cfg.JWKSRefresh)
+	}
+	if a.jwks != nil {
+		if err := a.jwks.load(ctx); err != nil {
+			return nil, fmt.Errorf("не удалось загрузить JWKS: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// Enabled - включена ли проверка; у nil - нет
+func (a *Authenticator) Enabled() bool {
+	return a != nil
+}
+
+// Authenticate проверяет X-API-Key или Authorization: Bearer <JWT>
+// Ключ API проверяется первым, если в запросе есть оба
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.apiKey(key)
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	return a.jwt(r.Context(), strings.TrimSpace(token))
+}
+
+// apiKey ищет ключ по SHA-256, сравнивая хэши за постоянное время
+func (a *Authenticator) apiKey(key string) (Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+	for _, k := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.Hash) == 1 {
+			p := Principal{Subject: k.Name, Method: MethodAPIKey, Scopes: k.Scopes}
+			if k.Role != "" {
+				p.Roles = []string{k.Role}
+			}
+			return p, nil
+		}
+	}
+	return Principal{}, fmt.Errorf("%w: ключ API не найден", ErrInvalidCredentials)
+}
+
+type principalKey struct{}
+
+// WithPrincipal кладёт клиента в контекст запроса
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext - клиент из контекста, false - запрос не аутентифицирован
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Roles - роли клиента из контекста, у неаутентифицированного запроса их нет
+func Roles(ctx context.Context) []string {
+	p, _ := FromContext(ctx)
+	return p.Roles
+}
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func segment(t *testing.T, v any) string {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hs256 - токен, подписанный общим секретом
+func hs256(t *testing.T, claims map[string]any) string {
+	signed := segment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// rs256 - токен, подписанный закрытым ключом с идентификатором kid
+func rs256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	signed := segment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + segment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// writeJWKS сохраняет открытый ключ в файл JWKS
+func writeJWKS(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	b, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	return path
+}
+
+func request(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/order/id1", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+// TestAuthenticate_APIKey
+// Проверяет ключи API: в конфиге только SHA-256, клиент получает имя и скоупы ключа,
+// неизвестный ключ - ErrInvalidCredentials, запрос без ключа и токена - ErrNoCredentials
+func TestAuthenticate_APIKey(t *testing.T) {
+	sum := sha256.Sum256([]byte("s3cret-key"))
+	a, err := New(context.Background(), config.AuthConfig{
+		Enabled: true,
+		APIKeys: []string{"support:" + hex.EncodeToString(sum[:]) + ":orders:read"},
+	})
+	require.NoError(t, err)
+
+	p, err := a.Authenticate(request(APIKeyHeader, "s3cret-key"))
+	require.NoError(t, err)
+	assert.Equal(t, Principal{Subject: "support", Method: MethodAPIKey, Scopes: []string{ScopeOrdersRead}}, p)
+	assert.True(t, p.HasScope(ScopeOrdersRead))
+	assert.False(t, p.HasScope(ScopeAdmin))
+
+	_, err = a.Authenticate(request(APIKeyHeader, "guess"))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(request("", ""))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+// TestAuthenticate_HS256
+// Проверяет JWT с общим секретом: скоупы из scope и scp, проверка exp, iss и aud
+func TestAuthenticate_HS256(t *testing.T) {
+	a, err := New(context.Background(), config.AuthConfig{
+		Enabled: true, JWTSecret: secret, Issuer: "https://idp.example", Audience: "orders-api", Leeway: time.Second,
+	})
+	require.NoError(t, err)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	token := hs256(t, map[string]any{"sub": "agent-7", "iss": "https://idp.example", "aud": []string{"orders-api"},
+		"exp": exp, "scope": "orders:read", "scp": []string{"orders:write"}})
+	p, err := a.Authenticate(request("Authorization", "Bearer "+token))
+	require.NoError(t, err)
+	assert.Equal(t, "agent-7", p.Subject)
+	assert.Equal(t, MethodJWT, p.Method)
+	assert.Equal(t, []string{ScopeOrdersRead, ScopeOrdersWrite}, p.Scopes)
+
+	for name, claims := range map[string]map[string]any{
+		"истёк":     {"iss": "https://idp.example", "aud": "orders-api", "exp": time.Now().Add(-time.Minute).Unix()},
+		"без exp":   {"iss": "https://idp.example", "aud": "orders-api"},
+		"ещё рано":  {"iss": "https://idp.example", "aud": "orders-api", "exp": exp, "nbf": time.Now().Add(time.Minute).Unix()},
+		"чужой iss": {"iss": "https://evil.example", "aud": "orders-api", "exp": exp},
+		"чужой aud": {"iss": "https://idp.example", "aud": "billing", "exp": exp},
+	} {
+		_, err := a.Authenticate(request("Authorization", "Bearer "+hs256(t, claims)))
+		assert.ErrorIs(t, err, ErrInvalidCredentials, name)
+	}
+
+	// Подделанное тело не проходит проверку подписи
+	forged := hs256(t, map[string]any{"exp": exp})
+	forged = forged[:len(forged)-2] + "xx"
+	_, err = a.Authenticate(request("Authorization", "Bearer "+forged))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+// TestAuthenticate_RS256
+// Проверяет JWT с ключом из JWKS: верная подпись принимается,
+// чужой ключ, неизвестный kid, alg=none и HS256 без секрета - нет
+func TestAuthenticate_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	a, err := New(context.Background(), config.AuthConfig{Enabled: true, JWKSFile: writeJWKS(t, key, "k1")})
+	require.NoError(t, err)
+	claims := map[string]any{"sub": "finance", "exp": time.Now().Add(time.Hour).Unix(), "scope": "admin"}
+
+	p, err := a.Authenticate(request("Authorization", "Bearer "+rs256(t, key, "k1", claims)))
+	require.NoError(t, err)
+	assert.Equal(t, "finance", p.Subject)
+	assert.True(t, p.HasScope(ScopeOrdersRead))
+
+	none := segment(t, map[string]string{"alg": "none"}) + "." + segment(t, claims) + "."
+	for name, token := range map[string]string{
+		"чужой ключ":      rs256(t, other, "k1", claims),
+		"неизвестный kid": rs256(t, key, "k2", claims),
+		"alg none":        none,
+		"HS256":           hs256(t, claims),
+		"не JWT":          "abc",
+	} {
+		_, err := a.Authenticate(request("Authorization", "Bearer "+token))
+		assert.ErrorIs(t, err, ErrInvalidCredentials, name)
+	}
+}
+
+// TestAuthenticate_JWKSURL
+// Проверяет ключи по URL: загрузка при старте и перечитывание при неизвестном kid не чаще интервала
+func TestAuthenticate_JWKSURL(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	current, fetches := writeJWKS(t, key, "k1"), 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		http.ServeFile(w, r, current)
+	}))
+	defer srv.Close()
+
+	a, err := New(context.Background(), config.AuthConfig{Enabled: true, JWKSURL: srv.URL, JWKSRefresh: time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fetches)
+
+	// Провайдер сменил ключ, но интервал не прошёл - JWKS не перечитывается
+	current = writeJWKS(t, rotated, "k2")
+	claims := map[string]any{"exp": time.Now().Add(time.Hour).Unix()}
+	_, err = a.Authenticate(request("Authorization", "Bearer "+rs256(t, rotated, "k2", claims)))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, 1, fetches)
+
+	a.jwks.loadedAt = time.Now().Add(-2 * time.Hour)
+	_, err = a.Authenticate(request("Authorization", "Bearer "+rs256(t, rotated, "k2", claims)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fetches)
+}
+
+// TestAuthenticate_JWKSSlowRefresh
+// Проверяет, что медленное перечитывание JWKS не блокирует токены с известным kid,
+// а одновременные токены с неизвестным kid дают один запрос к провайдеру
+func TestAuthenticate_JWKSSlowRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := writeJWKS(t, key, "k1")
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		http.ServeFile(w, r, path)
+	}))
+	defer srv.Close()
+
+	a, err := New(context.Background(), config.AuthConfig{Enabled: true, JWKSURL: srv.URL, JWKSRefresh: time.Hour})
+	require.NoError(t, err)
+	a.jwks.mu.Lock()
+	a.jwks.loadedAt = time.Now().Add(-2 * time.Hour)
+	a.jwks.mu.Unlock()
+
+	claims := map[string]any{"exp": time.Now().Add(time.Hour).Unix()}
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := a.Authenticate(request("Authorization", "Bearer "+rs256(t, key, "forged", claims)))
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}()
+	}
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, 5*time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.Authenticate(request("Authorization", "Bearer "+rs256(t, key, "k1", claims)))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("токен с известным kid ждёт перечитывания JWKS")
+	}
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+// TestNew_Disabled проверяет, что выключенная аутентификация - nil, пропускающий все запросы
+func TestNew_Disabled(t *testing.T) {
+	a, err := New(context.Background(), config.AuthConfig{})
+	require.NoError(t, err)
+	assert.False(t, a.Enabled())
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// jwks - набор открытых ключей по RFC 7517
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk - открытый ключ; берутся только RSA-ключи для подписи
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// keySet - ключи RS256 по kid из файла или по URL
+// Ключи по URL перечитываются, когда приходит токен с неизвестным kid (провайдер сменил ключ),
+// но не чаще refresh, чтобы токены с выдуманным kid не превращались в запросы к провайдеру
+// Запрос к провайдеру идёт без блокировки: проверка токенов с известным kid его не ждёт
+type keySet struct {
+	fetch   func(ctx context.Context) ([]byte, error)
+	refresh time.Duration // 0 - не перечитывать (файл)
+
+	mu       sync.RWMutex
+	keys     map[string]*rsa.PublicKey
+	loadedAt time.Time // последняя попытка загрузки, в том числе неудачная
+
+	reload singleflight.Group // одно перечитывание на все запросы с неизвестным kid
+}
+
+// fileKeySet - ключи из локального файла, читаются один раз при старте
+func fileKeySet(path string) *keySet {
+	return &keySet{fetch: func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}}
+}
+
+// urlKeySet - ключи по URL провайдера
+func urlKeySet(url string, refresh time.Duration) *keySet {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return &keySet{refresh: refresh, fetch: func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	}}
+}
+
+// load перечитывает набор; при ошибке остаются прежние ключи
+func (s *keySet) load(ctx context.Context) error {
+	s.mu.Lock()
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	b, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	var set jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return fmt.Errorf("некорректный JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != "RS256") {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return fmt.Errorf("ключ %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("в JWKS нет ключей RS256")
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil
+}
+
+// key - ключ по kid; токен без kid подходит, только если ключ в наборе один
+// Запросы с неизвестным kid во время перечитывания ждут его, а не запускают своё
+func (s *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	if s.refresh > 0 {
+		_, err, _ := s.reload.Do("", func() (any, error) {
+			if !s.due() {
+				return nil, nil
+			}
+			// Перечитывание общее, отмена запроса, который его начал, не должна сорвать его остальным
+			return nil, s.load(context.WithoutCancel(ctx))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("не удалось перечитать JWKS: %w", err)
+		}
+		if key, ok := s.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестный kid %q", kid)
+}
+
+// due - прошёл ли интервал с последней попытки загрузки
+func (s *keySet) due() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Since(s.loadedAt) >= s.refresh
+}
+
+func (s *keySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// publicKey собирает открытый ключ из модуля n и экспоненты e в base64url
+func (k jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, fmt.Errorf("некорректный модуль n")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("некорректная экспонента e")
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	if key.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("ключ RSA короче 2048 бит")
+	}
+	return key, nil
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// header - заголовок JWT, нужны алгоритм и идентификатор ключа
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// claims - поля токена, которые проверяются или попадают в Principal
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	Scope     string   `json:"scope"` // скоупы через пробел (RFC 8693)
+	Scp       []string `json:"scp"`   // или массивом, как у некоторых провайдеров
+	Roles     []string `json:"roles"` // роли для маскирования полей
+	Role      string   `json:"role"`  // или одна роль строкой
+}
+
+// audience - aud бывает строкой или массивом строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// jwt проверяет подпись, срок, iss и aud токена
+// Алгоритм определяется настройками, а не только заголовком: HS256 - только при заданном секрете,
+// RS256 - только при JWKS, "none" и остальные отклоняются
+func (a *Authenticator) jwt(ctx context.Context, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("%w: токен должен состоять из трёх частей", ErrInvalidCredentials)
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Principal{}, fmt.Errorf("%w: заголовок токена: %v", ErrInvalidCredentials, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: подпись токена: %v", ErrInvalidCredentials, err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch {
+	case h.Alg == "HS256" && len(a.secret) > 0:
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return Principal{}, fmt.Errorf("%w: неверная подпись HS256", ErrInvalidCredentials)
+		}
+	case h.Alg == "RS256" && a.jwks != nil:
+		key, err := a.jwks.key(ctx, h.Kid)
+		if err != nil {
+			return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return Principal{}, fmt.Errorf("%w: неверная подпись RS256", ErrInvalidCredentials)
+		}
+	default:
+		return Principal{}, fmt.Errorf("%w: алгоритм %q не принимается", ErrInvalidCredentials, h.Alg)
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Principal{}, fmt.Errorf("%w: тело токена: %v", ErrInvalidCredentials, err)
+	}
+	if err := a.checkClaims(c, time.Now()); err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	scopes := strings.Fields(c.Scope)
+	for _, s := range c.Scp {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	roles := c.Roles
+	if c.Role != "" && !slices.Contains(roles, c.Role) {
+		roles = append(roles, c.Role)
+	}
+	return Principal{Subject: c.Subject, Method: MethodJWT, Scopes: scopes, Roles: roles}, nil
+}
+
+// checkClaims - exp обязателен, nbf, iss и aud проверяются, если заданы
+func (a *Authenticator) checkClaims(c claims, now time.Time) error {
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("в токене нет exp")
+	}
+	if now.After(time.Unix(*c.ExpiresAt, 0).Add(a.leeway)) {
+		return fmt.Errorf("срок действия токена истёк")
+	}
+	if c.NotBefore != nil && now.Add(a.leeway).Before(time.Unix(*c.NotBefore, 0)) {
+		return fmt.Errorf("токен ещё не действует")
+	}
+	if a.issuer != "" && c.Issuer != a.issuer {
+		return fmt.Errorf("неожиданный iss %q", c.Issuer)
+	}
+	if a.aud != "" && !slices.Contains(c.Audience, a.aud) {
+		return fmt.Errorf("токен выпущен не для %q", a.aud)
+	}
+	return nil
+}
+
+// decodeSegment - base64url без padding и JSON
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
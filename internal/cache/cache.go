@@ -3,9 +3,12 @@ package cache
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/metrics"
 	"github.com/fathersson/wb-demo-service/internal/models"
 	"github.com/fathersson/wb-demo-service/internal/repository"
 )
@@ -14,6 +17,7 @@ import (
 type CacheInterface interface {
 	SetCache(orderUID string, order models.Order)
 	GetCache(orderUID string) (models.Order, bool)
+	DeleteCache(orderUID string)
 }
 
 // Cache - простой in-memory кэш заказов
@@ -24,6 +28,21 @@ type Cache struct {
 	Orders map[string]models.Order
 	keys   []string // порядок добавления записей в кэш
 	maxLen int      // лимит для кэша
+
+	// Счётчики для метрик, меняются без блокировки
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+
+	warm atomic.Bool // кэш загружен из БД
+}
+
+// Stats - статистика кэша
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
 }
 
 func NewCache() *Cache {
@@ -45,6 +64,7 @@ func (c *Cache) SetCache(orderUID string, order models.Order) {
 		oldKey := c.keys[0]
 		delete(c.Orders, oldKey)
 		c.keys = c.keys[1:]
+		c.evictions.Add(1)
 	}
 	c.Orders[orderUID] = order
 	c.mu.Unlock()
@@ -58,18 +78,86 @@ func (c *Cache) GetCache(orderUID string) (models.Order, bool) {
 	order, ok := c.Orders[orderUID]
 	c.mu.RUnlock()
 
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
 	return order, ok
 }
 
-// NewCacheFromDB загружает заказы из БД в кэш при старте
-// Использует OrderRepository - сначала читает базовые поля из orders, затем дочитывает delivery/payment/items
-// Любая ошибка чтения/сканирования - фатальна для инициализации
+// DeleteCache удаляет заказ из кэша и из очереди keys
+func (c *Cache) DeleteCache(orderUID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.Orders[orderUID]; !ok {
+		return
+	}
+	delete(c.Orders, orderUID)
+	for i, key := range c.keys {
+		if key == orderUID {
+			c.keys = append(c.keys[:i], c.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Stats возвращает попадания, промахи, вытеснения и текущий размер кэша
+func (c *Cache) Stats() Stats {
+	c.mu.RLock()
+	size := len(c.Orders)
+	c.mu.RUnlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+	}
+}
+
+// Register добавляет метрики кэша в реестр
+func (c *Cache) Register(reg *metrics.Registry) {
+	reg.CounterFunc("cache_hits_total", "Заказов найдено в кэше", func() float64 {
+		return float64(c.Stats().Hits)
+	})
+	reg.CounterFunc("cache_misses_total", "Заказов не найдено в кэше", func() float64 {
+		return float64(c.Stats().Misses)
+	})
+	reg.CounterFunc("cache_evictions_total", "Заказов вытеснено из кэша по лимиту", func() float64 {
+		return float64(c.Stats().Evictions)
+	})
+	reg.GaugeFunc("cache_size", "Заказов в кэше", func() float64 {
+		return float64(c.Stats().Size)
+	})
+	reg.GaugeFunc("cache_capacity", "Лимит заказов в кэше", func() float64 {
+		return float64(c.maxLen)
+	})
+}
+
+// NewCacheFromDB создаёт кэш и загружает в него заказы из БД
 func NewCacheFromDB(db repository.OrderRepository) (*Cache, error) {
 	cache := NewCache()
+	if err := cache.Load(db); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Warm - загружен ли кэш из БД; до этого сервис не готов принимать трафик
+func (c *Cache) Warm() bool {
+	return c.warm.Load()
+}
+
+// Load загружает заказы из БД в кэш при старте
+// Использует OrderRepository - сначала читает базовые поля из orders, затем дочитывает delivery/payment/items
+// Любая ошибка чтения/сканирования - фатальна для инициализации
+func (c *Cache) Load(db repository.OrderRepository) error {
 
 	rows, err := db.Query("SELECT order_uid, track_number, entry, locale, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard FROM orders")
 	if err != nil {
-		return nil, fmt.Errorf("ошибка запроса к базе: %w", err)
+		return fmt.Errorf("ошибка запроса к базе: %w", err)
 	}
 	defer rows.Close()
 
@@ -81,7 +169,7 @@ func NewCacheFromDB(db repository.OrderRepository) (*Cache, error) {
 		if err := rows.Scan(&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
 			&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID,
 			&order.DateCreated, &order.OofShard); err != nil {
-			return nil, fmt.Errorf("ошибка сканирования заказа: %w", err)
+			return fmt.Errorf("ошибка сканирования заказа: %w", err)
 		}
 
 		// заполняем поля delivery
@@ -90,7 +178,7 @@ func NewCacheFromDB(db repository.OrderRepository) (*Cache, error) {
 				&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region,
 				&order.Delivery.Email)
 		if err != nil && err != sql.ErrNoRows {
-			return nil, fmt.Errorf("ошибка сканирования delivery: %w", err)
+			return fmt.Errorf("ошибка сканирования delivery: %w", err)
 		}
 
 		// заполняем поля payment
@@ -99,13 +187,13 @@ func NewCacheFromDB(db repository.OrderRepository) (*Cache, error) {
 				&order.Payment.Amount, &order.Payment.PaymentDT, &order.Payment.Bank,
 				&order.Payment.DeliveryCost, &order.Payment.GoodsTotal, &order.Payment.CustomFee)
 		if err != nil && err != sql.ErrNoRows {
-			return nil, fmt.Errorf("ошибка сканирования payment: %w", err)
+			return fmt.Errorf("ошибка сканирования payment: %w", err)
 		}
 
 		// заполняем поля items
 		itemRows, err := db.Query(`SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status FROM items WHERE order_uid = $1`, order.OrderUID)
 		if err != nil {
-			return nil, fmt.Errorf("ошибка запроса к базе: %w", err)
+			return fmt.Errorf("ошибка запроса к базе: %w", err)
 		}
 
 		for itemRows.Next() {
@@ -115,23 +203,24 @@ func NewCacheFromDB(db repository.OrderRepository) (*Cache, error) {
 				&item.Name, &item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand,
 				&item.Status); err != nil {
 				itemRows.Close()
-				return nil, fmt.Errorf("ошибка сканирования items: %w", err)
+				return fmt.Errorf("ошибка сканирования items: %w", err)
 			}
 			order.Items = append(order.Items, item)
 		}
 		itemRows.Close()
 
 		// сохраняем в кэш
-		cache.SetCache(order.OrderUID, order)
+		c.SetCache(order.OrderUID, order)
 
-		log.Printf("Заказ %s загружен в кэш", order.OrderUID)
+		slog.Debug("Заказ загружен в кэш", logger.KeyOrderUID, order.OrderUID)
 	}
 
 	err = rows.Err()
 	if err != nil {
-		return nil, fmt.Errorf("ошибка запроса к базе: %w", err)
+		return fmt.Errorf("ошибка запроса к базе: %w", err)
 	}
 
-	log.Printf("Инициализация кэша завершена. Загружено %d заказов", len(cache.Orders))
-	return cache, nil
+	c.warm.Store(true)
+	slog.Info("Инициализация кэша завершена", "orders", c.Stats().Size)
+	return nil
 }
@@ -1,10 +1,14 @@
 package cache
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestSetCache_GetCache проверяет базовый сценарий:
@@ -113,3 +117,40 @@ func TestSetCache_Concurrent(t *testing.T) {
 		assert.True(t, ok)
 	}
 }
+
+// TestCache_Stats проверяет статистику для метрик:
+// 1) Одно попадание и один промах
+// 2) При лимите 1 второй заказ вытесняет первый, размер остаётся 1
+func TestCache_Stats(t *testing.T) {
+	c := NewCache()
+	c.maxLen = 1
+
+	c.SetCache("a", models.Order{OrderUID: "a"})
+	c.GetCache("a")
+	c.GetCache("missing")
+	c.SetCache("b", models.Order{OrderUID: "b"})
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Evictions: 1, Size: 1}, c.Stats())
+}
+
+// TestCache_LoadWarm проверяет признак прогрева:
+// 1) До загрузки и при ошибке чтения кэш не прогрет
+// 2) После успешной загрузки (даже пустой таблицы) прогрет
+func TestCache_LoadWarm(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	repo := repository.NewPostgresRepo(db)
+
+	c := NewCache()
+	assert.False(t, c.Warm())
+
+	mock.ExpectQuery("SELECT order_uid").WillReturnError(errors.New("connection refused"))
+	assert.Error(t, c.Load(repo))
+	assert.False(t, c.Warm())
+
+	mock.ExpectQuery("SELECT order_uid").WillReturnRows(sqlmock.NewRows([]string{"order_uid"}))
+	assert.NoError(t, c.Load(repo))
+	assert.True(t, c.Warm())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
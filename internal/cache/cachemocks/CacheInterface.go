@@ -20,6 +20,39 @@ func (_m *CacheInterface) EXPECT() *CacheInterface_Expecter {
 	return &CacheInterface_Expecter{mock: &_m.Mock}
 }
 
+// DeleteCache provides a mock function with given fields: orderUID
+func (_m *CacheInterface) DeleteCache(orderUID string) {
+	_m.Called(orderUID)
+}
+
+// CacheInterface_DeleteCache_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteCache'
+type CacheInterface_DeleteCache_Call struct {
+	*mock.Call
+}
+
+// DeleteCache is a helper method to define mock.On call
+//   - orderUID string
+func (_e *CacheInterface_Expecter) DeleteCache(orderUID interface{}) *CacheInterface_DeleteCache_Call {
+	return &CacheInterface_DeleteCache_Call{Call: _e.mock.On("DeleteCache", orderUID)}
+}
+
+func (_c *CacheInterface_DeleteCache_Call) Run(run func(orderUID string)) *CacheInterface_DeleteCache_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *CacheInterface_DeleteCache_Call) Return() *CacheInterface_DeleteCache_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *CacheInterface_DeleteCache_Call) RunAndReturn(run func(string)) *CacheInterface_DeleteCache_Call {
+	_c.Run(run)
+	return _c
+}
+
 // GetCache provides a mock function with given fields: orderUID
 func (_m *CacheInterface) GetCache(orderUID string) (models.Order, bool) {
 	ret := _m.Called(orderUID)
@@ -0,0 +1,215 @@
+package codec
+
+import (
+	_ "embed"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/hamba/avro/v2"
+)
+
+// AvroSchema - Avro-схема заказа (schema/order.avsc), её же регистрируют в реестре схем
+//
+//go:embed schema/order.avsc
+var AvroSchema string
+
+// avroSchema - разобранная AvroSchema, схема вшита в бинарник, поэтому ошибка разбора - баг сборки
+var avroSchema = avro.MustParse(AvroSchema)
+
+// Avro - заказ в бинарном Avro по схеме schema/order.avsc
+type Avro struct{}
+
+func (Avro) Name() string        { return NameAvro }
+func (Avro) ContentType() string { return contentTypes[NameAvro][0] }
+
+func (Avro) Encode(order models.Order) ([]byte, error) {
+	return avro.Marshal(avroSchema, toAvro(order))
+}
+
+func (a Avro) Decode(data []byte) (models.Order, error) {
+	return a.decodeResolved(avroSchema, data)
+}
+
+// avroOrder и вложенные типы повторяют order.avsc, в модель переводятся явно, чтобы не тащить avro-теги в models
+type avroOrder struct {
+	OrderUID          string       `avro:"order_uid"`
+	TrackNumber       string       `avro:"track_number"`
+	Entry             string       `avro:"entry"`
+	Delivery          avroDelivery `avro:"delivery"`
+	Payment           avroPayment  `avro:"payment"`
+	Items             []avroItem   `avro:"items"`
+	Locale            string       `avro:"locale"`
+	InternalSignature string       `avro:"internal_signature"`
+	CustomerID        string       `avro:"customer_id"`
+	DeliveryService   string       `avro:"delivery_service"`
+	ShardKey          string       `avro:"shardkey"`
+	SmID              int64        `avro:"sm_id"`
+	DateCreated       *time.Time   `avro:"date_created"`
+	OofShard          string       `avro:"oof_shard"`
+}
+
+type avroDelivery struct {
+	Name    string `avro:"name"`
+	Phone   string `avro:"phone"`
+	Zip     string `avro:"zip"`
+	City    string `avro:"city"`
+	Address string `avro:"address"`
+	Region  string `avro:"region"`
+	Email   string `avro:"email"`
+}
+
+type avroPayment struct {
+	Transaction  string `avro:"transaction"`
+	RequestID    string `avro:"request_id"`
+	Currency     string `avro:"currency"`
+	Provider     string `avro:"provider"`
+	Amount       int64  `avro:"amount"`
+	PaymentDT    int64  `avro:"payment_dt"`
+	Bank         string `avro:"bank"`
+	DeliveryCost int64  `avro:"delivery_cost"`
+	GoodsTotal   int64  `avro:"goods_total"`
+	CustomFee    int64  `avro:"custom_fee"`
+}
+
+type avroItem struct {
+	ChrtID      int64  `avro:"chrt_id"`
+	TrackNumber string `avro:"track_number"`
+	Price       int64  `avro:"price"`
+	Rid         string `avro:"rid"`
+	Name        string `avro:"name"`
+	Sale        int64  `avro:"sale"`
+	Size        string `avro:"size"`
+	TotalPrice  int64  `avro:"total_price"`
+	NmID        int64  `avro:"nm_id"`
+	Brand       string `avro:"brand"`
+	Status      int64  `avro:"status"`
+}
+
+func toAvro(o models.Order) avroOrder {
+	a := avroOrder{
+		OrderUID:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery:    avroDelivery(o.Delivery),
+		Payment: avroPayment{
+			Transaction:  o.Payment.Transaction,
+			RequestID:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDT:    o.Payment.PaymentDT,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items:             make([]avroItem, len(o.Items)),
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		ShardKey:          o.ShardKey,
+		SmID:              int64(o.SmID),
+		OofShard:          o.OofShard,
+	}
+	for i, item := range o.Items {
+		a.Items[i] = avroItem{
+			ChrtID:      int64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price),
+			Rid:         item.Rid,
+			Name:        item.Name,
+			Sale:        int64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice),
+			NmID:        int64(item.NmID),
+			Brand:       item.Brand,
+			Status:      int64(item.Status),
+		}
+	}
+	if !o.DateCreated.IsZero() {
+		created := o.DateCreated
+		a.DateCreated = &created
+	}
+	return a
+}
+
+func (a avroOrder) model() models.Order {
+	o := models.Order{
+		OrderUID:    a.OrderUID,
+		TrackNumber: a.TrackNumber,
+		Entry:       a.Entry,
+		Delivery:    models.Delivery(a.Delivery),
+		Payment: models.Payment{
+			Transaction:  a.Payment.Transaction,
+			RequestID:    a.Payment.RequestID,
+			Currency:     a.Payment.Currency,
+			Provider:     a.Payment.Provider,
+			Amount:       int(a.Payment.Amount),
+			PaymentDT:    a.Payment.PaymentDT,
+			Bank:         a.Payment.Bank,
+			DeliveryCost: int(a.Payment.DeliveryCost),
+			GoodsTotal:   int(a.Payment.GoodsTotal),
+			CustomFee:    int(a.Payment.CustomFee),
+		},
+		Locale:            a.Locale,
+		InternalSignature: a.InternalSignature,
+		CustomerID:        a.CustomerID,
+		DeliveryService:   a.DeliveryService,
+		ShardKey:          a.ShardKey,
+		SmID:              int(a.SmID),
+		OofShard:          a.OofShard,
+	}
+	for _, item := range a.Items {
+		o.Items = append(o.Items, models.Item{
+			ChrtID:      int(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int(item.Price),
+			Rid:         item.Rid,
+			Name:        item.Name,
+			Sale:        int(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int(item.TotalPrice),
+			NmID:        int(item.NmID),
+			Brand:       item.Brand,
+			Status:      int(item.Status),
+		})
+	}
+	if a.DateCreated != nil {
+		o.DateCreated = a.DateCreated.UTC()
+	}
+	return o
+}
+
+// resolvedSchemas - схемы чтения, согласованные со схемами записи из реестра (текст схемы записи -> avro.Schema)
+var resolvedSchemas sync.Map
+
+// decodeWith читает данные, записанные другой совместимой версией схемы (например, с лишним или новым полем)
+func (a Avro) decodeWith(writerSchema string, data []byte) (models.Order, error) {
+	if cached, ok := resolvedSchemas.Load(writerSchema); ok {
+		return a.decodeResolved(cached.(avro.Schema), data)
+	}
+
+	writer, err := avro.Parse(writerSchema)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("avro: схема записи: %w", err)
+	}
+	resolved := avroSchema
+	if writer.Fingerprint() != avroSchema.Fingerprint() {
+		if resolved, err = avro.NewSchemaCompatibility().Resolve(avroSchema, writer); err != nil {
+			return models.Order{}, fmt.Errorf("avro: схема записи несовместима: %w", err)
+		}
+	}
+	resolvedSchemas.Store(writerSchema, resolved)
+	return a.decodeResolved(resolved, data)
+}
+
+func (Avro) decodeResolved(schema avro.Schema, data []byte) (models.Order, error) {
+	var o avroOrder
+	if err := avro.Unmarshal(schema, data, &o); err != nil {
+		return models.Order{}, fmt.Errorf("avro: %w", err)
+	}
+	return o.model(), nil
+}
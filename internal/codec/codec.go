@@ -0,0 +1,70 @@
+// Package codec - форматы тела сообщения с заказом: JSON, Protobuf и Avro,
+// а также wire format Confluent Schema Registry (магический байт + ID схемы)
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+)
+
+// Имена форматов, их принимают конфиг (KAFKA_CODEC, KAFKA_TOPIC_CODECS) и ByName
+const (
+	NameJSON     = "json"
+	NameProtobuf = "protobuf"
+	NameAvro     = "avro"
+)
+
+// ContentTypeHeader - заголовок сообщения Kafka с форматом тела
+const ContentTypeHeader = "content-type"
+
+// ErrUnknown - формат не поддерживается
+var ErrUnknown = errors.New("неизвестный формат сообщения")
+
+// Codec - перевод заказа в байты тела сообщения и обратно
+type Codec interface {
+	Name() string
+	ContentType() string
+	Encode(order models.Order) ([]byte, error)
+	Decode(data []byte) (models.Order, error)
+}
+
+// contentTypes - MIME-типы, по которым выбирается формат, первый - основной
+var contentTypes = map[string][]string{
+	NameJSON:     {"application/json"},
+	NameProtobuf: {"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf"},
+	NameAvro:     {"avro/binary", "application/avro", "application/vnd.apache.avro+binary"},
+}
+
+// ByName возвращает формат по имени
+func ByName(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case NameJSON:
+		return JSON{}, nil
+	case NameProtobuf:
+		return Protobuf{}, nil
+	case NameAvro:
+		return Avro{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknown, name)
+	}
+}
+
+// ByContentType возвращает формат по значению заголовка content-type, параметры (charset и т.п.) игнорируются
+func ByContentType(contentType string) (Codec, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: content-type %q", ErrUnknown, contentType)
+	}
+	for name, types := range contentTypes {
+		for _, t := range types {
+			if mediaType == t {
+				return ByName(name)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: content-type %q", ErrUnknown, contentType)
+}
@@ -0,0 +1,192 @@
+package codec
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testOrder - заказ, в котором заполнены все поля модели
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:    "test123",
+		TrackNumber: "TRACK001",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Ivan", Phone: "+79990000000", Zip: "123456", City: "Moscow",
+			Address: "Street 1", Region: "Moscow region", Email: "ivan@example.com",
+		},
+		Payment: models.Payment{
+			Transaction: "test123", RequestID: "req1", Currency: "RUB", Provider: "bank",
+			Amount: 1000, PaymentDT: 1637907727, Bank: "Sber", DeliveryCost: 200, GoodsTotal: 800, CustomFee: 5,
+		},
+		Items: []models.Item{
+			{ChrtID: 1, TrackNumber: "TRACK001", Price: 100, Rid: "rid1", Name: "Item", Sale: 10, Size: "L", TotalPrice: 90, NmID: 7, Brand: "Brand", Status: 202},
+			{ChrtID: 2, Price: 700, Name: "Other", TotalPrice: 700},
+		},
+		Locale:            "ru",
+		InternalSignature: "sig",
+		CustomerID:        "customer",
+		DeliveryService:   "meest",
+		ShardKey:          "9",
+		SmID:              99,
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:          "1",
+	}
+}
+
+// TestCodecs_RoundTrip проверяет, что каждый формат переводит заказ в байты и обратно без потерь
+func TestCodecs_RoundTrip(t *testing.T) {
+	for _, name := range []string{NameJSON, NameProtobuf, NameAvro} {
+		t.Run(name, func(t *testing.T) {
+			c, err := ByName(name)
+			if !assert.NoError(t, err) {
+				return
+			}
+			data, err := c.Encode(testOrder())
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := c.Decode(data)
+			if assert.NoError(t, err) {
+				assert.Equal(t, testOrder(), got)
+			}
+		})
+	}
+}
+
+// TestByContentType проверяет выбор формата по заголовку content-type
+func TestByContentType(t *testing.T) {
+	tests := map[string]string{
+		"application/json; charset=utf-8": NameJSON,
+		"application/x-protobuf":          NameProtobuf,
+		"avro/binary":                     NameAvro,
+	}
+	for contentType, want := range tests {
+		c, err := ByContentType(contentType)
+		if assert.NoError(t, err, contentType) {
+			assert.Equal(t, want, c.Name())
+		}
+	}
+
+	_, err := ByContentType("text/xml")
+	assert.ErrorIs(t, err, ErrUnknown)
+}
+
+// TestProtobuf_Decode проверяет разбор Protobuf:
+// неизвестные поля (например, из новой версии схемы) пропускаются, поле не того типа - ошибка
+func TestProtobuf_Decode(t *testing.T) {
+	data, _ := Protobuf{}.Encode(testOrder())
+	data = protowire.AppendTag(data, 100, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 42)
+
+	got, err := Protobuf{}.Decode(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test123", got.OrderUID)
+	}
+
+	var bad []byte
+	bad = protowire.AppendTag(bad, 1, protowire.VarintType)
+	bad = protowire.AppendVarint(bad, 1)
+	_, err = Protobuf{}.Decode(bad)
+	assert.Error(t, err)
+
+	_, err = Protobuf{}.Decode([]byte{0xff})
+	assert.Error(t, err)
+}
+
+// TestWire проверяет wire format Confluent поверх реестра в памяти:
+// 1) Avro и Protobuf с префиксом (0x00 + ID схемы) читаются обратно
+// 2) Сообщение без префикса, с неизвестным ID или со схемой другого типа отклоняется
+func TestWire(t *testing.T) {
+	registry := NewMemoryRegistry()
+	avroID := registry.Register(SchemaAvro, AvroSchema)
+	protoID := registry.Register(SchemaProtobuf, "syntax = \"proto3\"; message Order {}")
+
+	for _, w := range []*Wire{
+		NewWire(Avro{}, registry, avroID),
+		NewWire(Protobuf{}, registry, protoID),
+	} {
+		data, err := w.Encode(testOrder())
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, byte(magicByte), data[0])
+
+		got, err := w.Decode(data)
+		if assert.NoError(t, err, w.Name()) {
+			assert.Equal(t, testOrder(), got)
+		}
+	}
+
+	raw, _ := Avro{}.Encode(testOrder())
+	_, err := NewWire(Avro{}, registry, avroID).Decode(raw)
+	assert.ErrorIs(t, err, ErrWireFormat)
+
+	data, _ := NewWire(Avro{}, registry, 99).Encode(testOrder())
+	_, err = NewWire(Avro{}, registry, 0).Decode(data)
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	data, _ = NewWire(Avro{}, registry, protoID).Encode(testOrder())
+	_, err = NewWire(Avro{}, registry, 0).Decode(data)
+	assert.ErrorIs(t, err, ErrWireFormat)
+}
+
+// TestWire_AvroWriterSchema проверяет чтение сообщения, записанного другой совместимой версией схемы:
+// у продюсера в схеме есть лишнее поле, консьюмер его пропускает
+func TestWire_AvroWriterSchema(t *testing.T) {
+	writerSchema := strings.Replace(AvroSchema,
+		`{"name": "oof_shard", "type": "string", "default": ""}`,
+		`{"name": "oof_shard", "type": "string", "default": ""},
+    {"name": "promo_code", "type": "string", "default": ""}`, 1)
+	assert.NotEqual(t, AvroSchema, writerSchema)
+
+	type withPromo struct {
+		avroOrder
+		PromoCode string `avro:"promo_code"`
+	}
+	body, err := avro.Marshal(avro.MustParse(writerSchema), withPromo{avroOrder: toAvro(testOrder()), PromoCode: "SALE"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	registry := NewMemoryRegistry()
+	id := registry.Register(SchemaAvro, writerSchema)
+	data := append([]byte{magicByte, 0, 0, 0, byte(id)}, body...)
+
+	got, err := NewWire(Avro{}, registry, 0).Decode(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testOrder(), got)
+	}
+}
+
+// TestHTTPRegistry проверяет клиент реестра против HTTP API реестра в памяти и кэширование схем
+func TestHTTPRegistry(t *testing.T) {
+	memory := NewMemoryRegistry()
+	id := memory.Register(SchemaAvro, AvroSchema)
+
+	srv := httptest.NewServer(memory.Handler())
+	client := NewHTTPRegistry(srv.URL)
+
+	schema, err := client.SchemaByID(id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Schema{ID: id, Type: SchemaAvro, Schema: AvroSchema}, schema)
+	}
+
+	_, err = client.SchemaByID(42)
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	// Повторный запрос берётся из кэша, даже если реестр уже недоступен
+	srv.Close()
+	_, err = client.SchemaByID(id)
+	assert.NoError(t, err)
+
+	_, err = client.SchemaByID(43)
+	assert.ErrorIs(t, err, ErrRegistryUnavailable)
+}
@@ -0,0 +1,23 @@
+package codec
+
+import (
+	"encoding/json"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+)
+
+// JSON - заказ в JSON, исходный формат сервиса
+type JSON struct{}
+
+func (JSON) Name() string        { return NameJSON }
+func (JSON) ContentType() string { return contentTypes[NameJSON][0] }
+
+func (JSON) Encode(order models.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+func (JSON) Decode(data []byte) (models.Order, error) {
+	var order models.Order
+	err := json.Unmarshal(data, &order)
+	return order, err
+}
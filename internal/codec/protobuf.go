@@ -0,0 +1,317 @@
+package codec
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Protobuf - заказ в Protobuf по схеме schema/order.proto
+// Перевод в модель написан руками поверх protowire, без генерации кода: схема маленькая и меняется редко.
+// Номера полей ниже должны совпадать с order.proto
+type Protobuf struct{}
+
+func (Protobuf) Name() string        { return NameProtobuf }
+func (Protobuf) ContentType() string { return contentTypes[NameProtobuf][0] }
+
+func (Protobuf) Encode(order models.Order) ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, order.OrderUID)
+	b = appendString(b, 2, order.TrackNumber)
+	b = appendString(b, 3, order.Entry)
+	b = appendMessage(b, 4, encodeDelivery(order.Delivery))
+	b = appendMessage(b, 5, encodePayment(order.Payment))
+	for _, item := range order.Items {
+		b = appendMessage(b, 6, encodeItem(item))
+	}
+	b = appendString(b, 7, order.Locale)
+	b = appendString(b, 8, order.InternalSignature)
+	b = appendString(b, 9, order.CustomerID)
+	b = appendString(b, 10, order.DeliveryService)
+	b = appendString(b, 11, order.ShardKey)
+	b = appendInt(b, 12, int64(order.SmID))
+	if !order.DateCreated.IsZero() {
+		// google.protobuf.Timestamp: seconds = 1, nanos = 2
+		var ts []byte
+		ts = appendInt(ts, 1, order.DateCreated.Unix())
+		ts = appendInt(ts, 2, int64(order.DateCreated.Nanosecond()))
+		b = appendMessage(b, 13, ts)
+	}
+	b = appendString(b, 14, order.OofShard)
+	return b, nil
+}
+
+func (Protobuf) Decode(data []byte) (models.Order, error) {
+	var order models.Order
+	d := &pbDecoder{}
+
+	for _, f := range d.fields(data) {
+		switch f.num {
+		case 1:
+			order.OrderUID = d.str(f)
+		case 2:
+			order.TrackNumber = d.str(f)
+		case 3:
+			order.Entry = d.str(f)
+		case 4:
+			order.Delivery = decodeDelivery(d, d.bytes(f))
+		case 5:
+			order.Payment = decodePayment(d, d.bytes(f))
+		case 6:
+			order.Items = append(order.Items, decodeItem(d, d.bytes(f)))
+		case 7:
+			order.Locale = d.str(f)
+		case 8:
+			order.InternalSignature = d.str(f)
+		case 9:
+			order.CustomerID = d.str(f)
+		case 10:
+			order.DeliveryService = d.str(f)
+		case 11:
+			order.ShardKey = d.str(f)
+		case 12:
+			order.SmID = d.int(f)
+		case 13:
+			var sec, nsec int64
+			for _, tf := range d.fields(d.bytes(f)) {
+				switch tf.num {
+				case 1:
+					sec = d.int64(tf)
+				case 2:
+					nsec = d.int64(tf)
+				}
+			}
+			order.DateCreated = time.Unix(sec, nsec).UTC()
+		case 14:
+			order.OofShard = d.str(f)
+		}
+	}
+
+	if d.err != nil {
+		return models.Order{}, fmt.Errorf("protobuf: %w", d.err)
+	}
+	return order, nil
+}
+
+func encodeDelivery(d models.Delivery) []byte {
+	var b []byte
+	b = appendString(b, 1, d.Name)
+	b = appendString(b, 2, d.Phone)
+	b = appendString(b, 3, d.Zip)
+	b = appendString(b, 4, d.City)
+	b = appendString(b, 5, d.Address)
+	b = appendString(b, 6, d.Region)
+	b = appendString(b, 7, d.Email)
+	return b
+}
+
+func decodeDelivery(d *pbDecoder, data []byte) models.Delivery {
+	var delivery models.Delivery
+	for _, f := range d.fields(data) {
+		switch f.num {
+		case 1:
+			delivery.Name = d.str(f)
+		case 2:
+			delivery.Phone = d.str(f)
+		case 3:
+			delivery.Zip = d.str(f)
+		case 4:
+			delivery.City = d.str(f)
+		case 5:
+			delivery.Address = d.str(f)
+		case 6:
+			delivery.Region = d.str(f)
+		case 7:
+			delivery.Email = d.str(f)
+		}
+	}
+	return delivery
+}
+
+func encodePayment(p models.Payment) []byte {
+	var b []byte
+	b = appendString(b, 1, p.Transaction)
+	b = appendString(b, 2, p.RequestID)
+	b = appendString(b, 3, p.Currency)
+	b = appendString(b, 4, p.Provider)
+	b = appendInt(b, 5, int64(p.Amount))
+	b = appendInt(b, 6, p.PaymentDT)
+	b = appendString(b, 7, p.Bank)
+	b = appendInt(b, 8, int64(p.DeliveryCost))
+	b = appendInt(b, 9, int64(p.GoodsTotal))
+	b = appendInt(b, 10, int64(p.CustomFee))
+	return b
+}
+
+func decodePayment(d *pbDecoder, data []byte) models.Payment {
+	var payment models.Payment
+	for _, f := range d.fields(data) {
+		switch f.num {
+		case 1:
+			payment.Transaction = d.str(f)
+		case 2:
+			payment.RequestID = d.str(f)
+		case 3:
+			payment.Currency = d.str(f)
+		case 4:
+			payment.Provider = d.str(f)
+		case 5:
+			payment.Amount = d.int(f)
+		case 6:
+			payment.PaymentDT = d.int64(f)
+		case 7:
+			payment.Bank = d.str(f)
+		case 8:
+			payment.DeliveryCost = d.int(f)
+		case 9:
+			payment.GoodsTotal = d.int(f)
+		case 10:
+			payment.CustomFee = d.int(f)
+		}
+	}
+	return payment
+}
+
+func encodeItem(i models.Item) []byte {
+	var b []byte
+	b = appendInt(b, 1, int64(i.ChrtID))
+	b = appendString(b, 2, i.TrackNumber)
+	b = appendInt(b, 3, int64(i.Price))
+	b = appendString(b, 4, i.Rid)
+	b = appendString(b, 5, i.Name)
+	b = appendInt(b, 6, int64(i.Sale))
+	b = appendString(b, 7, i.Size)
+	b = appendInt(b, 8, int64(i.TotalPrice))
+	b = appendInt(b, 9, int64(i.NmID))
+	b = appendString(b, 10, i.Brand)
+	b = appendInt(b, 11, int64(i.Status))
+	return b
+}
+
+func decodeItem(d *pbDecoder, data []byte) models.Item {
+	var item models.Item
+	for _, f := range d.fields(data) {
+		switch f.num {
+		case 1:
+			item.ChrtID = d.int(f)
+		case 2:
+			item.TrackNumber = d.str(f)
+		case 3:
+			item.Price = d.int(f)
+		case 4:
+			item.Rid = d.str(f)
+		case 5:
+			item.Name = d.str(f)
+		case 6:
+			item.Sale = d.int(f)
+		case 7:
+			item.Size = d.str(f)
+		case 8:
+			item.TotalPrice = d.int(f)
+		case 9:
+			item.NmID = d.int(f)
+		case 10:
+			item.Brand = d.str(f)
+		case 11:
+			item.Status = d.int(f)
+		}
+	}
+	return item
+}
+
+// appendString пишет строковое поле, пустые строки в proto3 не передаются
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendInt пишет целое поле (int64), нули в proto3 не передаются
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendMessage пишет вложенное сообщение
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// pbField - одно поле сообщения: varint или length-delimited значение
+type pbField struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+// pbDecoder запоминает первую ошибку разбора, чтобы не проверять её после каждого поля
+type pbDecoder struct {
+	err error
+}
+
+// fields разбирает сообщение на поля, поля других типов (fixed32/64, группы) пропускаются
+func (d *pbDecoder) fields(b []byte) []pbField {
+	var fields []pbField
+	for len(b) > 0 && d.err == nil {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			d.err = protowire.ParseError(n)
+			break
+		}
+		b = b[n:]
+
+		f := pbField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			d.err = protowire.ParseError(n)
+			break
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func (d *pbDecoder) bytes(f pbField) []byte {
+	if f.typ != protowire.BytesType {
+		d.fail(f, "length-delimited")
+	}
+	return f.bytes
+}
+
+func (d *pbDecoder) str(f pbField) string {
+	return string(d.bytes(f))
+}
+
+func (d *pbDecoder) int64(f pbField) int64 {
+	if f.typ != protowire.VarintType {
+		d.fail(f, "varint")
+	}
+	return int64(f.varint)
+}
+
+func (d *pbDecoder) int(f pbField) int {
+	return int(d.int64(f))
+}
+
+func (d *pbDecoder) fail(f pbField, want string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("поле %d: ожидался тип %s", f.num, want)
+	}
+}
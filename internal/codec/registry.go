@@ -0,0 +1,269 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Типы схем, как в Confluent Schema Registry
+const (
+	SchemaAvro     = "AVRO"
+	SchemaProtobuf = "PROTOBUF"
+	SchemaJSON     = "JSON"
+)
+
+// magicByte - первый байт сообщения в wire format Confluent
+const magicByte = 0
+
+// registryTimeout - таймаут запроса к реестру схем
+const registryTimeout = 5 * time.Second
+
+var (
+	ErrWireFormat          = errors.New("сообщение не в wire format реестра схем")
+	ErrSchemaNotFound      = errors.New("схема не найдена в реестре")
+	ErrRegistryUnavailable = errors.New("реестр схем недоступен") // временная ошибка, сообщение стоит повторить
+)
+
+// Schema - схема из реестра
+type Schema struct {
+	ID     int    `json:"id,omitempty"`
+	Type   string `json:"schemaType,omitempty"` // пусто - AVRO, как в Confluent
+	Schema string `json:"schema"`
+}
+
+// SchemaRegistry - реестр схем: по ID из сообщения отдаёт схему, которой оно записано
+type SchemaRegistry interface {
+	SchemaByID(id int) (Schema, error)
+}
+
+// schemaType - тип схемы в реестре для формата
+func schemaType(name string) string {
+	switch name {
+	case NameAvro:
+		return SchemaAvro
+	case NameProtobuf:
+		return SchemaProtobuf
+	default:
+		return SchemaJSON
+	}
+}
+
+// Wire - формат поверх Codec с префиксом Confluent: 0x00, ID схемы (4 байта big-endian),
+// для Protobuf - индексы сообщения в .proto, затем само тело
+type Wire struct {
+	codec    Codec
+	registry SchemaRegistry
+	schemaID int // ID схемы для Encode
+}
+
+func NewWire(codec Codec, registry SchemaRegistry, schemaID int) *Wire {
+	return &Wire{codec: codec, registry: registry, schemaID: schemaID}
+}
+
+func (w *Wire) Name() string        { return w.codec.Name() }
+func (w *Wire) ContentType() string { return w.codec.ContentType() }
+
+func (w *Wire) Encode(order models.Order) ([]byte, error) {
+	body, err := w.codec.Encode(order)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 5, 6+len(body))
+	b[0] = magicByte
+	binary.BigEndian.PutUint32(b[1:5], uint32(w.schemaID))
+	if w.codec.Name() == NameProtobuf {
+		// Индексы сообщения: 0 - первое сообщение в .proto (Order)
+		b = append(b, 0)
+	}
+	return append(b, body...), nil
+}
+
+func (w *Wire) Decode(data []byte) (models.Order, error) {
+	if len(data) < 5 || data[0] != magicByte {
+		return models.Order{}, ErrWireFormat
+	}
+	id := int(binary.BigEndian.Uint32(data[1:5]))
+	body := data[5:]
+
+	schema, err := w.registry.SchemaByID(id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if got, want := schemaTypeOrAvro(schema.Type), schemaType(w.codec.Name()); got != want {
+		return models.Order{}, fmt.Errorf("%w: схема %d типа %s, ожидался %s", ErrWireFormat, id, got, want)
+	}
+
+	if w.codec.Name() == NameProtobuf {
+		if body, err = skipMessageIndexes(body); err != nil {
+			return models.Order{}, err
+		}
+	}
+	if a, ok := w.codec.(Avro); ok {
+		return a.decodeWith(schema.Schema, body)
+	}
+	return w.codec.Decode(body)
+}
+
+// skipMessageIndexes пропускает индексы сообщения Protobuf: число индексов и сами индексы (zigzag varint)
+// Поддерживается только первое сообщение схемы - Order
+func skipMessageIndexes(b []byte) ([]byte, error) {
+	count, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return nil, fmt.Errorf("%w: индексы сообщения", ErrWireFormat)
+	}
+	b = b[n:]
+	if count == 0 {
+		return b, nil
+	}
+	for i := 0; i < int(protowire.DecodeZigZag(count)); i++ {
+		index, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, fmt.Errorf("%w: индексы сообщения", ErrWireFormat)
+		}
+		if protowire.DecodeZigZag(index) != 0 {
+			return nil, fmt.Errorf("%w: поддерживается только сообщение Order", ErrWireFormat)
+		}
+		b = b[n:]
+	}
+	return b, nil
+}
+
+func schemaTypeOrAvro(t string) string {
+	if t == "" {
+		return SchemaAvro
+	}
+	return t
+}
+
+// MemoryRegistry - реестр схем в памяти, заменяет Schema Registry в тестах и локально
+// Handler отдаёт те же ручки, что и Confluent, поэтому его можно поднять через httptest для HTTPRegistry
+type MemoryRegistry struct {
+	mu      sync.RWMutex
+	schemas []Schema // ID = индекс + 1
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{}
+}
+
+// Register добавляет схему и возвращает её ID, одинаковая схема получает тот же ID
+func (r *MemoryRegistry) Register(schemaType, schema string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.schemas {
+		if s.Type == schemaType && s.Schema == schema {
+			return s.ID
+		}
+	}
+	id := len(r.schemas) + 1
+	r.schemas = append(r.schemas, Schema{ID: id, Type: schemaType, Schema: schema})
+	return id
+}
+
+func (r *MemoryRegistry) SchemaByID(id int) (Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id < 1 || id > len(r.schemas) {
+		return Schema{}, fmt.Errorf("%w: %d", ErrSchemaNotFound, id)
+	}
+	return r.schemas[id-1], nil
+}
+
+// Handler - HTTP API реестра: GET /schemas/ids/{id} и POST /subjects/{subject}/versions
+func (r *MemoryRegistry) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /schemas/ids/{id}", func(w http.ResponseWriter, req *http.Request) {
+		id, err := strconv.Atoi(req.PathValue("id"))
+		if err != nil {
+			http.Error(w, "bad id", http.StatusBadRequest)
+			return
+		}
+		schema, err := r.SchemaByID(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if schema.Type == SchemaAvro {
+			schema.Type = ""
+		}
+		schema.ID = 0
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		json.NewEncoder(w).Encode(schema)
+	})
+
+	mux.HandleFunc("POST /subjects/{subject}/versions", func(w http.ResponseWriter, req *http.Request) {
+		var schema Schema
+		if err := json.NewDecoder(req.Body).Decode(&schema); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id := r.Register(schemaTypeOrAvro(schema.Type), schema.Schema)
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		json.NewEncoder(w).Encode(map[string]int{"id": id})
+	})
+
+	return mux
+}
+
+// HTTPRegistry - клиент Schema Registry, схемы по ID неизменяемы, поэтому кэшируются навсегда
+type HTTPRegistry struct {
+	url    string
+	client *http.Client
+
+	mu    sync.RWMutex
+	cache map[int]Schema
+}
+
+func NewHTTPRegistry(url string) *HTTPRegistry {
+	return &HTTPRegistry{
+		url:    strings.TrimRight(url, "/"),
+		client: &http.Client{Timeout: registryTimeout},
+		cache:  make(map[int]Schema),
+	}
+}
+
+func (r *HTTPRegistry) SchemaByID(id int) (Schema, error) {
+	r.mu.RLock()
+	schema, ok := r.cache[id]
+	r.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	resp, err := r.client.Get(fmt.Sprintf("%s/schemas/ids/%d", r.url, id))
+	if err != nil {
+		return Schema{}, fmt.Errorf("%w: %v", ErrRegistryUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return Schema{}, fmt.Errorf("%w: %d", ErrSchemaNotFound, id)
+	case resp.StatusCode != http.StatusOK:
+		return Schema{}, fmt.Errorf("%w: ответ %s", ErrRegistryUnavailable, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&schema); err != nil {
+		return Schema{}, fmt.Errorf("ошибка разбора ответа реестра схем: %w", err)
+	}
+	schema.ID = id
+	schema.Type = schemaTypeOrAvro(schema.Type)
+
+	r.mu.Lock()
+	r.cache[id] = schema
+	r.mu.Unlock()
+	return schema, nil
+}
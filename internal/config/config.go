@@ -1,7 +1,17 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
@@ -12,11 +22,341 @@ type Config struct {
 	HttpServer HttpServer     `yaml:"http_server"`
 	Database   DatabaseConfig `yaml:"database"`
 	Kafka      KafkaConfig    `yaml:"kafka"`
+	Log        LogConfig      `yaml:"log"`
+	Tracing    TracingConfig  `yaml:"tracing"`
+}
+
+// TracingConfig - экспорт трейсов OpenTelemetry
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`                 // none, otlp или file
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`                         // URL коллектора OTLP/HTTP, пусто - http://localhost:4318
+	File        string  `yaml:"file" env:"TRACING_FILE" env-default:"traces.jsonl"`                 // куда писать спаны при exporter=file
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`            // доля трейсов, которые начинаются у нас
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" env-default:"wb-demo-service"` // service.name в ресурсе
+}
+
+// validate проверяет экспортёр и долю семплирования
+func (c *TracingConfig) validate() error {
+	if !oneOf(c.Exporter, "none", "otlp", "file") {
+		return fmt.Errorf("TRACING_EXPORTER должен быть none, otlp или file, получено %q", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO должен быть от 0 до 1, получено %v", c.SampleRatio)
+	}
+	if c.Exporter == "file" && c.File == "" {
+		return fmt.Errorf("TRACING_FILE обязателен при TRACING_EXPORTER=file")
+	}
+	if c.Endpoint != "" {
+		if u, err := url.Parse(c.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT должен быть URL вида http://host:port, получено %q", c.Endpoint)
+		}
+	}
+	return nil
+}
+
+// LogConfig - уровень и формат логов
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" env-default:"info"`   // debug, info, warn, error
+	Format string `yaml:"format" env:"LOG_FORMAT" env-default:"json"` // json или text
+}
+
+// validate проверяет уровень и формат логов
+func (c *LogConfig) validate() error {
+	if !oneOf(c.Level, "debug", "info", "warn", "error") {
+		return fmt.Errorf("LOG_LEVEL должен быть debug, info, warn или error, получено %q", c.Level)
+	}
+	if !oneOf(c.Format, "json", "text") {
+		return fmt.Errorf("LOG_FORMAT должен быть json или text, получено %q", c.Format)
+	}
+	return nil
 }
 
 // HttpServer - конфигурация HTTP-сервера (порт берётся из env/конфига)
 type HttpServer struct {
-	Port int `yaml:"port" env:"HTTP_PORT"`
+	Port          int               `yaml:"port" env:"HTTP_PORT"`
+	ReadyTimeout  time.Duration     `yaml:"ready_timeout" env:"HTTP_READY_TIMEOUT" env-default:"2s"`                                                                                                                         // таймаут каждой проверки /readyz
+	ShutdownDelay time.Duration     `yaml:"shutdown_delay" env:"HTTP_SHUTDOWN_DELAY" env-default:"3s"`                                                                                                                       // /readyz уже 503, а сервер ещё принимает запросы
+	DBTimeout     time.Duration     `yaml:"db_timeout" env:"HTTP_DB_TIMEOUT" env-default:"3s"`                                                                                                                               // на запросы к БД из обработчика, 0 - без таймаута
+	CacheControl  []string          `yaml:"cache_control" env:"HTTP_CACHE_CONTROL" env-separator:";" env-default:"/order/{id}=private, no-cache;/order/{id}/items=private, no-cache;/schema/order.json=public, max-age=300"` // шаблон=значение Cache-Control успешных ответов
+	CORS          CORSConfig        `yaml:"cors"`
+	Compression   CompressionConfig `yaml:"compression"`
+	Auth          AuthConfig        `yaml:"auth"`
+	RateLimit     RateLimitConfig   `yaml:"rate_limit"`
+}
+
+// ParseCacheControl разбирает HTTP_CACHE_CONTROL: шаблон маршрута без метода (/order/{id}) - значение Cache-Control
+func (c HttpServer) ParseCacheControl() (map[string]string, error) {
+	routes := make(map[string]string, len(c.CacheControl))
+	for _, entry := range c.CacheControl {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		route, value = strings.TrimSpace(route), strings.TrimSpace(value)
+		if !ok || !strings.HasPrefix(route, "/") || value == "" {
+			return nil, fmt.Errorf("HTTP_CACHE_CONTROL: запись должна быть вида /путь=директивы, получено %q", entry)
+		}
+		routes[route] = value
+	}
+	return routes, nil
+}
+
+// CompressionConfig - сжатие ответов gzip или zstd по Accept-Encoding клиента
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled" env:"HTTP_COMPRESSION_ENABLED" env-default:"true"`
+	MinSize int  `yaml:"min_size" env:"HTTP_COMPRESSION_MIN_SIZE" env-default:"1024"` // байт, ответы меньше отдаются как есть
+}
+
+// validate проверяет порог сжатия
+func (c *CompressionConfig) validate() error {
+	if c.MinSize < 0 {
+		return fmt.Errorf("HTTP_COMPRESSION_MIN_SIZE не может быть отрицательным, получено %d", c.MinSize)
+	}
+	return nil
+}
+
+// RateLimitConfig - ограничение частоты запросов к API для каждого клиента (token bucket)
+// Клиент - ключ API или sub токена, без аутентификации - IP-адрес
+type RateLimitConfig struct {
+	Enabled        bool     `yaml:"enabled" env:"RATE_LIMIT_ENABLED" env-default:"false"`
+	RPS            float64  `yaml:"rps" env:"RATE_LIMIT_RPS" env-default:"10"`                                           // запросов в секунду на клиента
+	Burst          int      `yaml:"burst" env:"RATE_LIMIT_BURST" env-default:"20"`                                       // запросов подряд сверх RPS
+	Routes         []string `yaml:"routes" env:"RATE_LIMIT_ROUTES" env-separator:"," env-default:"/healthz=0,/readyz=0"` // шаблон=rps:burst, rps 0 - без ограничения
+	TrustedProxies []string `yaml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES" env-separator:","`                  // IP или CIDR прокси, которым верим в X-Forwarded-For
+	MaxClients     int      `yaml:"max_clients" env:"RATE_LIMIT_MAX_CLIENTS" env-default:"10000"`                        // клиентов в памяти на маршрут, давно не заходившие вытесняются
+}
+
+// RouteLimit - лимит маршрута, RPS 0 - без ограничения
+type RouteLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// ParseRoutes разбирает RATE_LIMIT_ROUTES: шаблон маршрута без метода (/order/{id}) - лимит
+func (c RateLimitConfig) ParseRoutes() (map[string]RouteLimit, error) {
+	routes := make(map[string]RouteLimit, len(c.Routes))
+	for _, entry := range c.Routes {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES: запись должна быть вида /путь=rps:burst или /путь=0, получено %q", entry)
+		}
+		rps, burst, _ := strings.Cut(limit, ":")
+		var l RouteLimit
+		var err error
+		if l.RPS, err = strconv.ParseFloat(rps, 64); err != nil || l.RPS < 0 {
+			return nil, fmt.Errorf("RATE_LIMIT_ROUTES: некорректный rps у %s: %q", route, rps)
+		}
+		if l.RPS > 0 {
+			if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst < 1 {
+				return nil, fmt.Errorf("RATE_LIMIT_ROUTES: у %s нужен burst >= 1, получено %q", route, burst)
+			}
+		}
+		routes[route] = l
+	}
+	return routes, nil
+}
+
+// ParseTrustedProxies разбирает RATE_LIMIT_TRUSTED_PROXIES, отдельный IP - сеть из одного адреса
+func (c RateLimitConfig) ParseTrustedProxies() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, p := range c.TrustedProxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(p); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_TRUSTED_PROXIES: ожидается IP или CIDR, получено %q", p)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// validate проверяет лимиты и список прокси
+func (c *RateLimitConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RPS <= 0 || c.Burst < 1 {
+		return fmt.Errorf("RATE_LIMIT_RPS должен быть больше 0, а RATE_LIMIT_BURST - не меньше 1, получено %v и %d", c.RPS, c.Burst)
+	}
+	if c.MaxClients < 1 {
+		return fmt.Errorf("RATE_LIMIT_MAX_CLIENTS должен быть >= 1, получено %d", c.MaxClients)
+	}
+	if _, err := c.ParseRoutes(); err != nil {
+		return err
+	}
+	_, err := c.ParseTrustedProxies()
+	return err
+}
+
+// AuthConfig - аутентификация клиентов API: статические ключи и JWT
+// Выключенная аутентификация пропускает все запросы без проверки скоупов
+type AuthConfig struct {
+	Enabled     bool          `yaml:"enabled" env:"AUTH_ENABLED" env-default:"false"`
+	APIKeys     []string      `yaml:"api_keys" env:"AUTH_API_KEYS" env-separator:";"`        // имя:sha256-hex ключа:скоупы через пробел
+	JWTSecret   string        `yaml:"jwt_secret" env:"AUTH_JWT_SECRET"`                      // общий секрет HS256, пусто - HS256 не принимается
+	JWKSFile    string        `yaml:"jwks_file" env:"AUTH_JWKS_FILE"`                        // открытые ключи RS256 из файла JWKS
+	JWKSURL     string        `yaml:"jwks_url" env:"AUTH_JWKS_URL"`                          // или по URL, перечитываются при неизвестном kid
+	JWKSRefresh time.Duration `yaml:"jwks_refresh" env:"AUTH_JWKS_REFRESH" env-default:"1m"` // не чаще раза в этот интервал
+	Issuer      string        `yaml:"issuer" env:"AUTH_JWT_ISSUER"`                          // ожидаемый iss, пусто - не проверяется
+	Audience    string        `yaml:"audience" env:"AUTH_JWT_AUDIENCE"`                      // ожидаемый aud, пусто - не проверяется
+	Leeway      time.Duration `yaml:"leeway" env:"AUTH_JWT_LEEWAY" env-default:"30s"`        // допуск расхождения часов для exp и nbf
+
+	APIKeyRoles map[string]string `yaml:"api_key_roles" env:"AUTH_API_KEY_ROLES"` // роль ключа по имени: имя:роль,имя:роль; у JWT - claim roles или role
+	FieldPolicy string            `yaml:"field_policy" env:"AUTH_FIELD_POLICY"`   // JSON-политика маскирования полей по ролям, пусто - ответы без маскирования
+}
+
+// APIKey - статический ключ клиента, хранится только SHA-256 ключа
+type APIKey struct {
+	Name   string
+	Hash   []byte
+	Scopes []string
+	Role   string // из AUTH_API_KEY_ROLES, пусто - без роли
+}
+
+// Scopes - скоупы доступа к API; admin разрешает всё
+var Scopes = []string{"orders:read", "orders:write", "admin"}
+
+// ParseAPIKeys разбирает AUTH_API_KEYS: записи через ";", каждая - имя:sha256-hex:скоупы через пробел
+func (c AuthConfig) ParseAPIKeys() ([]APIKey, error) {
+	keys := make([]APIKey, 0, len(c.APIKeys))
+	for _, entry := range c.APIKeys {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Саму запись в ошибку не пишем: по ошибке туда мог попасть ключ, а не его хэш
+		name, rest, _ := strings.Cut(entry, ":")
+		hash, scopes, ok := strings.Cut(rest, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("AUTH_API_KEYS: запись должна быть вида имя:sha256:скоупы")
+		}
+		sum, err := hex.DecodeString(hash)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("AUTH_API_KEYS: у ключа %s должен быть SHA-256 в hex (64 символа)", name)
+		}
+		key := APIKey{Name: name, Hash: sum, Scopes: strings.Fields(scopes), Role: c.APIKeyRoles[name]}
+		if len(key.Scopes) == 0 {
+			return nil, fmt.Errorf("AUTH_API_KEYS: у ключа %s нет скоупов", name)
+		}
+		for _, scope := range key.Scopes {
+			if !oneOf(scope, Scopes...) {
+				return nil, fmt.Errorf("AUTH_API_KEYS: неизвестный скоуп %q у ключа %s", scope, name)
+			}
+		}
+		keys = append(keys, key)
+	}
+	for name := range c.APIKeyRoles {
+		if !slices.ContainsFunc(keys, func(k APIKey) bool { return k.Name == name }) {
+			return nil, fmt.Errorf("AUTH_API_KEY_ROLES: ключа %s нет в AUTH_API_KEYS", name)
+		}
+	}
+	return keys, nil
+}
+
+// validate проверяет ключи и источники проверки JWT
+func (c *AuthConfig) validate() error {
+	keys, err := c.ParseAPIKeys()
+	if err != nil {
+		return err
+	}
+	if c.JWKSFile != "" && c.JWKSURL != "" {
+		return fmt.Errorf("AUTH_JWKS_FILE и AUTH_JWKS_URL нельзя задавать вместе")
+	}
+	if c.JWKSURL != "" {
+		if u, err := url.Parse(c.JWKSURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("AUTH_JWKS_URL должен быть URL вида https://host/path, получено %q", c.JWKSURL)
+		}
+		if c.JWKSRefresh <= 0 {
+			return fmt.Errorf("AUTH_JWKS_REFRESH должен быть больше 0, получено %s", c.JWKSRefresh)
+		}
+	}
+	if c.JWTSecret != "" && len(c.JWTSecret) < 32 {
+		return fmt.Errorf("AUTH_JWT_SECRET должен быть не короче 32 байт")
+	}
+	if c.FieldPolicy != "" {
+		if _, err := os.Stat(c.FieldPolicy); err != nil {
+			return fmt.Errorf("файл AUTH_FIELD_POLICY недоступен: %w", err)
+		}
+	}
+	if c.Leeway < 0 {
+		return fmt.Errorf("AUTH_JWT_LEEWAY не может быть отрицательным, получено %s", c.Leeway)
+	}
+	if c.Enabled && len(keys) == 0 && c.JWTSecret == "" && c.JWKSFile == "" && c.JWKSURL == "" {
+		return fmt.Errorf("AUTH_ENABLED=true, но не задан ни AUTH_API_KEYS, ни AUTH_JWT_SECRET, ни JWKS")
+	}
+	return nil
+}
+
+// CORSConfig - какие сайты могут обращаться к API из браузера
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" env-separator:"," env-default:"*"` // * - любой источник
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" env-separator:"," env-default:"GET,HEAD"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" env-separator:"," env-default:"Accept,Content-Type,Authorization,X-API-Key,X-Request-ID,If-None-Match"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS" env-separator:"," env-default:"X-Request-ID,ETag,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset"` // видны скрипту в ответе
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" env-default:"false"`                                                                                           // cookie и Authorization из браузера
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" env-default:"10m"`                                                                                                                 // сколько браузер кэширует ответ на preflight
+}
+
+// validate проверяет источники и методы CORS
+func (c *CORSConfig) validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("CORS_ALLOW_CREDENTIALS=true нельзя сочетать с CORS_ALLOWED_ORIGINS=*, перечислите источники явно")
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS: источник должен быть вида https://host[:port], получено %q", origin)
+		}
+	}
+	for _, method := range c.AllowedMethods {
+		if method == "" || strings.ToUpper(method) != method {
+			return fmt.Errorf("CORS_ALLOWED_METHODS: метод должен быть в верхнем регистре, получено %q", method)
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("CORS_MAX_AGE не может быть отрицательным, получено %s", c.MaxAge)
+	}
+	return nil
+}
+
+// validate проверяет таймауты проверок готовности и остановки
+func (c *HttpServer) validate() error {
+	if c.ReadyTimeout <= 0 {
+		return fmt.Errorf("HTTP_READY_TIMEOUT должен быть больше 0, получено %s", c.ReadyTimeout)
+	}
+	if c.ShutdownDelay < 0 {
+		return fmt.Errorf("HTTP_SHUTDOWN_DELAY не может быть отрицательным, получено %s", c.ShutdownDelay)
+	}
+	if c.DBTimeout < 0 {
+		return fmt.Errorf("HTTP_DB_TIMEOUT не может быть отрицательным, получено %s", c.DBTimeout)
+	}
+	if _, err := c.ParseCacheControl(); err != nil {
+		return err
+	}
+	if err := c.Compression.validate(); err != nil {
+		return err
+	}
+	if err := c.CORS.validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	return c.Auth.validate()
 }
 
 // DatabaseConfig - настройки подключения к PostgreSQL
@@ -29,14 +369,229 @@ type DatabaseConfig struct {
 	DBName   string `yaml:"dbname" env:"POSTGRES_DB"`
 }
 
-// KafkaConfig - настройки брокера Kafka (адрес, топик, group ID)
-// Значения приходят из env/конфига через cleanenv
+// KafkaConfig - настройки брокеров Kafka (адреса, топик, group ID, безопасность, тюнинг reader/writer)
+// и пула воркеров консьюмера. Значения приходят из env/конфига через cleanenv
 type KafkaConfig struct {
-	Broker    string `yaml:"broker" env:"KAFKA_BROKER"`
-	Zookeeper string `yaml:"zookeeper" env:"KAFKA_ZOOKEEPER"`
-	Topic     string `yaml:"topic" env:"KAFKA_TOPIC"`
-	GroupID   string `yaml:"groupID" env:"KAFKA_GROUP_ID"`
+	Brokers   []string `yaml:"brokers" env:"KAFKA_BROKERS" env-separator:","`
+	Broker    string   `yaml:"broker" env:"KAFKA_BROKER"` // устаревшее: один брокер, если KAFKA_BROKERS не задан
+	Zookeeper string   `yaml:"zookeeper" env:"KAFKA_ZOOKEEPER"`
+	Topic     string   `yaml:"topic" env:"KAFKA_TOPIC"`
+	GroupID   string   `yaml:"groupID" env:"KAFKA_GROUP_ID"`
 	// Commit    bool   `yaml:"commit"`
+
+	SASL KafkaSASL `yaml:"sasl"`
+	TLS  KafkaTLS  `yaml:"tls"`
+
+	// Reader
+	StartOffset       string        `yaml:"start_offset" env:"KAFKA_START_OFFSET" env-default:"first"`          // first или last при первом запуске группы
+	MinBytes          int           `yaml:"min_bytes" env:"KAFKA_MIN_BYTES" env-default:"1"`                    // минимум байт в ответе fetch
+	MaxBytes          int           `yaml:"max_bytes" env:"KAFKA_MAX_BYTES" env-default:"10485760"`             // максимум байт в ответе fetch
+	MaxWait           time.Duration `yaml:"max_wait" env:"KAFKA_MAX_WAIT" env-default:"10s"`                    // сколько брокер копит MinBytes
+	SessionTimeout    time.Duration `yaml:"session_timeout" env:"KAFKA_SESSION_TIMEOUT" env-default:"30s"`      // таймаут сессии в группе
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env:"KAFKA_HEARTBEAT_INTERVAL" env-default:"3s"` // период heartbeat в группе
+
+	// Writer
+	Compression        string        `yaml:"compression" env:"KAFKA_COMPRESSION" env-default:"none"`                  // none, gzip, snappy, lz4, zstd
+	RequiredAcks       string        `yaml:"required_acks" env:"KAFKA_REQUIRED_ACKS" env-default:"one"`               // none, one, all
+	WriterBatchSize    int           `yaml:"writer_batch_size" env:"KAFKA_WRITER_BATCH_SIZE" env-default:"100"`       // сообщений в одном запросе
+	WriterBatchBytes   int64         `yaml:"writer_batch_bytes" env:"KAFKA_WRITER_BATCH_BYTES" env-default:"1048576"` // байт в одном запросе
+	WriterBatchTimeout time.Duration `yaml:"writer_batch_timeout" env:"KAFKA_WRITER_BATCH_TIMEOUT" env-default:"1s"`  // сколько копить неполную пачку
+
+	Workers      int           `yaml:"workers" env:"KAFKA_WORKERS" env-default:"1"`                 // число параллельных воркеров
+	Ordering     string        `yaml:"ordering" env:"KAFKA_ORDERING" env-default:"partition"`       // partition или key (order_uid)
+	DrainTimeout time.Duration `yaml:"drain_timeout" env:"KAFKA_DRAIN_TIMEOUT" env-default:"10s"`   // дообработка при остановке
+	BatchSize    int           `yaml:"batch_size" env:"KAFKA_BATCH_SIZE" env-default:"1"`           // заказов в одной транзакции
+	BatchTimeout time.Duration `yaml:"batch_timeout" env:"KAFKA_BATCH_TIMEOUT" env-default:"100ms"` // сколько добирать пачку
+
+	StatusTopic   string        `yaml:"status_topic" env:"KAFKA_STATUS_TOPIC"`                       // смена статусов, пусто - не читаем
+	DeleteTopic   string        `yaml:"delete_topic" env:"KAFKA_DELETE_TOPIC"`                       // удаления, пусто - не читаем
+	RetryTopic    string        `yaml:"retry_topic" env:"KAFKA_RETRY_TOPIC"`                         // повтор заказов из DLQ, пусто - не читаем
+	RetryAttempts int           `yaml:"retry_attempts" env:"KAFKA_RETRY_ATTEMPTS" env-default:"3"`   // попыток при временной ошибке
+	RetryBackoff  time.Duration `yaml:"retry_backoff" env:"KAFKA_RETRY_BACKOFF" env-default:"200ms"` // пауза перед повтором, растёт с номером попытки
+	DedupTTL      time.Duration `yaml:"dedup_ttl" env:"KAFKA_DEDUP_TTL" env-default:"168h"`          // сколько помнить обработанные события, 0 - без дедупликации
+
+	// Предохранитель БД: после BreakerThreshold ошибок сохранения подряд чтение останавливается,
+	// база пингуется раз в BreakerProbeInterval до восстановления
+	BreakerThreshold     int           `yaml:"breaker_threshold" env:"KAFKA_BREAKER_THRESHOLD" env-default:"5"` // 0 - без предохранителя
+	BreakerProbeInterval time.Duration `yaml:"breaker_probe_interval" env:"KAFKA_BREAKER_PROBE_INTERVAL" env-default:"5s"`
+
+	Codec             string            `yaml:"codec" env:"KAFKA_CODEC" env-default:"json"`          // формат тела без заголовка content-type: json, protobuf, avro
+	TopicCodecs       map[string]string `yaml:"topic_codecs" env:"KAFKA_TOPIC_CODECS"`               // формат по топику: topic:codec,topic:codec
+	SchemaRegistryURL string            `yaml:"schema_registry_url" env:"KAFKA_SCHEMA_REGISTRY_URL"` // задан - protobuf/avro в wire format Confluent
+	ValidateSchema    bool              `yaml:"validate_schema" env:"KAFKA_VALIDATE_SCHEMA"`         // проверять JSON заказа по JSON Schema до декодирования
+
+	StoredTopic    string        `yaml:"stored_topic" env:"KAFKA_STORED_TOPIC" env-default:"orders.stored"` // топик событий outbox
+	OutboxInterval time.Duration `yaml:"outbox_interval" env:"OUTBOX_INTERVAL" env-default:"1s"`            // период опроса outbox
+}
+
+// KafkaSASL - аутентификация SASL, пустой Mechanism - без аутентификации
+type KafkaSASL struct {
+	Mechanism string `yaml:"mechanism" env:"KAFKA_SASL_MECHANISM"` // PLAIN, SCRAM-SHA-256, SCRAM-SHA-512
+	Username  string `yaml:"username" env:"KAFKA_SASL_USERNAME"`
+	Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD"`
+}
+
+// KafkaTLS - шифрование соединения с брокерами
+// CertFile/KeyFile задаются вместе для mTLS, CAFile - если сертификат брокера подписан своим CA
+type KafkaTLS struct {
+	Enabled            bool   `yaml:"enabled" env:"KAFKA_TLS_ENABLED"`
+	CAFile             string `yaml:"ca_file" env:"KAFKA_TLS_CA_FILE"`
+	CertFile           string `yaml:"cert_file" env:"KAFKA_TLS_CERT_FILE"`
+	KeyFile            string `yaml:"key_file" env:"KAFKA_TLS_KEY_FILE"`
+	ServerName         string `yaml:"server_name" env:"KAFKA_TLS_SERVER_NAME"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env:"KAFKA_TLS_INSECURE_SKIP_VERIFY"`
+}
+
+// validate - проверяет настройки Kafka, которые нельзя молча заменить значениями по умолчанию
+func (c *KafkaConfig) validate() error {
+	// Обратная совместимость: один брокер из KAFKA_BROKER
+	if len(c.Brokers) == 0 && c.Broker != "" {
+		c.Brokers = []string{c.Broker}
+	}
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("не задан ни один брокер (KAFKA_BROKERS)")
+	}
+	for _, b := range c.Brokers {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			return fmt.Errorf("некорректный адрес брокера %q: %w", b, err)
+		}
+	}
+
+	if err := c.SASL.validate(); err != nil {
+		return err
+	}
+	if err := c.TLS.validate(); err != nil {
+		return err
+	}
+
+	if !oneOf(c.StartOffset, "first", "last") {
+		return fmt.Errorf("KAFKA_START_OFFSET должен быть first или last, получено %q", c.StartOffset)
+	}
+	if c.MinBytes < 1 || c.MaxBytes < c.MinBytes {
+		return fmt.Errorf("должно быть 1 <= KAFKA_MIN_BYTES <= KAFKA_MAX_BYTES, получено %d и %d", c.MinBytes, c.MaxBytes)
+	}
+	if c.MaxWait <= 0 {
+		return fmt.Errorf("KAFKA_MAX_WAIT должен быть положительным, получено %s", c.MaxWait)
+	}
+	if c.HeartbeatInterval <= 0 || c.SessionTimeout <= c.HeartbeatInterval {
+		return fmt.Errorf("должно быть 0 < KAFKA_HEARTBEAT_INTERVAL < KAFKA_SESSION_TIMEOUT, получено %s и %s", c.HeartbeatInterval, c.SessionTimeout)
+	}
+
+	if !oneOf(c.Compression, "none", "gzip", "snappy", "lz4", "zstd") {
+		return fmt.Errorf("KAFKA_COMPRESSION должен быть none, gzip, snappy, lz4 или zstd, получено %q", c.Compression)
+	}
+	if !oneOf(c.RequiredAcks, "none", "one", "all") {
+		return fmt.Errorf("KAFKA_REQUIRED_ACKS должен быть none, one или all, получено %q", c.RequiredAcks)
+	}
+	if c.WriterBatchSize < 1 || c.WriterBatchBytes < 1 || c.WriterBatchTimeout <= 0 {
+		return fmt.Errorf("KAFKA_WRITER_BATCH_SIZE, KAFKA_WRITER_BATCH_BYTES и KAFKA_WRITER_BATCH_TIMEOUT должны быть положительными")
+	}
+
+	if c.Workers < 1 {
+		return fmt.Errorf("KAFKA_WORKERS должен быть >= 1, получено %d", c.Workers)
+	}
+	if !oneOf(c.Ordering, "partition", "key") {
+		return fmt.Errorf("KAFKA_ORDERING должен быть partition или key, получено %q", c.Ordering)
+	}
+	if c.DrainTimeout <= 0 {
+		return fmt.Errorf("KAFKA_DRAIN_TIMEOUT должен быть положительным, получено %s", c.DrainTimeout)
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("KAFKA_BATCH_SIZE должен быть >= 1, получено %d", c.BatchSize)
+	}
+	if c.BatchTimeout <= 0 {
+		return fmt.Errorf("KAFKA_BATCH_TIMEOUT должен быть положительным, получено %s", c.BatchTimeout)
+	}
+	if len(c.ConsumeTopics()) > 1 && c.GroupID == "" {
+		return fmt.Errorf("для чтения нескольких топиков нужен KAFKA_GROUP_ID")
+	}
+	if c.RetryAttempts < 1 {
+		return fmt.Errorf("KAFKA_RETRY_ATTEMPTS должен быть >= 1, получено %d", c.RetryAttempts)
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("KAFKA_RETRY_BACKOFF не может быть отрицательным, получено %s", c.RetryBackoff)
+	}
+	if c.DedupTTL < 0 {
+		return fmt.Errorf("KAFKA_DEDUP_TTL не может быть отрицательным, получено %s", c.DedupTTL)
+	}
+	if c.BreakerThreshold < 0 {
+		return fmt.Errorf("KAFKA_BREAKER_THRESHOLD не может быть отрицательным, получено %d", c.BreakerThreshold)
+	}
+	if c.BreakerThreshold > 0 && c.BreakerProbeInterval <= 0 {
+		return fmt.Errorf("KAFKA_BREAKER_PROBE_INTERVAL должен быть больше нуля, получено %s", c.BreakerProbeInterval)
+	}
+	if !oneOf(c.Codec, codecs...) {
+		return fmt.Errorf("KAFKA_CODEC должен быть json, protobuf или avro, получено %q", c.Codec)
+	}
+	for topic, codec := range c.TopicCodecs {
+		if !oneOf(codec, codecs...) {
+			return fmt.Errorf("KAFKA_TOPIC_CODECS: формат топика %s должен быть json, protobuf или avro, получено %q", topic, codec)
+		}
+	}
+	if c.SchemaRegistryURL != "" {
+		if u, err := url.Parse(c.SchemaRegistryURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("некорректный KAFKA_SCHEMA_REGISTRY_URL %q", c.SchemaRegistryURL)
+		}
+	}
+	if c.StoredTopic == "" {
+		return fmt.Errorf("KAFKA_STORED_TOPIC не задан")
+	}
+	if c.OutboxInterval <= 0 {
+		return fmt.Errorf("OUTBOX_INTERVAL должен быть положительным, получено %s", c.OutboxInterval)
+	}
+	return nil
+}
+
+// codecs - поддерживаемые форматы тела сообщения
+var codecs = []string{"json", "protobuf", "avro"}
+
+// ConsumeTopics - все топики, которые читает консьюмер, без пустых и повторов
+func (c KafkaConfig) ConsumeTopics() []string {
+	var topics []string
+	for _, t := range []string{c.Topic, c.StatusTopic, c.DeleteTopic, c.RetryTopic} {
+		if t != "" && !slices.Contains(topics, t) {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// validate - механизм SASL должен быть известен, а логин и пароль заданы
+func (s KafkaSASL) validate() error {
+	if s.Mechanism == "" {
+		return nil
+	}
+	if !oneOf(s.Mechanism, "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512") {
+		return fmt.Errorf("KAFKA_SASL_MECHANISM должен быть PLAIN, SCRAM-SHA-256 или SCRAM-SHA-512, получено %q", s.Mechanism)
+	}
+	if s.Username == "" || s.Password == "" {
+		return fmt.Errorf("для SASL %s нужны KAFKA_SASL_USERNAME и KAFKA_SASL_PASSWORD", s.Mechanism)
+	}
+	return nil
+}
+
+// validate - файлы сертификатов должны существовать, сертификат и ключ задаются парой
+func (t KafkaTLS) validate() error {
+	if !t.Enabled {
+		return nil
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("KAFKA_TLS_CERT_FILE и KAFKA_TLS_KEY_FILE задаются только вместе")
+	}
+	for _, f := range []string{t.CAFile, t.CertFile, t.KeyFile} {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			return fmt.Errorf("файл TLS недоступен: %w", err)
+		}
+	}
+	return nil
+}
+
+// oneOf - значение входит в список допустимых
+func oneOf(value string, allowed ...string) bool {
+	return slices.Contains(allowed, value)
 }
 
 // Load - грузит .env и переменные окружения в структуру Config
@@ -60,5 +615,19 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("ошибка чтения переменных окружения: %w", err)
 	}
 
+	// Проверяем значения
+	if err := cfg.Kafka.validate(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация Kafka: %w", err)
+	}
+	if err := cfg.HttpServer.validate(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация HTTP сервера: %w", err)
+	}
+	if err := cfg.Log.validate(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация логов: %w", err)
+	}
+	if err := cfg.Tracing.validate(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация трейсинга: %w", err)
+	}
+
 	return &cfg, nil
 }
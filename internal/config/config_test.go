@@ -0,0 +1,220 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validKafka - конфиг Kafka со значениями по умолчанию, который проходит проверку
+func validKafka() KafkaConfig {
+	return KafkaConfig{
+		Brokers:              []string{"localhost:29092"},
+		Topic:                "orders",
+		StartOffset:          "first",
+		MinBytes:             1,
+		MaxBytes:             10485760,
+		MaxWait:              10 * time.Second,
+		SessionTimeout:       30 * time.Second,
+		HeartbeatInterval:    3 * time.Second,
+		Compression:          "none",
+		RequiredAcks:         "one",
+		WriterBatchSize:      100,
+		WriterBatchBytes:     1048576,
+		WriterBatchTimeout:   time.Second,
+		Workers:              1,
+		Ordering:             "partition",
+		DrainTimeout:         10 * time.Second,
+		BatchSize:            1,
+		BatchTimeout:         100 * time.Millisecond,
+		RetryAttempts:        3,
+		RetryBackoff:         200 * time.Millisecond,
+		DedupTTL:             168 * time.Hour,
+		BreakerThreshold:     5,
+		BreakerProbeInterval: 5 * time.Second,
+		Codec:                "json",
+		StoredTopic:          "orders.stored",
+		OutboxInterval:       time.Second,
+	}
+}
+
+// TestKafkaConfig_Valid проверяет, что значения по умолчанию проходят проверку
+func TestKafkaConfig_Valid(t *testing.T) {
+	cfg := validKafka()
+	assert.NoError(t, cfg.validate())
+}
+
+// TestKafkaConfig_LegacyBroker проверяет обратную совместимость:
+// если KAFKA_BROKERS не задан, используется единственный KAFKA_BROKER
+func TestKafkaConfig_LegacyBroker(t *testing.T) {
+	cfg := validKafka()
+	cfg.Brokers = nil
+	cfg.Broker = "kafka:9092"
+
+	assert.NoError(t, cfg.validate())
+	assert.Equal(t, []string{"kafka:9092"}, cfg.Brokers)
+}
+
+// TestKafkaConfig_Invalid проверяет, что некорректные значения отклоняются при загрузке
+func TestKafkaConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *KafkaConfig)
+	}{
+		{"нет брокеров", func(c *KafkaConfig) { c.Brokers = nil }},
+		{"брокер без порта", func(c *KafkaConfig) { c.Brokers = []string{"localhost"} }},
+		{"неизвестный SASL", func(c *KafkaConfig) { c.SASL.Mechanism = "GSSAPI" }},
+		{"SASL без пароля", func(c *KafkaConfig) { c.SASL = KafkaSASL{Mechanism: "PLAIN", Username: "user"} }},
+		{"TLS сертификат без ключа", func(c *KafkaConfig) { c.TLS = KafkaTLS{Enabled: true, CertFile: "cert.pem"} }},
+		{"TLS нет файла CA", func(c *KafkaConfig) { c.TLS = KafkaTLS{Enabled: true, CAFile: "/nonexistent/ca.pem"} }},
+		{"неизвестный start offset", func(c *KafkaConfig) { c.StartOffset = "middle" }},
+		{"min bytes больше max", func(c *KafkaConfig) { c.MinBytes, c.MaxBytes = 100, 10 }},
+		{"heartbeat не меньше сессии", func(c *KafkaConfig) { c.HeartbeatInterval = c.SessionTimeout }},
+		{"неизвестное сжатие", func(c *KafkaConfig) { c.Compression = "brotli" }},
+		{"неизвестные acks", func(c *KafkaConfig) { c.RequiredAcks = "two" }},
+		{"нулевой батч writer", func(c *KafkaConfig) { c.WriterBatchSize = 0 }},
+		{"ноль воркеров", func(c *KafkaConfig) { c.Workers = 0 }},
+		{"неизвестный порядок", func(c *KafkaConfig) { c.Ordering = "random" }},
+		{"несколько топиков без группы", func(c *KafkaConfig) { c.StatusTopic, c.GroupID = "orders.status", "" }},
+		{"ноль попыток", func(c *KafkaConfig) { c.RetryAttempts = 0 }},
+		{"отрицательный TTL дедупликации", func(c *KafkaConfig) { c.DedupTTL = -time.Hour }},
+		{"отрицательный порог предохранителя", func(c *KafkaConfig) { c.BreakerThreshold = -1 }},
+		{"предохранитель без интервала пинга", func(c *KafkaConfig) { c.BreakerProbeInterval = 0 }},
+		{"неизвестный формат", func(c *KafkaConfig) { c.Codec = "xml" }},
+		{"неизвестный формат топика", func(c *KafkaConfig) { c.TopicCodecs = map[string]string{"orders.proto": "thrift"} }},
+		{"реестр схем без схемы URL", func(c *KafkaConfig) { c.SchemaRegistryURL = "registry:8081" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validKafka()
+			tt.modify(&cfg)
+			assert.Error(t, cfg.validate())
+		})
+	}
+}
+
+// TestKafkaConfig_TLSFiles проверяет, что существующие файлы сертификатов принимаются
+func TestKafkaConfig_TLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(ca, []byte("pem"), 0o600))
+
+	cfg := validKafka()
+	cfg.SASL = KafkaSASL{Mechanism: "SCRAM-SHA-512", Username: "user", Password: "pass"}
+	cfg.TLS = KafkaTLS{Enabled: true, CAFile: ca}
+	assert.NoError(t, cfg.validate())
+}
+
+// TestKafkaConfig_ConsumeTopics проверяет список читаемых топиков: без пустых и повторов, основной первым
+func TestKafkaConfig_ConsumeTopics(t *testing.T) {
+	cfg := validKafka()
+	assert.Equal(t, []string{"orders"}, cfg.ConsumeTopics())
+
+	cfg.StatusTopic = "orders.status"
+	cfg.RetryTopic = "orders"
+	cfg.DeleteTopic = "orders.deleted"
+	assert.Equal(t, []string{"orders", "orders.status", "orders.deleted"}, cfg.ConsumeTopics())
+}
+
+// TestLogConfig_Validate проверяет уровни и форматы логов
+func TestLogConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&LogConfig{Level: "debug", Format: "text"}).validate())
+	assert.Error(t, (&LogConfig{Level: "trace", Format: "json"}).validate())
+	assert.Error(t, (&LogConfig{Level: "info", Format: "xml"}).validate())
+}
+
+// TestTracingConfig_Validate проверяет экспортёр, долю семплирования и адрес коллектора
+func TestTracingConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&TracingConfig{Exporter: "none", SampleRatio: 1}).validate())
+	assert.NoError(t, (&TracingConfig{Exporter: "otlp", Endpoint: "http://otel:4318", SampleRatio: 0.1}).validate())
+	assert.Error(t, (&TracingConfig{Exporter: "jaeger", SampleRatio: 1}).validate())
+	assert.Error(t, (&TracingConfig{Exporter: "none", SampleRatio: 1.5}).validate())
+	assert.Error(t, (&TracingConfig{Exporter: "file", SampleRatio: 1}).validate())
+	assert.Error(t, (&TracingConfig{Exporter: "otlp", Endpoint: "otel:4318", SampleRatio: 1}).validate())
+}
+
+// TestHttpServer_Validate проверяет таймауты готовности и остановки
+func TestHttpServer_Validate(t *testing.T) {
+	assert.NoError(t, (&HttpServer{Port: 8080, ReadyTimeout: time.Second}).validate())
+	assert.Error(t, (&HttpServer{Port: 8080}).validate())
+	assert.Error(t, (&HttpServer{Port: 8080, ReadyTimeout: time.Second, ShutdownDelay: -time.Second}).validate())
+	assert.Error(t, (&HttpServer{Port: 8080, ReadyTimeout: time.Second, Compression: CompressionConfig{MinSize: -1}}).validate())
+}
+
+// TestHttpServer_CacheControl проверяет разбор HTTP_CACHE_CONTROL: запятые внутри значения - часть директив
+func TestHttpServer_CacheControl(t *testing.T) {
+	c := HttpServer{ReadyTimeout: time.Second, CacheControl: []string{"/order/{id}=private, no-cache", " /schema/order.json = public, max-age=300 "}}
+	routes, err := c.ParseCacheControl()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"/order/{id}": "private, no-cache", "/schema/order.json": "public, max-age=300"}, routes)
+
+	for _, bad := range []string{"/order/{id}", "order=no-store", "/order/{id}="} {
+		c.CacheControl = []string{bad}
+		assert.Error(t, c.validate(), bad)
+	}
+}
+
+// TestCORSConfig_Validate проверяет источники, методы и запрет "*" вместе с credentials
+func TestCORSConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}).validate())
+	assert.NoError(t, (&CORSConfig{AllowedOrigins: []string{"https://shop.example", "http://localhost:3000"}, AllowCredentials: true}).validate())
+	assert.Error(t, (&CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}).validate())
+	assert.Error(t, (&CORSConfig{AllowedOrigins: []string{"shop.example"}}).validate())
+	assert.Error(t, (&CORSConfig{AllowedOrigins: []string{"https://shop.example/"}}).validate())
+	assert.Error(t, (&CORSConfig{AllowedMethods: []string{"get"}}).validate())
+}
+
+// TestAuthConfig_Validate проверяет ключи API, секрет HS256 и источник JWKS
+func TestAuthConfig_Validate(t *testing.T) {
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	assert.NoError(t, (&AuthConfig{}).validate())
+	assert.NoError(t, (&AuthConfig{Enabled: true, APIKeys: []string{"support:" + hash + ":orders:read", "ops:" + hash + ":orders:read admin"}}).validate())
+	assert.NoError(t, (&AuthConfig{Enabled: true, JWKSURL: "https://idp.example/jwks.json", JWKSRefresh: time.Minute}).validate())
+	assert.Error(t, (&AuthConfig{Enabled: true}).validate())
+	assert.Error(t, (&AuthConfig{APIKeys: []string{"support:" + hash}}).validate())
+	assert.Error(t, (&AuthConfig{APIKeys: []string{"support:plain-key:orders:read"}}).validate())
+	assert.Error(t, (&AuthConfig{APIKeys: []string{"support:" + hash + ":orders:delete"}}).validate())
+	assert.Error(t, (&AuthConfig{JWTSecret: "short"}).validate())
+	assert.Error(t, (&AuthConfig{APIKeys: []string{"support:" + hash + ":orders:read"}, APIKeyRoles: map[string]string{"finance": "finance"}}).validate())
+	assert.Error(t, (&AuthConfig{FieldPolicy: "/nonexistent/policy.json"}).validate())
+	assert.Error(t, (&AuthConfig{JWKSFile: "jwks.json", JWKSURL: "https://idp.example/jwks.json", JWKSRefresh: time.Minute}).validate())
+
+	keys, err := (AuthConfig{APIKeys: []string{"ops:" + hash + ":orders:read admin"}, APIKeyRoles: map[string]string{"ops": "admin"}}).ParseAPIKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", keys[0].Name)
+	assert.Equal(t, "admin", keys[0].Role)
+	assert.Equal(t, []string{"orders:read", "admin"}, keys[0].Scopes)
+}
+
+// TestRateLimitConfig_Validate проверяет лимиты, переопределения маршрутов и доверенные прокси
+func TestRateLimitConfig_Validate(t *testing.T) {
+	valid := RateLimitConfig{Enabled: true, RPS: 10, Burst: 20, MaxClients: 100,
+		Routes: []string{"/healthz=0", "/order/{id}=5:10"}, TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}}
+	assert.NoError(t, (&valid).validate())
+	assert.NoError(t, (&RateLimitConfig{}).validate())
+
+	routes, err := valid.ParseRoutes()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]RouteLimit{"/healthz": {}, "/order/{id}": {RPS: 5, Burst: 10}}, routes)
+	proxies, err := valid.ParseTrustedProxies()
+	assert.NoError(t, err)
+	assert.Len(t, proxies, 2)
+
+	for name, modify := range map[string]func(c *RateLimitConfig){
+		"нулевой RPS":         func(c *RateLimitConfig) { c.RPS = 0 },
+		"нулевой burst":       func(c *RateLimitConfig) { c.Burst = 0 },
+		"ноль клиентов":       func(c *RateLimitConfig) { c.MaxClients = 0 },
+		"маршрут без лимита":  func(c *RateLimitConfig) { c.Routes = []string{"/order/{id}"} },
+		"маршрут без burst":   func(c *RateLimitConfig) { c.Routes = []string{"/order/{id}=5"} },
+		"маршрут без слэша":   func(c *RateLimitConfig) { c.Routes = []string{"order=5:10"} },
+		"некорректный прокси": func(c *RateLimitConfig) { c.TrustedProxies = []string{"proxy.local"} },
+	} {
+		cfg := valid
+		modify(&cfg)
+		assert.Error(t, cfg.validate(), name)
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/fathersson/wb-demo-service/internal/metrics"
+)
+
+// RegisterMetrics добавляет в реестр статистику пула соединений (sql.DBStats)
+func RegisterMetrics(reg *metrics.Registry, db *sql.DB) {
+	gauges := []struct {
+		name, help string
+		value      func(s sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "Лимит открытых соединений с БД", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "Открытых соединений с БД", func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "Соединений с БД в работе", func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "Простаивающих соединений с БД", func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for _, g := range gauges {
+		reg.GaugeFunc(g.name, g.help, func() float64 { return g.value(db.Stats()) })
+	}
+
+	counters := []struct {
+		name, help string
+		value      func(s sql.DBStats) float64
+	}{
+		{"db_wait_count_total", "Сколько раз ждали свободное соединение с БД", func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "Суммарное ожидание свободного соединения с БД", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "Соединений закрыто из-за лимита простаивающих", func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_idle_time_closed_total", "Соединений закрыто из-за времени простоя", func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_max_lifetime_closed_total", "Соединений закрыто из-за времени жизни", func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, c := range counters {
+		reg.CounterFunc(c.name, c.help, func() float64 { return c.value(db.Stats()) })
+	}
+}
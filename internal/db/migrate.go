@@ -0,0 +1,20 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+)
+
+//go:embed schema.sql
+var schema string
+
+// Migrate создаёт недостающие таблицы и индексы из schema.sql
+// Все операторы с IF NOT EXISTS: на существующей базе добавляется только то, чего в ней нет
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("ошибка создания схемы БД: %w", err)
+	}
+	return nil
+}
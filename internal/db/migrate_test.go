@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMigrate проверяет, что схема применяется целиком и создаёт таблицы, в которые пишут SaveOrder и дедупликация
+func TestMigrate(t *testing.T) {
+	database, mock, err := sqlmock.New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer database.Close()
+
+	for _, table := range []string{"orders", "delivery", "payment", "items", "outbox", "processed_events"} {
+		assert.Contains(t, schema, "CREATE TABLE IF NOT EXISTS "+table+" (")
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(schema)).WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.NoError(t, Migrate(context.Background(), database))
+
+	mock.ExpectExec(regexp.QuoteMeta(schema)).WillReturnError(assert.AnError)
+	assert.ErrorIs(t, Migrate(context.Background(), database), assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
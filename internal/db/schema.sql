@@ -0,0 +1,90 @@
+-- Схема БД сервиса: применяется при старте (db.Migrate), все операторы идемпотентны
+
+-- ================================
+-- 1) Таблица orders
+-- ================================
+CREATE TABLE IF NOT EXISTS orders (
+    order_uid          TEXT PRIMARY KEY,
+    track_number       TEXT NOT NULL,
+    entry              TEXT,
+    locale             TEXT,
+    internal_signature TEXT,
+    customer_id        TEXT,
+    delivery_service   TEXT,
+    shardkey           TEXT,
+    sm_id              INTEGER,
+    date_created       TIMESTAMPTZ,
+    oof_shard          TEXT
+);
+
+-- ================================
+-- 2) Таблица delivery
+-- ================================
+CREATE TABLE IF NOT EXISTS delivery (
+    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+    name      TEXT NOT NULL,
+    phone     TEXT NOT NULL,
+    zip       TEXT NOT NULL,
+    city      TEXT NOT NULL,
+    address   TEXT NOT NULL,
+    region    TEXT,
+    email     TEXT
+);
+
+-- ================================
+-- 3) Таблица payment
+-- ================================
+CREATE TABLE IF NOT EXISTS payment (
+    order_uid     TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
+    transaction   TEXT NOT NULL,
+    request_id    TEXT,
+    currency      TEXT NOT NULL,
+    provider      TEXT NOT NULL,
+    amount        INTEGER NOT NULL,
+    payment_dt    BIGINT NOT NULL,
+    bank          TEXT,
+    delivery_cost INTEGER NOT NULL,
+    goods_total   INTEGER NOT NULL,
+    custom_fee    INTEGER
+);
+
+-- ================================
+-- 4) Таблица items
+-- ================================
+CREATE TABLE IF NOT EXISTS items (
+    id SERIAL PRIMARY KEY,
+    order_uid   TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    chrt_id     INTEGER NOT NULL,
+    track_number TEXT,
+    price       INTEGER NOT NULL,
+    rid         TEXT,
+    name        TEXT NOT NULL,
+    sale        INTEGER,
+    size        TEXT,
+    total_price INTEGER,
+    nm_id       INTEGER,
+    brand       TEXT,
+    status      INTEGER
+);
+
+-- ================================
+-- 5) Таблица outbox (события для orders.stored)
+-- ================================
+CREATE TABLE IF NOT EXISTS outbox (
+    id         BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    order_uid  TEXT NOT NULL,
+    payload    JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at    TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS outbox_unsent_idx ON outbox (id) WHERE sent_at IS NULL;
+
+-- ================================
+-- 6) Таблица processed_events (дедупликация доставок из Kafka)
+-- ================================
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id     TEXT PRIMARY KEY,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS processed_events_at_idx ON processed_events (processed_at);
@@ -0,0 +1,228 @@
+// Package export - выгрузка ответов API в плоские форматы
+// Работает с уже готовым JSON-представлением, поэтому замаскированные поля в выгрузку не попадают
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSV выгружает v плоской таблицей: вложенные объекты разворачиваются в колонки delivery.name,
+// первый массив объектов (items у заказа) даёт строки, остальные поля повторяются в каждой строке
+// Колонки идут в порядке полей JSON, поле, которое есть не у всех элементов, в остальных строках пустое
+func CSV(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	root, err := decode(dec)
+	if err != nil {
+		return err
+	}
+
+	t := &table{}
+	t.flatten("", root)
+
+	cw := csv.NewWriter(w)
+	cw.Write(t.columns)
+	rows := t.rows
+	if rows == nil {
+		rows = []row{{}} // без массива объектов - одна строка
+	}
+	for _, r := range rows {
+		record := make([]string, len(t.columns))
+		for i, col := range t.columns {
+			if val, ok := t.base[col]; ok {
+				record[i] = val
+			} else {
+				record[i] = r[col]
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// field - поле объекта с сохранением порядка
+type field struct {
+	key string
+	val any
+}
+
+// object - объект JSON в порядке полей, map его бы потерял
+type object []field
+
+// decode читает значение JSON: object, []any, string, json.Number, bool или nil
+func decode(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var obj object
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{key: key.(string), val: val})
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			val, err := decode(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// row - значения одной строки по колонкам
+type row map[string]string
+
+// table - колонки и строки выгрузки
+type table struct {
+	columns []string
+	base    row   // поля вне массива строк, общие для всех строк
+	rows    []row // строки из первого массива объектов
+	seen    map[string]bool
+}
+
+func (t *table) column(name string) {
+	if t.seen == nil {
+		t.seen = make(map[string]bool)
+		t.base = make(row)
+	}
+	if !t.seen[name] {
+		t.seen[name] = true
+		t.columns = append(t.columns, name)
+	}
+}
+
+// flatten раскладывает значение по колонкам с префиксом пути
+func (t *table) flatten(prefix string, v any) {
+	switch v := v.(type) {
+	case object:
+		for _, f := range v {
+			t.flatten(join(prefix, f.key), f.val)
+		}
+	case []any:
+		if t.rows == nil && isObjects(v) {
+			t.rows = make([]row, 0, len(v))
+			for _, item := range v {
+				r := make(row)
+				t.flattenRow(r, prefix, item)
+				t.rows = append(t.rows, r)
+			}
+			return
+		}
+		t.column(prefix)
+		t.base[prefix] = joinValues(v)
+	default:
+		t.column(prefix)
+		t.base[prefix] = cell(v)
+	}
+}
+
+// flattenRow раскладывает элемент массива строк; вложенные массивы остаются одной ячейкой
+func (t *table) flattenRow(r row, prefix string, v any) {
+	switch v := v.(type) {
+	case object:
+		for _, f := range v {
+			t.flattenRow(r, join(prefix, f.key), f.val)
+		}
+	case []any:
+		t.column(prefix)
+		r[prefix] = joinValues(v)
+	default:
+		t.column(prefix)
+		r[prefix] = cell(v)
+	}
+}
+
+func isObjects(arr []any) bool {
+	if len(arr) == 0 {
+		return false
+	}
+	for _, v := range arr {
+		if _, ok := v.(object); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// joinValues - массив в одной ячейке: скаляры через ";", объекты - JSON
+func joinValues(arr []any) string {
+	parts := make([]string, len(arr))
+	for i, v := range arr {
+		switch v.(type) {
+		case object, []any:
+			b, _ := json.Marshal(raw(v))
+			parts[i] = string(b)
+		default:
+			parts[i] = cell(v)
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// raw возвращает object и массивы к виду, который понимает json.Marshal; порядок полей здесь не важен
+func raw(v any) any {
+	switch v := v.(type) {
+	case object:
+		m := make(map[string]any, len(v))
+		for _, f := range v {
+			m[f.key] = raw(f.val)
+		}
+		return m
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = raw(item)
+		}
+		return out
+	}
+	return v
+}
+
+// cell - значение ячейки; строки, которые табличный редактор принял бы за формулу, экранируются апострофом
+func cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,44 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCSV_Order
+// Проверяет выгрузку заказа: строка на каждый товар, вложенные объекты - колонки с путём,
+// поля заказа повторяются, а поле, которого нет у части товаров, остаётся пустым
+func TestCSV_Order(t *testing.T) {
+	order := models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Delivery:    models.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin"},
+		Payment:     models.Payment{Transaction: "b563feb7b2b84b6test", Currency: "USD", Amount: 1817},
+		Items: []models.Item{
+			{ChrtID: 9934930, Price: 453, Name: "Mascaras", Brand: "Vivienne Sabo"},
+			{ChrtID: 9934931, Price: 100, Name: "Brush, small"},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, CSV(&buf, order))
+
+	want := "order_uid,track_number,delivery.name,delivery.phone,delivery.zip,delivery.city,delivery.address," +
+		"payment.transaction,payment.currency,payment.provider,payment.amount,payment.payment_dt,payment.delivery_cost,payment.goods_total," +
+		"items.chrt_id,items.price,items.name,items.total_price,items.brand,date_created\n" +
+		"b563feb7b2b84b6test,WBILMTESTTRACK,Test Testov,'+9720000000,,Kiryat Mozkin,,b563feb7b2b84b6test,USD,,1817,0,0,0,9934930,453,Mascaras,0,Vivienne Sabo,0001-01-01T00:00:00Z\n" +
+		"b563feb7b2b84b6test,WBILMTESTTRACK,Test Testov,'+9720000000,,Kiryat Mozkin,,b563feb7b2b84b6test,USD,,1817,0,0,0,9934931,100,\"Brush, small\",0,,0001-01-01T00:00:00Z\n"
+	assert.Equal(t, want, buf.String())
+}
+
+// TestCSV_List
+// Проверяет выгрузку списка: колонки без префикса, строка на элемент
+func TestCSV_List(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, CSV(&buf, []map[string]any{{"a": 1, "b": []string{"x", "y"}}, {"a": 2}}))
+	assert.Equal(t, "a,b\n1,x;y\n2,\n", buf.String())
+}
@@ -0,0 +1,221 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// adminTimeout - таймаут одного админского запроса к кластеру
+const adminTimeout = 30 * time.Second
+
+// ReplayOptions - какие оффсеты переигрывать
+// Начало диапазона: FromTime, если задан, иначе FromOffset (отрицательный - первый доступный оффсет).
+// Конец диапазона: ToOffset не включительно (отрицательный - текущий конец партиции)
+type ReplayOptions struct {
+	Topic      string
+	Partitions []int // пусто - все партиции топика
+	FromOffset int64
+	FromTime   time.Time
+	ToOffset   int64
+}
+
+// Admin - админские операции с кластером: поиск оффсетов и перемотка consumer group
+type Admin struct {
+	client *kafka.Client
+	cfg    config.KafkaConfig
+}
+
+// NewAdmin создаёт клиент с теми же брокерами и настройками безопасности, что и reader/writer
+func NewAdmin(cfg config.KafkaConfig) (*Admin, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Admin{
+		client: &kafka.Client{
+			Addr:      kafka.TCP(cfg.Brokers...),
+			Timeout:   adminTimeout,
+			Transport: transport,
+		},
+		cfg: cfg,
+	}, nil
+}
+
+// ResolveRanges переводит опции в конкретные диапазоны оффсетов по партициям
+func (a *Admin) ResolveRanges(ctx context.Context, opts ReplayOptions) ([]PartitionRange, error) {
+	partitions := opts.Partitions
+	if len(partitions) == 0 {
+		var err error
+		if partitions, err = a.partitions(ctx, opts.Topic); err != nil {
+			return nil, err
+		}
+	}
+
+	// Kafka не принимает одну партицию дважды в одном запросе, поэтому три отдельных запроса
+	first, err := a.listOffsets(ctx, opts.Topic, partitions, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	last, err := a.listOffsets(ctx, opts.Topic, partitions, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	var byTime map[int]kafka.PartitionOffsets
+	if !opts.FromTime.IsZero() {
+		byTime, err = a.listOffsets(ctx, opts.Topic, partitions, func(p int) kafka.OffsetRequest {
+			return kafka.TimeOffsetOf(p, opts.FromTime)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ranges := make([]PartitionRange, 0, len(partitions))
+	for _, p := range partitions {
+		pr := PartitionRange{Partition: p, From: first[p].FirstOffset, To: last[p].LastOffset}
+		switch {
+		case byTime != nil:
+			// Оффсет первого сообщения не раньше FromTime, -1 - таких сообщений нет
+			pr.From = pr.To
+			for off := range byTime[p].Offsets {
+				if off >= 0 && off < pr.From {
+					pr.From = off
+				}
+			}
+		case opts.FromOffset >= 0:
+			pr.From = max(opts.FromOffset, pr.From)
+		}
+		if opts.ToOffset >= 0 {
+			pr.To = min(opts.ToOffset, pr.To)
+		}
+		ranges = append(ranges, pr)
+	}
+
+	slices.SortFunc(ranges, func(a, b PartitionRange) int { return a.Partition - b.Partition })
+	return ranges, nil
+}
+
+// SeekGroup перематывает consumer group на начало диапазонов, чтобы обычный консьюмер перечитал их
+// Группа должна быть остановлена: коммит идёт без членства в группе (generation -1)
+func (a *Admin) SeekGroup(ctx context.Context, groupID, topic string, ranges []PartitionRange) error {
+	commits := make([]kafka.OffsetCommit, len(ranges))
+	for i, pr := range ranges {
+		commits[i] = kafka.OffsetCommit{Partition: pr.Partition, Offset: pr.From}
+	}
+
+	resp, err := a.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка перемотки группы %s: %w", groupID, err)
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return fmt.Errorf("ошибка перемотки группы %s, партиция %d: %w", groupID, p.Partition, p.Error)
+		}
+	}
+	return nil
+}
+
+// PartitionReader - фабрика reader'ов на отдельные партиции без consumer group, для Replay
+func (a *Admin) PartitionReader(topic string) ReaderFactory {
+	return func(partition int, offset int64) (MessageReader, error) {
+		dialer, err := newDialer(a.cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   a.cfg.Brokers,
+			Topic:     topic,
+			Partition: partition,
+			Dialer:    dialer,
+			MinBytes:  a.cfg.MinBytes,
+			MaxBytes:  a.cfg.MaxBytes,
+			MaxWait:   a.cfg.MaxWait,
+		})
+		if err := reader.SetOffset(offset); err != nil {
+			reader.Close()
+			return nil, err
+		}
+		return reader, nil
+	}
+}
+
+// listOffsets - оффсеты партиций топика по одному запросу на каждую партицию
+func (a *Admin) listOffsets(ctx context.Context, topic string, partitions []int, request func(int) kafka.OffsetRequest) (map[int]kafka.PartitionOffsets, error) {
+	requests := make([]kafka.OffsetRequest, len(partitions))
+	for i, p := range partitions {
+		requests[i] = request(p)
+	}
+
+	resp, err := a.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения оффсетов: %w", err)
+	}
+
+	offsets := make(map[int]kafka.PartitionOffsets, len(partitions))
+	for _, po := range resp.Topics[topic] {
+		if po.Error != nil {
+			return nil, fmt.Errorf("ошибка получения оффсетов партиции %d: %w", po.Partition, po.Error)
+		}
+		offsets[po.Partition] = po
+	}
+	return offsets, nil
+}
+
+// partitions - номера всех партиций топика
+func (a *Admin) partitions(ctx context.Context, topic string) ([]int, error) {
+	meta, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения метаданных: %w", err)
+	}
+
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("ошибка метаданных топика %s: %w", topic, t.Error)
+		}
+		partitions := make([]int, len(t.Partitions))
+		for i, p := range t.Partitions {
+			partitions[i] = p.ID
+		}
+		return partitions, nil
+	}
+	return nil, fmt.Errorf("топик %s не найден", topic)
+}
+
+// Ping проверяет связь с кластером: брокер отвечает на запрос метаданных и все топики существуют
+// Reader подключается к тем же брокерам с теми же настройками, поэтому это и проверка готовности консьюмера
+func (a *Admin) Ping(ctx context.Context, topics ...string) error {
+	meta, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return fmt.Errorf("ошибка получения метаданных: %w", err)
+	}
+
+	found := make(map[string]bool, len(meta.Topics))
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return fmt.Errorf("ошибка метаданных топика %s: %w", t.Name, t.Error)
+		}
+		found[t.Name] = true
+	}
+	for _, topic := range topics {
+		if !found[topic] {
+			return fmt.Errorf("топик %s не найден", topic)
+		}
+	}
+	return nil
+}
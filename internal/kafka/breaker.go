@@ -0,0 +1,211 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/metrics"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+)
+
+// Состояния предохранителя БД
+const (
+	BreakerClosed   = "closed"    // БД работает, сообщения обрабатываются
+	BreakerOpen     = "open"      // БД недоступна: чтение из Kafka остановлено, база проверяется пингом
+	BreakerHalfOpen = "half-open" // пинг прошёл, первая запись в БД решает - закрыться или открыться снова
+)
+
+// Pinger - проверка доступности БД, её реализует *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// BreakerStatus - состояние предохранителя для health и метрик
+type BreakerStatus struct {
+	State     string    `json:"state"`
+	Failures  int       `json:"consecutive_failures"`
+	Opens     uint64    `json:"opens_total"`
+	OpenedAt  time.Time `json:"opened_at,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Breaker - предохранитель вокруг записи в БД
+// После threshold ошибок сохранения подряд он открывается: консьюмер перестаёт читать Kafka,
+// а уже прочитанные сообщения ждут, не тратя попытки. Run раз в interval пингует базу
+// и, когда она отвечает, пускает сообщения снова
+type Breaker struct {
+	db        Pinger
+	threshold int
+	interval  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	opens    uint64
+	openedAt time.Time
+	lastErr  error
+	ready    chan struct{} // закрыт, пока предохранитель не открыт
+}
+
+func NewBreaker(db Pinger, threshold int, interval time.Duration) *Breaker {
+	ready := make(chan struct{})
+	close(ready)
+	return &Breaker{
+		db:        db,
+		threshold: max(threshold, 1),
+		interval:  interval,
+		state:     BreakerClosed,
+		ready:     ready,
+	}
+}
+
+// Wait блокируется, пока предохранитель открыт. Для nil-предохранителя сразу возвращает nil
+func (b *Breaker) Wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	ready := b.ready
+	b.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ready:
+		return nil
+	}
+}
+
+// Record учитывает итог обработки сообщения
+// Считается только недоступность БД (repository.ErrUnavailable): битое сообщение или дубль заказа
+// ничего не говорят о её здоровье и не должны останавливать чтение
+func (b *Breaker) Record(err error) {
+	if b == nil || (err != nil && !errors.Is(err, repository.ErrUnavailable)) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		if b.state == BreakerHalfOpen {
+			b.state = BreakerClosed
+			slog.Info("БД снова принимает записи, предохранитель закрыт")
+		}
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.trip()
+	}
+}
+
+// trip открывает предохранитель, вызывается под mu
+func (b *Breaker) trip() {
+	b.state = BreakerOpen
+	b.opens++
+	b.openedAt = time.Now()
+	b.ready = make(chan struct{})
+	slog.Error("БД недоступна, чтение из Kafka приостановлено", "failures", b.failures, logger.Err(b.lastErr))
+}
+
+// Open - открыт ли предохранитель (сообщения ждут восстановления БД)
+func (b *Breaker) Open() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == BreakerOpen
+}
+
+// Run раз в interval пингует открытую БД, пока не отменится контекст
+// Успешный пинг переводит предохранитель в half-open и возобновляет обработку
+func (b *Breaker) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if b.Open() {
+				b.probe(ctx)
+			}
+		}
+	}
+}
+
+// probe - одна проверка БД с таймаутом в interval
+func (b *Breaker) probe(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, b.interval)
+	defer cancel()
+	err := b.db.PingContext(pingCtx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.lastErr = err
+		slog.Warn("БД всё ещё недоступна", logger.Err(err))
+		return
+	}
+	if b.state == BreakerOpen {
+		b.state = BreakerHalfOpen
+		close(b.ready)
+		slog.Info("БД отвечает на пинг, возобновляем чтение из Kafka")
+	}
+}
+
+// Status - текущее состояние предохранителя
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := BreakerStatus{State: b.state, Failures: b.failures, Opens: b.opens}
+	if b.state != BreakerClosed {
+		s.OpenedAt = b.openedAt
+	}
+	if b.lastErr != nil && b.state != BreakerClosed {
+		s.LastError = b.lastErr.Error()
+	}
+	return s
+}
+
+// Ready - проверка для /readyz: не готов, пока предохранитель открыт и консьюмер не читает Kafka
+func (b *Breaker) Ready(context.Context) error {
+	s := b.Status()
+	if s.State != BreakerOpen {
+		return nil
+	}
+	if s.LastError != "" {
+		return fmt.Errorf("предохранитель БД открыт: %s", s.LastError)
+	}
+	return errors.New("предохранитель БД открыт")
+}
+
+// Register добавляет метрики предохранителя в реестр
+func (b *Breaker) Register(reg *metrics.Registry) {
+	reg.GaugeFunc("kafka_consumer_breaker_state", "Состояние предохранителя БД: 0 - closed, 1 - open, 2 - half-open", func() float64 {
+		switch b.Status().State {
+		case BreakerOpen:
+			return 1
+		case BreakerHalfOpen:
+			return 2
+		}
+		return 0
+	})
+	reg.GaugeFunc("kafka_consumer_breaker_failures", "Ошибок сохранения в БД подряд", func() float64 {
+		return float64(b.Status().Failures)
+	})
+	reg.CounterFunc("kafka_consumer_breaker_opens_total", "Сколько раз предохранитель БД открывался", func() float64 {
+		return float64(b.Status().Opens)
+	})
+}
@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// pingerFunc - функция как Pinger
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) PingContext(ctx context.Context) error {
+	return f(ctx)
+}
+
+// downFor - пингер, который отвечает ошибкой первые n раз
+func downFor(n int32) (Pinger, *atomic.Int32) {
+	var calls atomic.Int32
+	return pingerFunc(func(context.Context) error {
+		if calls.Add(1) <= n {
+			return errors.New("connection refused")
+		}
+		return nil
+	}), &calls
+}
+
+// waitFor ждёт, пока предохранитель пропустит, не дольше секунды
+func waitFor(t *testing.T, b *Breaker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, b.Wait(ctx))
+}
+
+// TestBreaker проверяет переходы предохранителя:
+// 1) Ошибки парсинга и данных и отмена контекста не считаются, две ошибки недоступности БД подряд открывают его
+// 2) Пока пинг падает, Wait блокируется; после успешного пинга - half-open
+// 3) Ошибка в half-open снова открывает, успех после пинга закрывает
+func TestBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pinger, pings := downFor(2)
+	b := NewBreaker(pinger, 2, 10*time.Millisecond)
+	go b.Run(ctx)
+
+	b.Record(fmt.Errorf("%w: bad", ErrDecode))
+	b.Record(fmt.Errorf("%w: %w: duplicate key", ErrSave, repository.ErrInternal))
+	b.Record(fmt.Errorf("%w: %w", ErrSave, context.Canceled))
+	b.Record(fmt.Errorf("%w: %w: down", ErrSave, repository.ErrUnavailable))
+	assert.Equal(t, BreakerClosed, b.Status().State)
+	b.Record(fmt.Errorf("%w: %w: down", ErrSave, repository.ErrUnavailable))
+	assert.Equal(t, BreakerOpen, b.Status().State)
+	assert.Error(t, b.Ready(ctx))
+
+	waitFor(t, b)
+	assert.Equal(t, int32(3), pings.Load())
+	assert.Equal(t, BreakerHalfOpen, b.Status().State)
+
+	b.Record(fmt.Errorf("%w: %w: down", ErrSave, repository.ErrUnavailable))
+	assert.True(t, b.Open())
+
+	waitFor(t, b)
+	b.Record(nil)
+	status := b.Status()
+	assert.Equal(t, BreakerClosed, status.State)
+	assert.Equal(t, uint64(2), status.Opens)
+	assert.Zero(t, status.Failures)
+}
+
+// TestBreaker_Nil проверяет, что консьюмер без предохранителя работает как раньше
+func TestBreaker_Nil(t *testing.T) {
+	var b *Breaker
+	assert.NoError(t, b.Wait(context.Background()))
+	assert.False(t, b.Open())
+	b.Record(ErrSave)
+}
+
+// TestConsumer_Breaker проверяет паузу при недоступной БД:
+// 1) Первое сохранение падает, предохранитель с порогом 1 открывается
+// 2) Сообщение ждёт восстановления БД, не тратя попытки (их всего одна)
+// 3) После успешного пинга заказ сохраняется, оба оффсета коммитятся
+func TestConsumer_Breaker(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{msgs: []kafka.Message{
+		orderMessage("p1", 0, 0),
+		orderMessage("p2", 0, 1),
+	}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	pinger, pings := downFor(1)
+
+	var saves atomic.Int32
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.AnythingOfType("models.Order")).
+		RunAndReturn(func(_ context.Context, o models.Order) error {
+			if saves.Add(1) == 1 {
+				return fmt.Errorf("%w: connection refused", repository.ErrUnavailable)
+			}
+			if o.OrderUID == "p2" {
+				cancel()
+			}
+			return nil
+		}).
+		Times(3)
+	cache.EXPECT().SetCache(mock.Anything, mock.Anything).Return().Times(2)
+
+	c := NewConsumer(reader, repo, cache, config.KafkaConfig{})
+	b := NewBreaker(pinger, 1, 10*time.Millisecond)
+	c.UseBreaker(b)
+	c.Run(ctx)
+
+	assert.Equal(t, int32(2), pings.Load())
+	assert.Equal(t, BreakerClosed, b.Status().State)
+	if assert.NotEmpty(t, reader.commits) {
+		assert.Equal(t, int64(1), reader.commits[len(reader.commits)-1].Offset)
+	}
+}
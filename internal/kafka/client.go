@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// dialTimeout - таймаут установки соединения с брокером (TCP, TLS, SASL)
+const dialTimeout = 10 * time.Second
+
+// newDialer - Dialer для reader с TLS и SASL из конфига
+func newDialer(cfg config.KafkaConfig) (*kafka.Dialer, error) {
+	mechanism, err := saslMechanism(cfg.SASL)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg, err := tlsConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Dialer{
+		Timeout:       dialTimeout,
+		DualStack:     true,
+		TLS:           tlsCfg,
+		SASLMechanism: mechanism,
+	}, nil
+}
+
+// newTransport - Transport для writer с TLS и SASL из конфига
+func newTransport(cfg config.KafkaConfig) (*kafka.Transport, error) {
+	mechanism, err := saslMechanism(cfg.SASL)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg, err := tlsConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		DialTimeout: dialTimeout,
+		TLS:         tlsCfg,
+		SASL:        mechanism,
+	}, nil
+}
+
+// saslMechanism - механизм SASL по имени, nil - без аутентификации
+func saslMechanism(cfg config.KafkaSASL) (sasl.Mechanism, error) {
+	switch cfg.Mechanism {
+	case "":
+		return nil, nil
+	case "PLAIN":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("неизвестный механизм SASL %q", cfg.Mechanism)
+	}
+}
+
+// tlsConfig - настройки TLS, nil - соединение без шифрования
+func tlsConfig(cfg config.KafkaTLS) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	// Свой CA для проверки сертификата брокера
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("в %s нет PEM сертификатов", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	// Клиентский сертификат для mTLS
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки клиентского сертификата: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// compressionCodec - кодек сжатия writer по имени, 0 - без сжатия
+func compressionCodec(name string) (kafka.Compression, error) {
+	switch name {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("неизвестный кодек сжатия %q", name)
+	}
+}
+
+// requiredAcks - уровень подтверждения записи по имени
+func requiredAcks(name string) (kafka.RequiredAcks, error) {
+	switch name {
+	case "none":
+		return kafka.RequireNone, nil
+	case "", "one":
+		return kafka.RequireOne, nil
+	case "all":
+		return kafka.RequireAll, nil
+	default:
+		return 0, fmt.Errorf("неизвестный уровень подтверждения %q", name)
+	}
+}
+
+// startOffset - откуда читать при первом запуске группы
+func startOffset(name string) (int64, error) {
+	switch name {
+	case "", "first":
+		return kafka.FirstOffset, nil
+	case "last":
+		return kafka.LastOffset, nil
+	default:
+		return 0, fmt.Errorf("неизвестный начальный оффсет %q", name)
+	}
+}
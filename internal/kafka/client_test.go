@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewTopicWriter_Settings проверяет, что writer собирается из конфига:
+// несколько брокеров, сжатие, подтверждения, батчинг и SASL в транспорте
+func TestNewTopicWriter_Settings(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Brokers:          []string{"k1:9092", "k2:9092"},
+		Compression:      "zstd",
+		RequiredAcks:     "all",
+		WriterBatchSize:  50,
+		WriterBatchBytes: 2048,
+		SASL:             config.KafkaSASL{Mechanism: "SCRAM-SHA-256", Username: "user", Password: "pass"},
+	}
+
+	w, err := NewTopicWriter(cfg, "orders.stored")
+	assert.NoError(t, err)
+	assert.Equal(t, "orders.stored", w.Topic)
+	assert.Equal(t, "k1:9092,k2:9092", w.Addr.String())
+	assert.Equal(t, kafka.Zstd, w.Compression)
+	assert.Equal(t, kafka.RequireAll, w.RequiredAcks)
+	assert.Equal(t, 50, w.BatchSize)
+	assert.Equal(t, int64(2048), w.BatchBytes)
+
+	transport, ok := w.Transport.(*kafka.Transport)
+	if assert.True(t, ok) {
+		assert.Equal(t, "SCRAM-SHA-256", transport.SASL.Name())
+		assert.Nil(t, transport.TLS)
+	}
+}
+
+// TestNewDialer_TLSAndPlain проверяет dialer reader с TLS и SASL PLAIN
+func TestNewDialer_TLSAndPlain(t *testing.T) {
+	cfg := config.KafkaConfig{
+		SASL: config.KafkaSASL{Mechanism: "PLAIN", Username: "user", Password: "pass"},
+		TLS:  config.KafkaTLS{Enabled: true, ServerName: "kafka.internal"},
+	}
+
+	d, err := newDialer(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "PLAIN", d.SASLMechanism.Name())
+	if assert.NotNil(t, d.TLS) {
+		assert.Equal(t, "kafka.internal", d.TLS.ServerName)
+	}
+}
+
+// TestNewDialer_BadCA проверяет, что недоступный CA файл даёт ошибку, а не молчаливый plaintext
+func TestNewDialer_BadCA(t *testing.T) {
+	cfg := config.KafkaConfig{TLS: config.KafkaTLS{Enabled: true, CAFile: "/nonexistent/ca.pem"}}
+
+	_, err := newDialer(cfg)
+	assert.Error(t, err)
+}
+
+// TestClientOptions_Unknown проверяет отказ на неизвестных значениях
+func TestClientOptions_Unknown(t *testing.T) {
+	_, err := compressionCodec("brotli")
+	assert.Error(t, err)
+	_, err = requiredAcks("two")
+	assert.Error(t, err)
+	_, err = startOffset("middle")
+	assert.Error(t, err)
+}
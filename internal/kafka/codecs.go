@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fathersson/wb-demo-service/internal/codec"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// Codecs - выбор формата тела сообщения с заказом:
+// по заголовку content-type, затем по топику, иначе формат по умолчанию
+type Codecs struct {
+	fallback   codec.Codec
+	byTopic    map[string]codec.Codec
+	registry   codec.SchemaRegistry // nil - Protobuf и Avro без wire format
+	jsonSchema bool                 // проверять JSON заказа по JSON Schema до декодирования
+}
+
+// NewCodecs собирает форматы из конфига, при заданном реестре схем Protobuf и Avro читаются в wire format Confluent
+// С KAFKA_VALIDATE_SCHEMA JSON заказа сначала проверяется по JSON Schema (см. пакет schema)
+func NewCodecs(cfg config.KafkaConfig) (*Codecs, error) {
+	var registry codec.SchemaRegistry
+	if cfg.SchemaRegistryURL != "" {
+		registry = codec.NewHTTPRegistry(cfg.SchemaRegistryURL)
+	}
+	c, err := newCodecs(cfg.Codec, cfg.TopicCodecs, registry)
+	if err != nil {
+		return nil, err
+	}
+	c.jsonSchema = cfg.ValidateSchema
+	return c, nil
+}
+
+func newCodecs(fallback string, topics map[string]string, registry codec.SchemaRegistry) (*Codecs, error) {
+	c := &Codecs{byTopic: make(map[string]codec.Codec), registry: registry}
+
+	var err error
+	if c.fallback, err = c.byName(fallback); err != nil {
+		return nil, err
+	}
+	for topic, name := range topics {
+		if c.byTopic[topic], err = c.byName(name); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// For - формат тела сообщения
+func (c *Codecs) For(msg kafka.Message) (codec.Codec, error) {
+	if contentType, ok := header(msg, codec.ContentTypeHeader); ok {
+		cd, err := codec.ByContentType(contentType)
+		if err != nil {
+			return nil, err
+		}
+		return c.wrap(cd), nil
+	}
+	if cd, ok := c.byTopic[msg.Topic]; ok {
+		return cd, nil
+	}
+	return c.fallback, nil
+}
+
+func (c *Codecs) byName(name string) (codec.Codec, error) {
+	if name == "" {
+		name = codec.NameJSON
+	}
+	cd, err := codec.ByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.wrap(cd), nil
+}
+
+// wrap оборачивает бинарные форматы в wire format, если есть реестр схем
+// ID схемы нужен только для записи, консьюмер берёт его из сообщения
+func (c *Codecs) wrap(cd codec.Codec) codec.Codec {
+	if c.registry == nil || cd.Name() == codec.NameJSON {
+		return cd
+	}
+	return codec.NewWire(cd, c.registry, 0)
+}
+
+// defaultCodecs - форматы при nil *Codecs: всё читается как JSON, content-type из заголовка учитывается всегда
+var defaultCodecs = &Codecs{fallback: codec.JSON{}}
+
+// orderCodec - формат тела сообщения с заказом и нужна ли проверка JSON Schema
+func (c *Codecs) orderCodec(msg kafka.Message) (codec.Codec, bool, error) {
+	if c == nil {
+		c = defaultCodecs
+	}
+	cd, err := c.For(msg)
+	return cd, c.jsonSchema, err
+}
+
+// decodeError - ошибка разбора тела: недоступный реестр схем временная, остальное - битое сообщение
+func decodeError(err error) error {
+	if errors.Is(err, codec.ErrRegistryUnavailable) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrDecode, err)
+}
@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fathersson/wb-demo-service/internal/codec"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeOrder_Codecs проверяет выбор формата тела:
+// 1) content-type из заголовка важнее топика
+// 2) без заголовка формат берётся по топику, иначе - формат по умолчанию (JSON)
+func TestDecodeOrder_Codecs(t *testing.T) {
+	c, err := newCodecs("json", map[string]string{"orders.avro": "avro"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	order, err := DecodeOrder(c, kafka.Message{Value: []byte(validOrderJSON)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	protoBody, _ := codec.Protobuf{}.Encode(order)
+	avroBody, _ := codec.Avro{}.Encode(order)
+
+	tests := []struct {
+		name string
+		msg  kafka.Message
+	}{
+		{"protobuf по content-type", kafka.Message{
+			Topic:   "orders.avro",
+			Value:   protoBody,
+			Headers: []kafka.Header{{Key: codec.ContentTypeHeader, Value: []byte("application/x-protobuf")}},
+		}},
+		{"avro по топику", kafka.Message{Topic: "orders.avro", Value: avroBody}},
+		{"json по умолчанию", kafka.Message{Topic: "orders", Value: []byte(validOrderJSON)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeOrder(c, tt.msg)
+			if assert.NoError(t, err) {
+				assert.Equal(t, order, got)
+			}
+		})
+	}
+
+	_, err = DecodeOrder(c, kafka.Message{Value: avroBody, Headers: []kafka.Header{{Key: codec.ContentTypeHeader, Value: []byte("text/xml")}}})
+	assert.ErrorIs(t, err, ErrDecode)
+}
+
+// unavailableRegistry - реестр схем, который не отвечает
+type unavailableRegistry struct{}
+
+func (unavailableRegistry) SchemaByID(int) (codec.Schema, error) {
+	return codec.Schema{}, codec.ErrRegistryUnavailable
+}
+
+// TestDecodeOrder_SchemaRegistry проверяет wire format Confluent:
+// сообщение читается по схеме из реестра, а недоступный реестр - временная ошибка, которую стоит повторить
+func TestDecodeOrder_SchemaRegistry(t *testing.T) {
+	order, err := DecodeOrder(nil, kafka.Message{Value: []byte(validOrderJSON)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	registry := codec.NewMemoryRegistry()
+	id := registry.Register(codec.SchemaAvro, codec.AvroSchema)
+	body, _ := codec.NewWire(codec.Avro{}, registry, id).Encode(order)
+
+	c, _ := newCodecs("avro", nil, registry)
+	got, err := DecodeOrder(c, kafka.Message{Value: body})
+	if assert.NoError(t, err) {
+		assert.Equal(t, order, got)
+	}
+
+	c, _ = newCodecs("avro", nil, unavailableRegistry{})
+	_, err = DecodeOrder(c, kafka.Message{Value: body})
+	assert.ErrorIs(t, err, codec.ErrRegistryUnavailable)
+	assert.True(t, retryable(err))
+}
+
+// TestDecodeOrder_JSONSchema проверяет проверку "сырого" JSON по JSON Schema:
+// без неё лишняя проверка не выполняется, с ней некорректный по схеме заказ отклоняется как ErrValidate
+func TestDecodeOrder_JSONSchema(t *testing.T) {
+	// shardkey числом: json.Unmarshal в string упадёт, а схема сообщит о типе поля
+	body := []byte(strings.Replace(validOrderJSON, `"order_uid": "full123"`, `"order_uid": "full123", "shardkey": 7`, 1))
+
+	_, err := DecodeOrder(nil, kafka.Message{Value: body})
+	assert.ErrorIs(t, err, ErrDecode)
+
+	c, _ := NewCodecs(config.KafkaConfig{Codec: "json", ValidateSchema: true})
+
+	_, err = DecodeOrder(c, kafka.Message{Value: body})
+	assert.ErrorIs(t, err, ErrValidate)
+	assert.Contains(t, err.Error(), "shardkey")
+
+	_, err = DecodeOrder(c, kafka.Message{Value: []byte(validOrderJSON)})
+	assert.NoError(t, err)
+}
@@ -2,13 +2,19 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/fathersson/wb-demo-service/internal/cache"
+	"github.com/fathersson/wb-demo-service/internal/codec"
 	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/logger"
 	"github.com/fathersson/wb-demo-service/internal/models"
 	"github.com/fathersson/wb-demo-service/internal/repository"
+	"github.com/fathersson/wb-demo-service/internal/schema"
+	"github.com/fathersson/wb-demo-service/internal/tracing"
 	"github.com/go-playground/validator/v10"
 	"github.com/segmentio/kafka-go"
 )
@@ -21,80 +27,159 @@ type MessageReader interface {
 }
 
 // NewReader создает и возвращает настроенный kafka.Reader
-func NewReader(cfg config.KafkaConfig) *kafka.Reader {
-	log.Println("Создаем Kafka reader")
+// Брокеры, топики, TLS/SASL, размеры fetch и таймауты группы берутся из конфига
+func NewReader(cfg config.KafkaConfig) (*kafka.Reader, error) {
+	slog.Info("Создаем Kafka reader")
+
+	dialer, err := newDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := startOffset(cfg.StartOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Несколько топиков читаются только в consumer group
+	var topic string
+	var groupTopics []string
+	if topics := cfg.ConsumeTopics(); len(topics) > 1 {
+		groupTopics = topics
+	} else {
+		topic = cfg.Topic
+	}
+
 	return kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        []string{cfg.Broker},
-		Topic:          cfg.Topic,
-		GroupID:        cfg.GroupID,
-		StartOffset:    kafka.FirstOffset, // читаем с начала при первом запуске
-		CommitInterval: 0,
-	})
+		Brokers:           cfg.Brokers,
+		Topic:             topic,
+		GroupTopics:       groupTopics,
+		GroupID:           cfg.GroupID,
+		Dialer:            dialer,
+		StartOffset:       offset, // откуда читать при первом запуске группы
+		MinBytes:          cfg.MinBytes,
+		MaxBytes:          cfg.MaxBytes,
+		MaxWait:           cfg.MaxWait,
+		SessionTimeout:    cfg.SessionTimeout,
+		HeartbeatInterval: cfg.HeartbeatInterval,
+		CommitInterval:    0,
+	}), nil
 }
 
-// ConsumeMessages читает сообщения из Kafka
-func ConsumeMessages(reader MessageReader, db repository.OrderRepository, cache cache.CacheInterface, ctx context.Context) {
-	log.Println("Kafka consumer запущен")
-	var order models.Order
-	var validate = validator.New()
-
-	// Читаем сообщения
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Kafka consumer завершен")
-			return
-		default:
-			msg, err := reader.FetchMessage(ctx)
-			if err != nil {
-				log.Println("Ошибка чтения заказа:", err)
-				continue
-			}
+// Ошибки пайплайна обработки сообщения, по ним вызывающий код понимает причину отказа
+var (
+	ErrDecode   = errors.New("ошибка парсинга JSON")
+	ErrValidate = errors.New("сообщение некорректно")
+	ErrSave     = errors.New("ошибка сохранения заказа")
+)
 
-			// Парсим JSON
-			err = json.Unmarshal(msg.Value, &order)
-			if err != nil {
-				log.Println("Ошибка парсинга JSON:", err)
-				// Пропускаем некорректное сообщение, не коммитим
-				continue
-			}
+// validate - общий валидатор, потокобезопасен и кэширует разбор тегов структур
+var validate = validator.New()
 
-			err = validate.Struct(order)
-			if err != nil {
-				log.Printf("Сообщение некорректно, ошибка:%s", err)
-				continue
-			}
-			if len(order.Items) == 0 {
-				log.Println("Сообщение некорректно, нет товаров в заказе")
-				continue
-			}
+// DecodeOrder парсит и валидирует заказ из сообщения Kafka
+// Формат тела (JSON, Protobuf, Avro) выбирается по content-type, топику или конфигу (см. NewCodecs), nil - JSON.
+// JSON-заказ достаётся из конверта (если он есть), доводится апкастерами до текущей версии схемы
+// и, если включено, проверяется по JSON Schema.
+// Каждый вызов декодирует в новый models.Order, поэтому поля одного сообщения не протекают в другое
+func DecodeOrder(codecs *Codecs, msg kafka.Message) (models.Order, error) {
+	return decodeOrder(context.Background(), codecs, msg)
+}
 
-			// Сообщение корректное
-			log.Printf("Получили заказ %s из %s", order.OrderUID, order.Delivery.City)
+// decodeOrder - DecodeOrder со спанами разбора и валидации в трейсе сообщения
+func decodeOrder(ctx context.Context, codecs *Codecs, msg kafka.Message) (order models.Order, err error) {
+	_, span := tracer.Start(ctx, "order.decode")
+	order, err = decodeBody(codecs, msg)
+	tracing.End(span, err)
+	if err != nil {
+		return models.Order{}, err
+	}
 
-			// проводим транзакцию в бд
-			err = db.SaveOrder(ctx, order)
-			if err != nil {
-				log.Println("Ошибка сохранения заказа:", err)
-				continue
-			}
-			log.Printf("Заказ %s сохранен в базе данных", order.OrderUID)
+	_, span = tracer.Start(ctx, "order.validate")
+	defer func() { tracing.End(span, err) }()
+	start := time.Now()
+	defer observe(stageValidate, start)
+	if err := validate.Struct(order); err != nil {
+		return models.Order{}, fmt.Errorf("%w: %v", ErrValidate, err)
+	}
+	if len(order.Items) == 0 {
+		return models.Order{}, fmt.Errorf("%w: нет товаров в заказе", ErrValidate)
+	}
 
-			// Добавляем сообщение в кэш
-			cache.SetCache(order.OrderUID, order)
-			log.Printf("Заказ %s добавлен в кэш", order.OrderUID)
+	return order, nil
+}
 
-			// Посылаем сигнал в Kafka, что мы обработали его сообщение
-			err = reader.CommitMessages(ctx, msg)
-			if err != nil {
-				log.Println("Ошибка коммита сообщения:", err)
-				continue
-			}
-			// Принтуем в консоль
-			log.Printf("Консьюмер кафки обработал заказ %s", order.OrderUID)
+// decodeBody - разбор тела заказа без валидации полей
+func decodeBody(codecs *Codecs, msg kafka.Message) (models.Order, error) {
+	start := time.Now()
+	c, jsonSchema, err := codecs.orderCodec(msg)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
 
-			// Тело заказа
-			log.Printf("Тело заказа: %+v", order)
+	payload := msg.Value
+	if c.Name() == codec.NameJSON {
+		env, err := Unwrap(msg)
+		if err != nil {
+			return models.Order{}, err
+		}
+		if payload, err = upcastOrder(env); err != nil {
+			return models.Order{}, err
+		}
+
+		// Проверка "сырого" JSON по тому же контракту, что отдаётся продюсерам
+		if jsonSchema {
+			if err := schema.ValidateOrder(payload); err != nil {
+				return models.Order{}, fmt.Errorf("%w: JSON Schema: %v", ErrValidate, err)
+			}
 		}
 	}
+
+	// Парсим тело
+	order, err := c.Decode(payload)
+	observe(stageDecode, start)
+	if err != nil {
+		return models.Order{}, decodeError(err)
+	}
+	return order, nil
+}
+
+// ProcessMessage - пайплайн обработки одного сообщения: парсинг, валидация, сохранение в БД и кэш
+// Коммит в Kafka остаётся на вызывающем коде, чтобы он сам решал, когда подтверждать сообщение
+func ProcessMessage(ctx context.Context, msg kafka.Message, codecs *Codecs, db repository.OrderRepository, cache cache.CacheInterface) (models.Order, error) {
+	order, err := decodeOrder(ctx, codecs, msg)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	// Сообщение корректное
+	l := msgLog(msg).With(logger.KeyOrderUID, order.OrderUID)
+	l.Info("Получили заказ", "city", order.Delivery.City)
+
+	// проводим транзакцию в бд
+	start := time.Now()
+	err = db.SaveOrder(ctx, order)
+	observe(stageSave, start)
+	switch {
+	case errors.Is(err, repository.ErrDuplicate):
+		// Повтор уже сохранённого заказа: например, падение между сохранением и отметкой дедупликации.
+		// Кэш не трогаем: в БД осталась прежняя версия, а статус в кэше мог уже смениться
+		messagesDuplicate.Inc(msg.Topic)
+		l.Info("Заказ уже сохранён, повтор подтверждаем")
+		return order, nil
+	case err != nil:
+		return models.Order{}, fmt.Errorf("%w: %w", ErrSave, err)
+	default:
+		l.Info("Заказ сохранен в базе данных")
+	}
+
+	// Добавляем сообщение в кэш
+	setCache(ctx, cache, order)
+	l.Debug("Заказ добавлен в кэш", "order", order)
+
+	return order, nil
+}
+
+// ConsumeMessages читает сообщения из Kafka последовательно, одним воркером
+// Для параллельной обработки используйте NewConsumer с настройками из конфига
+func ConsumeMessages(reader MessageReader, db repository.OrderRepository, cache cache.CacheInterface, ctx context.Context) {
+	NewConsumer(reader, db, cache, config.KafkaConfig{}).Run(ctx)
 }
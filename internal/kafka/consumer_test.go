@@ -3,13 +3,18 @@ package kafka
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/fathersson/wb-demo-service/internal/cache"
 	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
 	kafkamocks "github.com/fathersson/wb-demo-service/internal/kafka/kafkamocks"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
 	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
 	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -398,3 +403,177 @@ func TestConsumeMessages_BadContext(t *testing.T) {
 	repo.AssertExpectations(t)
 	cache.AssertExpectations(t)
 }
+
+// validOrderJSON - полностью корректный заказ, после которого идут частичные сообщения
+const validOrderJSON = `{
+	"order_uid": "full123",
+	"track_number": "TRACK001",
+	"delivery": {"name":"Ivan","phone":"+79990000000","zip":"123456","city":"Moscow","address":"Street 1"},
+	"payment": {"transaction":"full123","currency":"RUB","provider":"bank","amount":1000,"payment_dt":1234567890,"delivery_cost":200,"goods_total":800},
+	"items": [{"chrt_id":1,"name":"Item","price":100,"total_price":100}]
+}`
+
+// TestDecodeOrder_NoCrossContamination проверяет, что поля предыдущего сообщения не протекают в следующее
+// 1) Сначала декодируем полностью корректный заказ
+// 2) Затем частичное сообщение, в котором нет одного из обязательных блоков
+// 3) Частичное сообщение должно быть отклонено валидацией, а не унаследовать поля первого заказа
+func TestDecodeOrder_NoCrossContamination(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial string
+	}{
+		{
+			name: "без items",
+			partial: `{"order_uid":"part1","track_number":"T2",
+				"delivery": {"name":"Petr","phone":"+7","zip":"654321","city":"Kazan","address":"Street 2"},
+				"payment": {"transaction":"part1","currency":"RUB","provider":"bank","amount":1,"payment_dt":1,"delivery_cost":1,"goods_total":1}}`,
+		},
+		{
+			name: "без delivery",
+			partial: `{"order_uid":"part2","track_number":"T2",
+				"payment": {"transaction":"part2","currency":"RUB","provider":"bank","amount":1,"payment_dt":1,"delivery_cost":1,"goods_total":1},
+				"items": [{"chrt_id":2,"name":"Other","price":1,"total_price":1}]}`,
+		},
+		{
+			name: "без payment",
+			partial: `{"order_uid":"part3","track_number":"T2",
+				"delivery": {"name":"Petr","phone":"+7","zip":"654321","city":"Kazan","address":"Street 2"},
+				"items": [{"chrt_id":2,"name":"Other","price":1,"total_price":1}]}`,
+		},
+		{
+			name:    "только order_uid",
+			partial: `{"order_uid":"part4"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full, err := DecodeOrder(nil, kafka.Message{Value: []byte(validOrderJSON)})
+			assert.NoError(t, err)
+			assert.Equal(t, "full123", full.OrderUID)
+
+			got, err := DecodeOrder(nil, kafka.Message{Value: []byte(tt.partial)})
+			assert.ErrorIs(t, err, ErrValidate)
+			assert.Empty(t, got.OrderUID)
+			assert.Empty(t, got.Items)
+		})
+	}
+}
+
+// TestDecodeOrder_Errors проверяет, что причины отказа различимы через errors.Is
+func TestDecodeOrder_Errors(t *testing.T) {
+	_, err := DecodeOrder(nil, kafka.Message{Value: []byte("{bad json")})
+	assert.ErrorIs(t, err, ErrDecode)
+
+	_, err = DecodeOrder(nil, kafka.Message{Value: []byte(`{}`)})
+	assert.ErrorIs(t, err, ErrValidate)
+}
+
+// TestProcessMessage_SaveError проверяет, что ошибка БД возвращается как ErrSave и кэш не трогается
+func TestProcessMessage_SaveError(t *testing.T) {
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.AnythingOfType("models.Order")).
+		Return(errors.New("db down")).
+		Once()
+
+	_, err := ProcessMessage(context.Background(), kafka.Message{Value: []byte(validOrderJSON)}, nil, repo, cache)
+	assert.ErrorIs(t, err, ErrSave)
+	cache.AssertNotCalled(t, "SetCache", mock.Anything, mock.Anything)
+}
+
+// TestProcessMessage_DuplicateKeepsCache
+// Проверяет повтор уже сохранённого заказа: сообщение подтверждается, но кэш остаётся как был -
+// ни старая версия заказа, ни сброс статуса, уже выставленного StatusHandler
+func TestProcessMessage_DuplicateKeepsCache(t *testing.T) {
+	msg := kafka.Message{Value: []byte(validOrderJSON)}
+	cached, err := DecodeOrder(nil, msg)
+	assert.NoError(t, err)
+	cached.TrackNumber = "NEWTRACK"
+	cached.Items[0].Status = 404
+
+	c := cache.NewCache()
+	c.SetCache(cached.OrderUID, cached)
+	repo := repomocks.NewOrderRepository(t)
+	repo.EXPECT().SaveOrder(mock.Anything, mock.Anything).
+		Return(fmt.Errorf("%w: %w: duplicate key", repository.ErrInternal, repository.ErrDuplicate)).Once()
+
+	_, err = ProcessMessage(context.Background(), msg, nil, repo, c)
+	assert.NoError(t, err)
+	got, ok := c.GetCache(cached.OrderUID)
+	assert.True(t, ok)
+	assert.Equal(t, cached, got)
+}
+
+// TestHandleBatch_DuplicateKeepsCache проверяет то же для поштучного сохранения после неудачной пачки:
+// дубль подтверждается без записи в кэш, новый заказ кэшируется
+func TestHandleBatch_DuplicateKeepsCache(t *testing.T) {
+	msgs := []kafka.Message{orderMessage("d1", 0, 0), orderMessage("d2", 0, 1)}
+	cached, err := DecodeOrder(nil, msgs[0])
+	assert.NoError(t, err)
+	cached.Items[0].Status = 404
+
+	c := cache.NewCache()
+	c.SetCache("d1", cached)
+	repo := repomocks.NewOrderRepository(t)
+	repo.EXPECT().SaveOrders(mock.Anything, mock.Anything).Return(assert.AnError).Once()
+	repo.EXPECT().SaveOrder(mock.Anything, mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "d1" })).
+		Return(fmt.Errorf("%w: %w: duplicate key", repository.ErrInternal, repository.ErrDuplicate)).Once()
+	repo.EXPECT().SaveOrder(mock.Anything, mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "d2" })).
+		Return(nil).Once()
+
+	h := &OrderHandler{db: repo, cache: c}
+	assert.Equal(t, []error{nil, nil}, h.HandleBatch(context.Background(), msgs))
+
+	got, _ := c.GetCache("d1")
+	assert.Equal(t, cached, got)
+	_, ok := c.GetCache("d2")
+	assert.True(t, ok)
+}
+
+// TestConsumeMessages_PartialAfterFull - регрессия на общий models.Order между итерациями цикла
+// 1) Первым приходит корректный заказ - сохраняется, кладётся в кэш и коммитится
+// 2) Вторым приходит заказ без items - раньше он наследовал items первого заказа и проходил валидацию
+// 3) Второй заказ НЕ должен попасть ни в БД, ни в кэш, ни в коммит
+func TestConsumeMessages_PartialAfterFull(t *testing.T) {
+	reader := kafkamocks.NewMessageReader(t)
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	fullMsg := kafka.Message{Offset: 1, Value: []byte(validOrderJSON)}
+	partialMsg := kafka.Message{Offset: 2, Value: []byte(`{
+		"order_uid": "part1",
+		"track_number": "TRACK002",
+		"delivery": {"name":"Petr","phone":"+79990000001","zip":"654321","city":"Kazan","address":"Street 2"},
+		"payment": {"transaction":"part1","currency":"RUB","provider":"bank","amount":1,"payment_dt":1,"delivery_cost":1,"goods_total":1}
+	}`)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reader.EXPECT().FetchMessage(mock.Anything).Return(fullMsg, nil).Once()
+	reader.EXPECT().
+		FetchMessage(mock.Anything).
+		Return(partialMsg, nil).
+		Once().
+		Run(func(args mock.Arguments) { cancel() })
+	reader.EXPECT().
+		FetchMessage(mock.Anything).
+		Return(kafka.Message{}, context.Canceled).
+		Maybe()
+
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "full123" })).
+		Return(nil).
+		Once()
+	cache.EXPECT().SetCache("full123", mock.AnythingOfType("models.Order")).Return().Once()
+	reader.EXPECT().CommitMessages(mock.Anything, fullMsg).Return(nil).Once()
+
+	ConsumeMessages(reader, repo, cache, ctx)
+
+	repo.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "part1" }))
+	cache.AssertNotCalled(t, "SetCache", "part1", mock.Anything)
+	reader.AssertNotCalled(t, "CommitMessages", mock.Anything, partialMsg)
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	"github.com/segmentio/kafka-go"
+)
+
+// dedupPurgeInterval - как часто удаляются просроченные отметки об обработке
+const dedupPurgeInterval = time.Hour
+
+// EventID - идентификатор события для дедупликации
+// Берётся event_id из конверта или заголовка, а если продюсер его не прислал - хэш ключа и тела сообщения
+func EventID(msg kafka.Message) string {
+	if id, ok := header(msg, HeaderEventID); ok && id != "" {
+		return id
+	}
+	if env, err := Unwrap(msg); err == nil && env.EventID != "" {
+		return env.EventID
+	}
+
+	h := sha256.New()
+	h.Write(msg.Key)
+	h.Write([]byte{0})
+	h.Write(msg.Value)
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Deduplicate включает дедупликацию: уже обработанные события подтверждаются без вызова обработчика
+// Вызывается до Run
+func (c *Consumer) Deduplicate(store repository.DedupRepository) {
+	c.dedup = store
+}
+
+// handleOnce вызывает обработчик только для новых событий и отмечает успешно обработанные
+// Между сохранением и отметкой есть окно: при падении в нём событие придёт снова, SaveOrder
+// ответит repository.ErrDuplicate, и обработчик заказов подтвердит его как уже обработанное
+func (c *Consumer) handleOnce(ctx context.Context, h Handler, msgs []kafka.Message) []error {
+	if c.dedup == nil {
+		return c.handle(ctx, h, msgs)
+	}
+
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = EventID(msg)
+	}
+
+	seen, err := c.dedup.ProcessedEvents(ctx, ids)
+	if err != nil {
+		// Без проверки дублей обрабатываем как обычно: повторная обработка лучше потери сообщения
+		slog.Warn("Ошибка проверки дублей, обрабатываем без неё", logger.Err(err))
+		seen = nil
+	}
+
+	errs := make([]error, len(msgs))
+	first := make(map[string]int) // событие -> первое сообщение с ним в пачке
+	dups := make(map[int]int)     // повтор внутри пачки -> первое сообщение
+	var fresh []kafka.Message
+	var idx []int
+	for i, msg := range msgs {
+		if seen[ids[i]] {
+			messagesDuplicate.Inc(msg.Topic)
+			msgLog(msg).Info("Дубль события, пропускаем", "event_id", ids[i])
+			continue
+		}
+		if j, ok := first[ids[i]]; ok {
+			messagesDuplicate.Inc(msg.Topic)
+			dups[i] = j
+			continue
+		}
+		first[ids[i]] = i
+		fresh = append(fresh, msg)
+		idx = append(idx, i)
+	}
+	if len(fresh) == 0 {
+		return errs
+	}
+
+	for j, err := range c.handle(ctx, h, fresh) {
+		errs[idx[j]] = err
+	}
+	// Повтор внутри пачки разделяет судьбу первого сообщения
+	for i, j := range dups {
+		errs[i] = errs[j]
+	}
+
+	var done []string
+	for _, i := range idx {
+		if errs[i] == nil {
+			done = append(done, ids[i])
+		}
+	}
+	if len(done) > 0 {
+		if err := c.dedup.MarkProcessed(context.WithoutCancel(ctx), done); err != nil {
+			slog.Error("Ошибка отметки обработанных событий", logger.Err(err))
+		}
+	}
+	return errs
+}
+
+// DedupPurger - периодически удаляет отметки об обработке старше ttl, пока не отменится контекст
+func DedupPurger(repo repository.DedupRepository, ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(dedupPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := repo.PurgeProcessed(ctx, time.Now().Add(-ttl))
+			if err != nil {
+				slog.Error("Ошибка очистки отметок дедупликации", logger.Err(err))
+				continue
+			}
+			if n > 0 {
+				slog.Info("Удалены просроченные отметки дедупликации", "deleted", n)
+			}
+		}
+	}
+}
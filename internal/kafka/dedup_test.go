@@ -0,0 +1,141 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// withEventID - сообщение с заголовком event_id
+func withEventID(msg kafka.Message, id string) kafka.Message {
+	msg.Headers = append(msg.Headers, kafka.Header{Key: HeaderEventID, Value: []byte(id)})
+	return msg
+}
+
+// TestEventID проверяет источники идентификатора: заголовок, конверт, хэш ключа и тела
+func TestEventID(t *testing.T) {
+	assert.Equal(t, "e1", EventID(withEventID(orderMessage("a", 0, 0), "e1")))
+
+	env := kafka.Message{Value: []byte(`{"schema_version":1,"event_id":"e2","payload":{}}`)}
+	assert.Equal(t, "e2", EventID(env))
+
+	a := EventID(orderMessage("a", 0, 0))
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, a)
+	assert.Equal(t, a, EventID(orderMessage("a", 1, 7)), "хэш не зависит от партиции и оффсета")
+	assert.NotEqual(t, a, EventID(orderMessage("b", 0, 0)))
+}
+
+// TestConsumer_Dedup проверяет дедупликацию:
+// 1) Событие e1 уже обработано - подтверждается без SaveOrder
+// 2) e2 приходит дважды в одной пачке - сохраняется один раз
+// 3) Успешно обработанное e2 отмечается, коммитится последний оффсет
+func TestConsumer_Dedup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{msgs: []kafka.Message{
+		withEventID(orderMessage("d1", 0, 0), "e1"),
+		withEventID(orderMessage("d2", 0, 1), "e2"),
+		withEventID(orderMessage("d2", 0, 2), "e2"),
+	}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	dedup := repomocks.NewDedupRepository(t)
+
+	dedup.EXPECT().
+		ProcessedEvents(mock.Anything, []string{"e1", "e2", "e2"}).
+		Return(map[string]bool{"e1": true}, nil).
+		Once()
+	repo.EXPECT().SaveOrder(mock.Anything, mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "d2" })).Return(nil).Once()
+	cache.EXPECT().SetCache("d2", mock.Anything).Return().Once()
+	dedup.EXPECT().
+		MarkProcessed(mock.Anything, []string{"e2"}).
+		RunAndReturn(func(context.Context, []string) error {
+			cancel()
+			return nil
+		}).
+		Once()
+
+	c := NewConsumer(reader, repo, cache, config.KafkaConfig{BatchSize: 3, BatchTimeout: time.Second})
+	c.Deduplicate(dedup)
+	c.Run(ctx)
+
+	if assert.Len(t, reader.commits, 1) {
+		assert.Equal(t, int64(2), reader.commits[0].Offset)
+	}
+}
+
+// TestConsumer_DedupStoreDown проверяет, что при недоступном хранилище дублей сообщения всё равно обрабатываются
+func TestConsumer_DedupStoreDown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{msgs: []kafka.Message{orderMessage("s1", 0, 0)}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	dedup := repomocks.NewDedupRepository(t)
+
+	dedup.EXPECT().ProcessedEvents(mock.Anything, mock.Anything).Return(nil, assert.AnError).Once()
+	repo.EXPECT().SaveOrder(mock.Anything, mock.Anything).Return(nil).Once()
+	cache.EXPECT().SetCache("s1", mock.Anything).Return().Once()
+	dedup.EXPECT().
+		MarkProcessed(mock.Anything, mock.Anything).
+		RunAndReturn(func(context.Context, []string) error {
+			cancel()
+			return assert.AnError
+		}).
+		Once()
+
+	c := NewConsumer(reader, repo, cache, config.KafkaConfig{})
+	c.Deduplicate(dedup)
+	c.Run(ctx)
+
+	if assert.Len(t, reader.commits, 1) {
+		assert.Equal(t, int64(0), reader.commits[0].Offset)
+	}
+}
+
+// TestConsumer_DedupAfterCrash проверяет повтор после падения между сохранением и отметкой:
+// отметки нет, SaveOrder отвечает ErrDuplicate - событие подтверждается и отмечается с одной попытки,
+// без повторов, без ошибки для предохранителя и без записи в кэш
+func TestConsumer_DedupAfterCrash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{msgs: []kafka.Message{withEventID(orderMessage("c1", 0, 0), "e1")}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	dedup := repomocks.NewDedupRepository(t)
+
+	dedup.EXPECT().ProcessedEvents(mock.Anything, []string{"e1"}).Return(map[string]bool{}, nil).Once()
+	repo.EXPECT().SaveOrder(mock.Anything, mock.Anything).
+		Return(fmt.Errorf("%w: %w: duplicate key", repository.ErrInternal, repository.ErrDuplicate)).Once()
+	dedup.EXPECT().
+		MarkProcessed(mock.Anything, []string{"e1"}).
+		RunAndReturn(func(context.Context, []string) error {
+			cancel()
+			return nil
+		}).
+		Once()
+
+	c := NewConsumer(reader, repo, cache, config.KafkaConfig{})
+	c.Deduplicate(dedup)
+	b := NewBreaker(nil, 1, time.Second)
+	c.UseBreaker(b)
+	c.Run(ctx)
+
+	assert.Equal(t, BreakerClosed, b.Status().State)
+	if assert.Len(t, reader.commits, 1) {
+		assert.Equal(t, int64(0), reader.commits[0].Offset)
+	}
+}
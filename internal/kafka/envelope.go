@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки с метаданными для сообщений без конверта в теле
+const (
+	HeaderSchemaVersion = "schema_version"
+	HeaderEventID       = "event_id"
+	HeaderProducedAt    = "produced_at" // RFC 3339
+	HeaderSource        = "source"
+)
+
+// OrderSchemaVersion - текущая версия схемы заказа (models.Order)
+// Сообщения без версии считаются версией 1 - исходным форматом "сырого" JSON заказа
+const OrderSchemaVersion = 1
+
+// ErrSchemaVersion - версия схемы не поддерживается: новее текущей или для неё нет апкастера
+var ErrSchemaVersion = errors.New("неподдерживаемая версия схемы")
+
+// Upcaster переводит JSON заказа из версии N в версию N+1
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+var (
+	upcastersMu sync.RWMutex
+	upcasters   = make(map[int]Upcaster) // версия, из которой переводит апкастер -> апкастер
+)
+
+// RegisterUpcaster регистрирует перевод заказа из версии from в from+1
+// Регистрировать нужно при старте, до запуска консьюмера
+func RegisterUpcaster(from int, fn Upcaster) {
+	upcastersMu.Lock()
+	defer upcastersMu.Unlock()
+	upcasters[from] = fn
+}
+
+// Unwrap достаёт метаданные и payload сообщения
+// Если тело - конверт (есть schema_version и payload), берём всё из него, иначе тело - payload, а метаданные - из заголовков
+func Unwrap(msg kafka.Message) (models.Envelope, error) {
+	var probe struct {
+		SchemaVersion *int            `json:"schema_version"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(msg.Value, &probe); err == nil && probe.SchemaVersion != nil && probe.Payload != nil {
+		var env models.Envelope
+		if err := json.Unmarshal(msg.Value, &env); err != nil {
+			return models.Envelope{}, fmt.Errorf("%w: конверт: %v", ErrDecode, err)
+		}
+		if env.ProducedAt.IsZero() {
+			env.ProducedAt = msg.Time
+		}
+		return env, nil
+	}
+
+	env := models.Envelope{
+		SchemaVersion: 1,
+		ProducedAt:    msg.Time,
+		Payload:       msg.Value,
+	}
+	if v, ok := header(msg, HeaderSchemaVersion); ok {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return models.Envelope{}, fmt.Errorf("%w: заголовок %s=%q", ErrSchemaVersion, HeaderSchemaVersion, v)
+		}
+		env.SchemaVersion = version
+	}
+	if v, ok := header(msg, HeaderProducedAt); ok {
+		at, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return models.Envelope{}, fmt.Errorf("%w: заголовок %s=%q", ErrDecode, HeaderProducedAt, v)
+		}
+		env.ProducedAt = at
+	}
+	env.EventID, _ = header(msg, HeaderEventID)
+	env.Source, _ = header(msg, HeaderSource)
+	return env, nil
+}
+
+// EnvelopeHeaders - заголовки с метаданными конверта для продюсеров, которые шлют "сырой" JSON
+func EnvelopeHeaders(env models.Envelope) []kafka.Header {
+	headers := []kafka.Header{
+		{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(env.SchemaVersion))},
+		{Key: HeaderProducedAt, Value: []byte(env.ProducedAt.UTC().Format(time.RFC3339Nano))},
+	}
+	if env.EventID != "" {
+		headers = append(headers, kafka.Header{Key: HeaderEventID, Value: []byte(env.EventID)})
+	}
+	if env.Source != "" {
+		headers = append(headers, kafka.Header{Key: HeaderSource, Value: []byte(env.Source)})
+	}
+	return headers
+}
+
+// upcastOrder доводит payload заказа до OrderSchemaVersion зарегистрированными апкастерами
+func upcastOrder(env models.Envelope) (json.RawMessage, error) {
+	upcastersMu.RLock()
+	defer upcastersMu.RUnlock()
+	return upcast(env.Payload, env.SchemaVersion, OrderSchemaVersion, upcasters)
+}
+
+// upcast последовательно применяет апкастеры from -> from+1 -> ... -> to
+func upcast(payload json.RawMessage, from, to int, chain map[int]Upcaster) (json.RawMessage, error) {
+	if from < 1 || from > to {
+		return nil, fmt.Errorf("%w: v%d, поддерживается до v%d", ErrSchemaVersion, from, to)
+	}
+	for v := from; v < to; v++ {
+		fn, ok := chain[v]
+		if !ok {
+			return nil, fmt.Errorf("%w: нет апкастера v%d -> v%d", ErrSchemaVersion, v, v+1)
+		}
+		next, err := fn(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: апкастер v%d -> v%d: %v", ErrDecode, v, v+1, err)
+		}
+		payload = next
+	}
+	return payload, nil
+}
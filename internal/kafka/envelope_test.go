@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUnwrap_Envelope проверяет конверт в теле: метаданные и payload берутся из него
+func TestUnwrap_Envelope(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	body := `{"schema_version":1,"event_id":"ev1","produced_at":"2024-05-01T12:00:00Z","source":"shop","payload":` + validOrderJSON + `}`
+
+	env, err := Unwrap(kafka.Message{Value: []byte(body)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, env.SchemaVersion)
+		assert.Equal(t, "ev1", env.EventID)
+		assert.Equal(t, "shop", env.Source)
+		assert.True(t, at.Equal(env.ProducedAt))
+		assert.JSONEq(t, validOrderJSON, string(env.Payload))
+	}
+}
+
+// TestUnwrap_Headers проверяет "сырой" JSON: метаданные из заголовков, без заголовков - версия 1 и время сообщения
+func TestUnwrap_Headers(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	headers := EnvelopeHeaders(models.Envelope{SchemaVersion: 1, EventID: "ev2", ProducedAt: at, Source: "generator"})
+
+	env, err := Unwrap(kafka.Message{Value: []byte(validOrderJSON), Headers: headers})
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.Envelope{
+			SchemaVersion: 1,
+			EventID:       "ev2",
+			ProducedAt:    at,
+			Source:        "generator",
+			Payload:       json.RawMessage(validOrderJSON),
+		}, env)
+	}
+
+	msgTime := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	env, err = Unwrap(kafka.Message{Value: []byte(validOrderJSON), Time: msgTime})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, env.SchemaVersion)
+		assert.Empty(t, env.EventID)
+		assert.Equal(t, msgTime, env.ProducedAt)
+	}
+
+	_, err = Unwrap(kafka.Message{Value: []byte(validOrderJSON), Headers: []kafka.Header{{Key: HeaderSchemaVersion, Value: []byte("v2")}}})
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+}
+
+// TestUpcast проверяет цепочку апкастеров:
+// 1) v1 -> v3 проходит через оба апкастера по порядку
+// 2) Текущая версия возвращается как есть
+// 3) Версия новее текущей и разрыв в цепочке - ErrSchemaVersion
+func TestUpcast(t *testing.T) {
+	chain := map[int]Upcaster{
+		1: func(p json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(strings.Replace(string(p), `"uid"`, `"order_uid"`, 1)), nil
+		},
+		2: func(p json.RawMessage) (json.RawMessage, error) {
+			return json.RawMessage(strings.Replace(string(p), `}`, `,"locale":"ru"}`, 1)), nil
+		},
+	}
+
+	out, err := upcast(json.RawMessage(`{"uid":"a1"}`), 1, 3, chain)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"order_uid":"a1","locale":"ru"}`, string(out))
+	}
+
+	out, err = upcast(json.RawMessage(`{"order_uid":"a1"}`), 3, 3, chain)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"order_uid":"a1"}`, string(out))
+	}
+
+	_, err = upcast(json.RawMessage(`{}`), 4, 3, chain)
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+
+	delete(chain, 2)
+	_, err = upcast(json.RawMessage(`{}`), 1, 3, chain)
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+}
+
+// TestDecodeOrder_Envelope проверяет декодирование заказа из конверта и отказ от версии новее текущей
+func TestDecodeOrder_Envelope(t *testing.T) {
+	body := `{"schema_version":1,"event_id":"ev1","payload":` + validOrderJSON + `}`
+	order, err := DecodeOrder(nil, kafka.Message{Value: []byte(body)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "full123", order.OrderUID)
+	}
+
+	body = `{"schema_version":2,"payload":` + validOrderJSON + `}`
+	_, err = DecodeOrder(nil, kafka.Message{Value: []byte(body)})
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+	assert.False(t, retryable(err))
+}
@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// TypeHeader - заголовок сообщения с его типом, по нему выбирается обработчик
+const TypeHeader = "type"
+
+// Типы сообщений в заголовке TypeHeader
+const (
+	TypeOrder        = "order"         // новый заказ
+	TypeOrderStatus  = "order.status"  // смена статуса товаров заказа
+	TypeOrderDeleted = "order.deleted" // удаление заказа
+)
+
+// ErrNoHandler - для сообщения не зарегистрирован обработчик
+var ErrNoHandler = errors.New("нет обработчика для сообщения")
+
+// Handler - логика одного типа сообщений: парсинг, валидация и сохранение
+// Коммит, повторы и остановку берёт на себя Consumer
+type Handler interface {
+	Handle(ctx context.Context, msg kafka.Message) error
+}
+
+// BatchHandler - обработчик, который умеет сохранять пачку сообщений разом
+// Возвращает ошибку для каждого сообщения пачки (nil - обработано)
+type BatchHandler interface {
+	Handler
+	HandleBatch(ctx context.Context, msgs []kafka.Message) []error
+}
+
+// HandlerFunc - функция как Handler
+type HandlerFunc func(ctx context.Context, msg kafka.Message) error
+
+func (f HandlerFunc) Handle(ctx context.Context, msg kafka.Message) error {
+	return f(ctx, msg)
+}
+
+// Registry - реестр обработчиков по типу сообщения (заголовок type) или по топику
+// Тип из заголовка важнее топика, так в одном топике можно возить сообщения разных типов
+type Registry struct {
+	byType   map[string]Handler
+	byTopic  map[string]Handler
+	fallback Handler
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		byType:  make(map[string]Handler),
+		byTopic: make(map[string]Handler),
+	}
+}
+
+// HandleType регистрирует обработчик для типа сообщения
+func (r *Registry) HandleType(msgType string, h Handler) {
+	r.byType[msgType] = h
+}
+
+// HandleTopic регистрирует обработчик для всех сообщений топика без заголовка type
+func (r *Registry) HandleTopic(topic string, h Handler) {
+	r.byTopic[topic] = h
+}
+
+// HandleDefault регистрирует обработчик для сообщений без заголовка type из незарегистрированных топиков
+func (r *Registry) HandleDefault(h Handler) {
+	r.fallback = h
+}
+
+// Resolve находит обработчик для сообщения
+func (r *Registry) Resolve(msg kafka.Message) (Handler, error) {
+	_, h, err := r.resolve(msg)
+	return h, err
+}
+
+// resolve - обработчик и ключ, по которому он найден
+// По ключу Consumer собирает подряд идущие сообщения одного обработчика в пачку
+func (r *Registry) resolve(msg kafka.Message) (string, Handler, error) {
+	if msgType, ok := header(msg, TypeHeader); ok {
+		if h, ok := r.byType[msgType]; ok {
+			return "type:" + msgType, h, nil
+		}
+		return "", nil, fmt.Errorf("%w: тип %q", ErrNoHandler, msgType)
+	}
+	if h, ok := r.byTopic[msg.Topic]; ok {
+		return "topic:" + msg.Topic, h, nil
+	}
+	if r.fallback != nil {
+		return "default", r.fallback, nil
+	}
+	return "", nil, fmt.Errorf("%w: топик %q", ErrNoHandler, msg.Topic)
+}
+
+// header - значение заголовка сообщения по ключу
+func header(msg kafka.Message, key string) (string, bool) {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+// retryable - имеет ли смысл повторять обработку: битые сообщения от повтора не исправятся,
+// как и ошибки сохранения из-за самих данных (нарушение ограничений) - повторяются только временные ошибки БД
+func retryable(err error) bool {
+	return err != nil &&
+		!errors.Is(err, ErrDecode) &&
+		!errors.Is(err, ErrValidate) &&
+		!errors.Is(err, ErrSchemaVersion) &&
+		!errors.Is(err, ErrNoHandler) &&
+		!errors.Is(err, ErrNotFound) &&
+		!errors.Is(err, repository.ErrInternal) &&
+		!errors.Is(err, context.Canceled)
+}
+
+// msgLog - логгер с атрибутами сообщения: топик, партиция и оффсет
+func msgLog(msg kafka.Message) *slog.Logger {
+	return slog.With(logger.KeyTopic, msg.Topic, logger.KeyPartition, msg.Partition, logger.KeyOffset, msg.Offset)
+}
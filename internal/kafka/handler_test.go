@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestRegistry_Resolve проверяет выбор обработчика:
+// заголовок type важнее топика, неизвестный тип не уходит в обработчик по умолчанию
+func TestRegistry_Resolve(t *testing.T) {
+	var called string
+	named := func(name string) Handler {
+		return HandlerFunc(func(context.Context, kafka.Message) error {
+			called = name
+			return nil
+		})
+	}
+
+	r := NewRegistry()
+	r.HandleType(TypeOrderStatus, named("status"))
+	r.HandleTopic("orders", named("orders"))
+
+	tests := []struct {
+		name string
+		msg  kafka.Message
+		want string
+	}{
+		{"по топику", kafka.Message{Topic: "orders"}, "orders"},
+		{"тип важнее топика", kafka.Message{Topic: "orders", Headers: []kafka.Header{{Key: TypeHeader, Value: []byte(TypeOrderStatus)}}}, "status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := r.Resolve(tt.msg)
+			if assert.NoError(t, err) {
+				assert.NoError(t, h.Handle(context.Background(), tt.msg))
+				assert.Equal(t, tt.want, called)
+			}
+		})
+	}
+
+	_, err := r.Resolve(kafka.Message{Topic: "unknown"})
+	assert.ErrorIs(t, err, ErrNoHandler)
+
+	r.HandleDefault(named("default"))
+	_, err = r.Resolve(kafka.Message{Topic: "orders", Headers: []kafka.Header{{Key: TypeHeader, Value: []byte("payment")}}})
+	assert.ErrorIs(t, err, ErrNoHandler)
+
+	h, err := r.Resolve(kafka.Message{Topic: "unknown"})
+	if assert.NoError(t, err) {
+		assert.NoError(t, h.Handle(context.Background(), kafka.Message{}))
+		assert.Equal(t, "default", called)
+	}
+}
+
+// TestStatusHandler проверяет смену статуса: в БД и в закэшированной копии заказа
+func TestStatusHandler(t *testing.T) {
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	h := &StatusHandler{db: repo, cache: cache}
+
+	repo.EXPECT().UpdateOrderStatus(mock.Anything, "test123", 202).Return(nil).Once()
+	cache.EXPECT().GetCache("test123").
+		Return(models.Order{OrderUID: "test123", Items: []models.Item{{ChrtID: 1}, {ChrtID: 2}}}, true).Once()
+	cache.EXPECT().
+		SetCache("test123", mock.MatchedBy(func(o models.Order) bool {
+			return len(o.Items) == 2 && o.Items[0].Status == 202 && o.Items[1].Status == 202
+		})).
+		Return().Once()
+
+	err := h.Handle(context.Background(), kafka.Message{Value: []byte(`{"order_uid":"test123","status":202}`)})
+	assert.NoError(t, err)
+}
+
+// TestStatusHandler_Errors проверяет ошибки: битое сообщение не доходит до БД, отсутствующий заказ - ErrNotFound
+func TestStatusHandler_Errors(t *testing.T) {
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	h := &StatusHandler{db: repo, cache: cache}
+
+	err := h.Handle(context.Background(), kafka.Message{Value: []byte(`{"status":202}`)})
+	assert.ErrorIs(t, err, ErrValidate)
+
+	repo.EXPECT().UpdateOrderStatus(mock.Anything, "nope", 202).Return(sql.ErrNoRows).Once()
+	err = h.Handle(context.Background(), kafka.Message{Value: []byte(`{"order_uid":"nope","status":202}`)})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.False(t, retryable(err))
+
+	// Ошибка в запросе или данных не повторяется, временная недоступность БД - повторяется
+	repo.EXPECT().UpdateOrderStatus(mock.Anything, "bad", 202).Return(fmt.Errorf("%w: check violation", repository.ErrInternal)).Once()
+	err = h.Handle(context.Background(), kafka.Message{Value: []byte(`{"order_uid":"bad","status":202}`)})
+	assert.ErrorIs(t, err, ErrSave)
+	assert.False(t, retryable(err))
+
+	repo.EXPECT().UpdateOrderStatus(mock.Anything, "down", 202).Return(fmt.Errorf("%w: connection refused", repository.ErrUnavailable)).Once()
+	err = h.Handle(context.Background(), kafka.Message{Value: []byte(`{"order_uid":"down","status":202}`)})
+	assert.True(t, retryable(err))
+}
+
+// TestDeleteHandler проверяет удаление заказа из БД и кэша
+func TestDeleteHandler(t *testing.T) {
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	h := &DeleteHandler{db: repo, cache: cache}
+
+	repo.EXPECT().DeleteOrder(mock.Anything, "test123").Return(nil).Once()
+	cache.EXPECT().DeleteCache("test123").Return().Once()
+
+	err := h.Handle(context.Background(), kafka.Message{Value: []byte(`{"order_uid":"test123"}`)})
+	assert.NoError(t, err)
+}
+
+// TestConsumer_MultiTopic проверяет чтение нескольких топиков одним консьюмером:
+// 1) Заказ, смена статуса и удаление уходят каждый своему обработчику
+// 2) Временная ошибка БД на смене статуса повторяется, со второй попытки статус сохраняется
+// 3) Сообщение неизвестного типа не обрабатывается и само не коммитится
+func TestConsumer_MultiTopic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := config.KafkaConfig{
+		Topic:         "orders",
+		StatusTopic:   "orders.status",
+		DeleteTopic:   "orders.deleted",
+		RetryAttempts: 2,
+		RetryBackoff:  time.Millisecond,
+	}
+	reader := &fakeReader{msgs: []kafka.Message{
+		orderMessage("m1", 0, 0),
+		{Topic: "orders.status", Partition: 0, Offset: 0, Value: []byte(`{"order_uid":"m1","status":202}`)},
+		{Topic: "orders", Partition: 0, Offset: 1, Headers: []kafka.Header{{Key: TypeHeader, Value: []byte("payment")}}},
+		{Topic: "orders.deleted", Partition: 0, Offset: 0, Value: []byte(`{"order_uid":"m1"}`)},
+	}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	repo.EXPECT().SaveOrder(mock.Anything, mock.AnythingOfType("models.Order")).Return(nil).Once()
+	cache.EXPECT().SetCache("m1", mock.Anything).Return()
+	repo.EXPECT().UpdateOrderStatus(mock.Anything, "m1", 202).Return(assert.AnError).Once()
+	repo.EXPECT().UpdateOrderStatus(mock.Anything, "m1", 202).Return(nil).Once()
+	cache.EXPECT().GetCache("m1").Return(models.Order{}, false).Once()
+	repo.EXPECT().DeleteOrder(mock.Anything, "m1").RunAndReturn(func(context.Context, string) error {
+		cancel()
+		return nil
+	}).Once()
+	cache.EXPECT().DeleteCache("m1").Return().Once()
+
+	NewConsumer(reader, repo, cache, cfg).Run(ctx)
+
+	last := make(map[string]int64)
+	for _, c := range reader.commits {
+		last[c.Topic] = c.Offset
+	}
+	assert.Equal(t, map[string]int64{"orders": 0, "orders.status": 0, "orders.deleted": 0}, last)
+}
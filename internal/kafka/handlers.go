@@ -0,0 +1,208 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/cache"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrNotFound - сообщение ссылается на заказ, которого нет в базе
+var ErrNotFound = errors.New("заказ не найден")
+
+// DefaultRegistry - обработчики сервиса: заказы (и их повторы из DLQ), статусы и удаления
+// Топики, не заданные в конфиге, не регистрируются, сообщения без типа из прочих топиков считаются заказами
+func DefaultRegistry(db repository.OrderRepository, cache cache.CacheInterface, codecs *Codecs, cfg config.KafkaConfig) *Registry {
+	orders := &OrderHandler{db: db, cache: cache, codecs: codecs}
+	statuses := &StatusHandler{db: db, cache: cache}
+	deletions := &DeleteHandler{db: db, cache: cache}
+
+	r := NewRegistry()
+	r.HandleType(TypeOrder, orders)
+	r.HandleType(TypeOrderStatus, statuses)
+	r.HandleType(TypeOrderDeleted, deletions)
+	r.HandleDefault(orders)
+
+	topics := []struct {
+		topic   string
+		handler Handler
+	}{
+		{cfg.Topic, orders},
+		{cfg.RetryTopic, orders},
+		{cfg.StatusTopic, statuses},
+		{cfg.DeleteTopic, deletions},
+	}
+	for _, t := range topics {
+		if t.topic != "" {
+			r.HandleTopic(t.topic, t.handler)
+		}
+	}
+	return r
+}
+
+// OrderHandler - новые заказы: парсинг, валидация, сохранение в БД и кэш
+type OrderHandler struct {
+	db     repository.OrderRepository
+	cache  cache.CacheInterface
+	codecs *Codecs // nil - JSON
+}
+
+func (h *OrderHandler) Handle(ctx context.Context, msg kafka.Message) error {
+	_, err := ProcessMessage(ctx, msg, h.codecs, h.db, h.cache)
+	return err
+}
+
+// HandleBatch валидирует пачку и сохраняет корректные заказы одной транзакцией через SaveOrders
+// Если пачка не сохранилась, заказы сохраняются по одному, чтобы один плохой заказ не блокировал остальные
+func (h *OrderHandler) HandleBatch(ctx context.Context, msgs []kafka.Message) []error {
+	errs := make([]error, len(msgs))
+
+	// Одно сообщение обрабатываем обычным пайплайном
+	if len(msgs) == 1 {
+		errs[0] = h.Handle(ctx, msgs[0])
+		return errs
+	}
+
+	// Некорректные сообщения отсеиваем до БД
+	var orders []models.Order
+	var valid []int
+	for i, msg := range msgs {
+		order, err := decodeOrder(extract(ctx, msg), h.codecs, msg)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		orders = append(orders, order)
+		valid = append(valid, i)
+	}
+	if len(orders) == 0 {
+		return errs
+	}
+
+	start := time.Now()
+	err := h.db.SaveOrders(ctx, orders)
+	observe(stageSave, start)
+	saved := make([]bool, len(orders)) // заказ записан этим вызовом, а не подтверждён как дубль
+	if err != nil {
+		slog.Warn("Ошибка сохранения пачки заказов, сохраняем по одному", "orders", len(orders), logger.Err(err))
+		for j, i := range valid {
+			start := time.Now()
+			err := h.db.SaveOrder(ctx, orders[j])
+			observe(stageSave, start)
+			switch {
+			case errors.Is(err, repository.ErrDuplicate):
+				messagesDuplicate.Inc(msgs[i].Topic)
+				msgLog(msgs[i]).Info("Заказ уже сохранён, повтор подтверждаем", logger.KeyOrderUID, orders[j].OrderUID)
+			case err != nil:
+				errs[i] = fmt.Errorf("%w: %w", ErrSave, err)
+			default:
+				saved[j] = true
+			}
+		}
+	} else {
+		slog.Info("Пачка заказов сохранена в базе данных", "orders", len(orders))
+		for j := range saved {
+			saved[j] = true
+		}
+	}
+
+	// Добавляем сохранённые заказы в кэш, дубли его не меняют
+	for j := range valid {
+		if saved[j] {
+			setCache(ctx, h.cache, orders[j])
+		}
+	}
+
+	return errs
+}
+
+// StatusHandler - смена статуса товаров заказа в БД и в кэше
+type StatusHandler struct {
+	db    repository.OrderRepository
+	cache cache.CacheInterface
+}
+
+func (h *StatusHandler) Handle(ctx context.Context, msg kafka.Message) error {
+	var update models.OrderStatusUpdate
+	if err := decodeValid(msg, &update); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err := h.db.UpdateOrderStatus(ctx, update.OrderUID, update.Status)
+	observe(stageSave, start)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrNotFound, update.OrderUID)
+		}
+		return fmt.Errorf("%w: %w", ErrSave, err)
+	}
+
+	// Обновляем закэшированную копию, если она есть
+	if order, ok := h.cache.GetCache(update.OrderUID); ok {
+		items := make([]models.Item, len(order.Items))
+		for i, item := range order.Items {
+			item.Status = update.Status
+			items[i] = item
+		}
+		order.Items = items
+		h.cache.SetCache(order.OrderUID, order)
+	}
+
+	msgLog(msg).Info("Статус заказа изменён", logger.KeyOrderUID, update.OrderUID, "status", update.Status)
+	return nil
+}
+
+// DeleteHandler - удаление заказа из БД и кэша
+type DeleteHandler struct {
+	db    repository.OrderRepository
+	cache cache.CacheInterface
+}
+
+func (h *DeleteHandler) Handle(ctx context.Context, msg kafka.Message) error {
+	var deletion models.OrderDeletion
+	if err := decodeValid(msg, &deletion); err != nil {
+		return err
+	}
+
+	// Из кэша удаляем в любом случае, даже если в БД заказа уже нет
+	start := time.Now()
+	err := h.db.DeleteOrder(ctx, deletion.OrderUID)
+	observe(stageSave, start)
+	h.cache.DeleteCache(deletion.OrderUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrNotFound, deletion.OrderUID)
+		}
+		return fmt.Errorf("%w: %w", ErrSave, err)
+	}
+
+	msgLog(msg).Info("Заказ удалён", logger.KeyOrderUID, deletion.OrderUID)
+	return nil
+}
+
+// decodeValid парсит JSON сообщения (или payload конверта) в v и валидирует его тегами validate
+// У статусов и удалений одна версия схемы, апкастеры к ним не применяются
+func decodeValid(msg kafka.Message, v any) error {
+	env, err := Unwrap(msg)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(env.Payload, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	if err := validate.Struct(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrValidate, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/codec"
+	"github.com/fathersson/wb-demo-service/internal/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// Этапы обработки сообщения для гистограммы длительностей
+const (
+	stageDecode   = "decode"
+	stageValidate = "validate"
+	stageSave     = "save"
+	stageCommit   = "commit"
+)
+
+// Метрики консьюмера и генератора, в реестр их добавляет RegisterMetrics
+var (
+	messagesConsumed = metrics.NewCounter("kafka_consumer_messages_consumed_total",
+		"Прочитано сообщений из Kafka", "topic")
+	messagesAccepted = metrics.NewCounter("kafka_consumer_messages_accepted_total",
+		"Обработано и закоммичено сообщений (включая дубли)", "topic")
+	messagesRejected = metrics.NewCounter("kafka_consumer_messages_rejected_total",
+		"Отброшено некорректных сообщений, повтор не поможет", "topic", "reason")
+	messagesFailed = metrics.NewCounter("kafka_consumer_messages_failed_total",
+		"Не обработано сообщений из-за временных ошибок после всех повторов", "topic", "reason")
+	messagesDuplicate = metrics.NewCounter("kafka_consumer_messages_duplicate_total",
+		"Подтверждено без обработки повторно доставленных сообщений", "topic")
+	consumerLag = metrics.NewGauge("kafka_consumer_lag",
+		"Отставание от конца партиции по последнему прочитанному сообщению", "topic", "partition")
+	stageDuration = metrics.NewHistogram("kafka_consumer_stage_duration_seconds",
+		"Длительность этапов обработки сообщения", nil, "stage")
+	generatorSent = metrics.NewCounter("kafka_generator_messages_total",
+		"Отправлено сообщений генератором", "kind", "result")
+)
+
+// RegisterMetrics добавляет метрики консьюмера и генератора в реестр
+// reader может быть nil, тогда статистика kafka.Reader не выводится
+func RegisterMetrics(reg *metrics.Registry, reader *kafka.Reader) {
+	reg.Register(
+		messagesConsumed,
+		messagesAccepted,
+		messagesRejected,
+		messagesFailed,
+		messagesDuplicate,
+		consumerLag,
+		stageDuration,
+		generatorSent,
+	)
+	if reader != nil {
+		reg.Register(newReaderStats(reader))
+	}
+}
+
+// observe записывает длительность этапа с момента start
+func observe(stage string, start time.Time) {
+	stageDuration.Observe(time.Since(start).Seconds(), stage)
+}
+
+// fetched учитывает прочитанное сообщение и отставание его партиции
+// HighWaterMark - оффсет следующего сообщения, которое будет записано в партицию
+func fetched(msg kafka.Message) {
+	messagesConsumed.Inc(msg.Topic)
+	if msg.HighWaterMark > 0 {
+		consumerLag.Set(float64(max(msg.HighWaterMark-msg.Offset-1, 0)), msg.Topic, strconv.Itoa(msg.Partition))
+	}
+}
+
+// finished учитывает итог обработки сообщения
+func finished(msg kafka.Message, err error) {
+	switch {
+	case err == nil:
+		messagesAccepted.Inc(msg.Topic)
+	case retryable(err):
+		messagesFailed.Inc(msg.Topic, reason(err))
+	default:
+		messagesRejected.Inc(msg.Topic, reason(err))
+	}
+}
+
+// reason - причина отказа для метки метрики
+func reason(err error) string {
+	switch {
+	case errors.Is(err, ErrDecode):
+		return "decode"
+	case errors.Is(err, ErrValidate):
+		return "validate"
+	case errors.Is(err, ErrSchemaVersion):
+		return "schema_version"
+	case errors.Is(err, ErrNoHandler):
+		return "no_handler"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrSave):
+		return "save"
+	case errors.Is(err, codec.ErrRegistryUnavailable):
+		return "schema_registry"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "other"
+}
+
+// readerStats - статистика kafka.Reader в формате метрик
+// Reader.Stats() обнуляет счётчики при каждом вызове, поэтому они накапливаются здесь
+type readerStats struct {
+	reader *kafka.Reader
+
+	mu       sync.Mutex
+	counters map[string]float64
+}
+
+func newReaderStats(reader *kafka.Reader) *readerStats {
+	return &readerStats{reader: reader, counters: make(map[string]float64)}
+}
+
+func (s *readerStats) Name() string { return "kafka_reader" }
+
+func (s *readerStats) Collect(w io.Writer) {
+	stats := s.reader.Stats()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters := []struct {
+		name, help string
+		delta      int64
+	}{
+		{"kafka_reader_messages_total", "Получено сообщений reader'ом", stats.Messages},
+		{"kafka_reader_bytes_total", "Получено байт reader'ом", stats.Bytes},
+		{"kafka_reader_fetches_total", "Запросов fetch к брокерам", stats.Fetches},
+		{"kafka_reader_errors_total", "Ошибок reader'а", stats.Errors},
+		{"kafka_reader_timeouts_total", "Таймаутов reader'а", stats.Timeouts},
+		{"kafka_reader_rebalances_total", "Ребалансировок consumer group", stats.Rebalances},
+	}
+	for _, c := range counters {
+		s.counters[c.name] += float64(c.delta)
+		m := metrics.NewCounter(c.name, c.help)
+		m.Add(s.counters[c.name])
+		m.Collect(w)
+	}
+
+	gauges := []struct {
+		name, help string
+		value      int64
+	}{
+		{"kafka_reader_lag", "Отставание reader'а по данным kafka.Reader", stats.Lag},
+		{"kafka_reader_offset", "Текущий оффсет reader'а", stats.Offset},
+		{"kafka_reader_queue_length", "Сообщений в очереди reader'а", stats.QueueLength},
+		{"kafka_reader_queue_capacity", "Ёмкость очереди reader'а", stats.QueueCapacity},
+	}
+	for _, g := range gauges {
+		m := metrics.NewGauge(g.name, g.help)
+		m.Set(float64(g.value))
+		m.Collect(w)
+	}
+}
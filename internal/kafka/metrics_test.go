@@ -0,0 +1,80 @@
+package kafka
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/metrics"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestConsumer_Metrics проверяет учёт сообщений по итогам обработки:
+// 1) Корректный заказ - accepted, битый JSON - rejected с причиной decode, ошибка БД - failed с причиной save
+// 2) Отставание партиции считается по HighWaterMark последнего прочитанного сообщения
+// 3) Этапы decode, save и commit попадают в гистограмму, метрики видны на /metrics
+func TestConsumer_Metrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const topic = "orders.metrics"
+	msgs := []kafka.Message{
+		orderMessage("m1", 0, 0),
+		{Partition: 0, Offset: 1, Value: []byte("{bad json")},
+		orderMessage("m2", 0, 2),
+	}
+	for i := range msgs {
+		msgs[i].Topic = topic
+		msgs[i].HighWaterMark = 10
+	}
+	reader := &fakeReader{msgs: msgs}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	// Метрики общие для пакета, поэтому сравниваем прирост
+	consumed, accepted := messagesConsumed.Value(topic), messagesAccepted.Value(topic)
+	rejected, failed := messagesRejected.Value(topic, "decode"), messagesFailed.Value(topic, "save")
+	saves, commits := stageDuration.Count(stageSave), stageDuration.Count(stageCommit)
+
+	repo.EXPECT().SaveOrder(mock.Anything, mock.Anything).Return(nil).Once()
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.Anything).
+		RunAndReturn(func(context.Context, models.Order) error {
+			cancel()
+			return assert.AnError
+		}).
+		Once()
+	cache.EXPECT().SetCache("m1", mock.Anything).Return().Once()
+
+	NewConsumer(reader, repo, cache, config.KafkaConfig{}).Run(ctx)
+
+	assert.Equal(t, consumed+3, messagesConsumed.Value(topic))
+	assert.Equal(t, accepted+1, messagesAccepted.Value(topic))
+	assert.Equal(t, rejected+1, messagesRejected.Value(topic, "decode"))
+	assert.Equal(t, failed+1, messagesFailed.Value(topic, "save"))
+	assert.Equal(t, 7.0, consumerLag.Value(topic, "0"))
+	assert.Equal(t, saves+2, stageDuration.Count(stageSave))
+	assert.Greater(t, stageDuration.Count(stageCommit), commits)
+
+	registry := metrics.NewRegistry()
+	RegisterMetrics(registry, nil)
+	w := httptest.NewRecorder()
+	registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `kafka_consumer_messages_rejected_total{topic="orders.metrics",reason="decode"} `)
+	assert.Contains(t, w.Body.String(), `kafka_consumer_stage_duration_seconds_bucket{stage="save",le="+Inf"}`)
+}
+
+// TestReason проверяет причины отказа в метках метрик
+func TestReason(t *testing.T) {
+	assert.Equal(t, "decode", reason(ErrDecode))
+	assert.Equal(t, "other", reason(assert.AnError))
+	assert.Equal(t, "canceled", reason(context.Canceled))
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// topicPartition - ключ партиции в трекере оффсетов
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// partitionOffsets - оффсеты одной партиции в порядке чтения и итоги их обработки
+type partitionOffsets struct {
+	pending  []int64                  // прочитанные, но ещё не закоммиченные оффсеты
+	finished map[int64]*kafka.Message // обработанные: nil - с ошибкой, не коммитим
+}
+
+// offsetTracker следит, чтобы оффсет коммитился только после обработки всех предыдущих
+// Сообщения с ошибкой не коммитятся сами, но и не блокируют коммит следующих -
+// так же, как в последовательном консьюмере
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[topicPartition]*partitionOffsets)}
+}
+
+// track регистрирует прочитанное сообщение, вызывается в порядке чтения
+func (t *offsetTracker) track(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{finished: make(map[int64]*kafka.Message)}
+		t.partitions[key] = p
+	}
+	p.pending = append(p.pending, msg.Offset)
+}
+
+// done отмечает сообщение обработанным и возвращает сообщение, которое теперь можно закоммитить
+// Возвращает false, если коммитить пока нечего (есть необработанные оффсеты раньше или всё с ошибкой)
+func (t *offsetTracker) done(msg kafka.Message, ok bool) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, exists := t.partitions[topicPartition{topic: msg.Topic, partition: msg.Partition}]
+	if !exists {
+		return kafka.Message{}, false
+	}
+	if ok {
+		p.finished[msg.Offset] = &msg
+	} else {
+		p.finished[msg.Offset] = nil
+	}
+
+	// Сдвигаемся по непрерывному префиксу обработанных оффсетов
+	var commit *kafka.Message
+	for len(p.pending) > 0 {
+		res, finished := p.finished[p.pending[0]]
+		if !finished {
+			break
+		}
+		if res != nil {
+			commit = res
+		}
+		delete(p.finished, p.pending[0])
+		p.pending = p.pending[1:]
+	}
+
+	if commit == nil {
+		return kafka.Message{}, false
+	}
+	return *commit, true
+}
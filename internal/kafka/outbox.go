@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	"github.com/fathersson/wb-demo-service/internal/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// outboxBatch - сколько событий outbox публикуется за один WriteMessages
+const outboxBatch = 100
+
+// OutboxRelay - периодически публикует события из outbox в Kafka, пока не отменится контекст
+// Доставка at-least-once: событие помечается отправленным только после успешной записи в Kafka,
+// поэтому при падении между записью и пометкой оно уйдёт повторно
+func OutboxRelay(writer MessageWriter, repo repository.OutboxRepository, ctx context.Context, interval time.Duration) {
+	slog.Info("Outbox relay запущен")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Outbox relay остановлен")
+			return
+		case <-ticker.C:
+			if err := relayOutbox(ctx, writer, repo); err != nil {
+				slog.Error("Ошибка публикации outbox", logger.Err(err))
+			}
+		}
+	}
+}
+
+// relayOutbox публикует все накопившиеся события пачками по outboxBatch
+func relayOutbox(ctx context.Context, writer MessageWriter, repo repository.OutboxRepository) error {
+	for {
+		events, err := repo.FetchOutbox(ctx, outboxBatch)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения outbox: %w", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		msgs := make([]kafka.Message, len(events))
+		ids := make([]int64, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+			// Ключ - order_uid, чтобы события одного заказа шли в одну партицию
+			msgs[i] = kafka.Message{
+				Key:   []byte(e.OrderUID),
+				Value: e.Payload,
+				Headers: []kafka.Header{
+					{Key: HeaderEventID, Value: []byte(strconv.FormatInt(e.ID, 10))},
+					{Key: "event_type", Value: []byte(e.EventType)},
+				},
+			}
+		}
+
+		if err := publishOutbox(ctx, writer, msgs); err != nil {
+			return fmt.Errorf("ошибка публикации событий outbox: %w", err)
+		}
+		if err := repo.MarkOutboxSent(ctx, ids); err != nil {
+			return fmt.Errorf("ошибка пометки событий outbox отправленными: %w", err)
+		}
+		slog.Info("Опубликованы события outbox", "events", len(events))
+
+		if len(events) < outboxBatch {
+			return nil
+		}
+	}
+}
+
+// publishOutbox отправляет пачку событий под одним спаном, traceparent которого уходит в заголовках каждого события
+func publishOutbox(ctx context.Context, writer MessageWriter, msgs []kafka.Message) error {
+	ctx, span := tracer.Start(ctx, "outbox publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation.type", "send"),
+			attribute.Int("messaging.batch.message_count", len(msgs)),
+		))
+	for i := range msgs {
+		Inject(ctx, &msgs[i])
+	}
+	err := writer.WriteMessages(ctx, msgs...)
+	tracing.End(span, err)
+	return err
+}
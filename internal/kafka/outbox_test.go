@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	kafkamocks "github.com/fathersson/wb-demo-service/internal/kafka/kafkamocks"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestRelayOutbox_Success проверяет публикацию событий outbox:
+// 1) FetchOutbox возвращает два события
+// 2) Они публикуются одним WriteMessages с ключом order_uid и заголовком event_id
+// 3) Затем оба помечаются отправленными
+func TestRelayOutbox_Success(t *testing.T) {
+	writer := kafkamocks.NewMessageWriter(t)
+	repo := repomocks.NewOutboxRepository(t)
+
+	events := []models.OutboxEvent{
+		{ID: 1, EventType: models.EventOrderStored, OrderUID: "id1", Payload: []byte(`{"order_uid":"id1"}`)},
+		{ID: 2, EventType: models.EventOrderStored, OrderUID: "id2", Payload: []byte(`{"order_uid":"id2"}`)},
+	}
+	repo.EXPECT().FetchOutbox(mock.Anything, outboxBatch).Return(events, nil).Once()
+
+	writer.EXPECT().
+		WriteMessages(mock.Anything, mock.Anything, mock.Anything).
+		Run(func(_ context.Context, msgs ...kafka.Message) {
+			assert.Equal(t, []byte("id1"), msgs[0].Key)
+			assert.Equal(t, []byte(`{"order_uid":"id2"}`), msgs[1].Value)
+			assert.Contains(t, msgs[1].Headers, kafka.Header{Key: "event_id", Value: []byte("2")})
+		}).
+		Return(nil).
+		Once()
+
+	repo.EXPECT().MarkOutboxSent(mock.Anything, []int64{1, 2}).Return(nil).Once()
+
+	err := relayOutbox(context.Background(), writer, repo)
+	assert.NoError(t, err)
+}
+
+// TestRelayOutbox_WriteError проверяет at-least-once:
+// если запись в Kafka не удалась, события НЕ помечаются отправленными и уйдут при следующем проходе
+func TestRelayOutbox_WriteError(t *testing.T) {
+	writer := kafkamocks.NewMessageWriter(t)
+	repo := repomocks.NewOutboxRepository(t)
+
+	events := []models.OutboxEvent{{ID: 1, EventType: models.EventOrderStored, OrderUID: "id1"}}
+	repo.EXPECT().FetchOutbox(mock.Anything, outboxBatch).Return(events, nil).Once()
+	writer.EXPECT().WriteMessages(mock.Anything, mock.Anything).Return(assert.AnError).Once()
+
+	err := relayOutbox(context.Background(), writer, repo)
+	assert.ErrorIs(t, err, assert.AnError)
+	repo.AssertNotCalled(t, "MarkOutboxSent", mock.Anything, mock.Anything)
+}
+
+// TestRelayOutbox_Empty проверяет, что при пустом outbox в Kafka ничего не пишется
+func TestRelayOutbox_Empty(t *testing.T) {
+	writer := kafkamocks.NewMessageWriter(t)
+	repo := repomocks.NewOutboxRepository(t)
+
+	repo.EXPECT().FetchOutbox(mock.Anything, outboxBatch).Return(nil, nil).Once()
+
+	err := relayOutbox(context.Background(), writer, repo)
+	assert.NoError(t, err)
+	writer.AssertNotCalled(t, "WriteMessages", mock.Anything)
+}
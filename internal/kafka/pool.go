@@ -0,0 +1,398 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/cache"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	"github.com/fathersson/wb-demo-service/internal/tracing"
+	"github.com/segmentio/kafka-go"
+)
+
+// Режимы распределения сообщений по воркерам
+const (
+	OrderingPartition = "partition" // все сообщения одной партиции обрабатывает один воркер
+	OrderingKey       = "key"       // все сообщения с одним ключом (order_uid) обрабатывает один воркер
+)
+
+// queueSize - размер очереди каждого воркера, при заполнении чтение из Kafka притормаживает
+const queueSize = 16
+
+// defaultDrainTimeout - сколько ждём дообработки уже прочитанных сообщений при остановке
+const defaultDrainTimeout = 10 * time.Second
+
+// defaultBatchTimeout - сколько воркер добирает пачку, если размер пачки больше одного
+const defaultBatchTimeout = 100 * time.Millisecond
+
+// Consumer - пул воркеров, обрабатывающих сообщения Kafka параллельно
+// Порядок сохраняется внутри партиции (или ключа), оффсеты коммитятся строго по порядку.
+// Сообщения раздаются обработчикам из реестра по типу или топику, коммит, повторы и остановка общие
+type Consumer struct {
+	reader        MessageReader
+	db            repository.OrderRepository
+	cache         cache.CacheInterface
+	handlers      *Registry
+	dedup         repository.DedupRepository // nil - без дедупликации
+	breaker       *Breaker                   // nil - без предохранителя БД
+	codecs        *Codecs                    // nil - тело заказа читается как JSON
+	retryAttempts int
+	retryBackoff  time.Duration
+	workers       int
+	ordering      string
+	drainTimeout  time.Duration
+	batchSize     int
+	batchTimeout  time.Duration
+	replayIdle    time.Duration // сколько Replay ждёт следующего сообщения партиции
+}
+
+// result - итог обработки одного сообщения воркером
+type result struct {
+	msg kafka.Message
+	err error
+}
+
+// Option - настройка консьюмера, применяется в NewConsumer до создания обработчиков
+type Option func(c *Consumer)
+
+// WithCodecs задаёт форматы тела сообщений с заказами, без опции всё читается как JSON
+func WithCodecs(codecs *Codecs) Option {
+	return func(c *Consumer) {
+		c.codecs = codecs
+	}
+}
+
+// NewConsumer создаёт пул воркеров по настройкам из конфига с обработчиками DefaultRegistry
+// Нулевые значения заменяются безопасными: один воркер, порядок по партициям, без пачек и повторов
+func NewConsumer(reader MessageReader, db repository.OrderRepository, cache cache.CacheInterface, cfg config.KafkaConfig, opts ...Option) *Consumer {
+	c := &Consumer{
+		reader:        reader,
+		db:            db,
+		cache:         cache,
+		retryAttempts: cfg.RetryAttempts,
+		retryBackoff:  cfg.RetryBackoff,
+		workers:       cfg.Workers,
+		ordering:      cfg.Ordering,
+		drainTimeout:  cfg.DrainTimeout,
+		batchSize:     cfg.BatchSize,
+		batchTimeout:  cfg.BatchTimeout,
+		replayIdle:    replayIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.handlers = DefaultRegistry(db, cache, c.codecs, cfg)
+	if c.workers < 1 {
+		c.workers = 1
+	}
+	if c.ordering == "" {
+		c.ordering = OrderingPartition
+	}
+	if c.drainTimeout <= 0 {
+		c.drainTimeout = defaultDrainTimeout
+	}
+	if c.batchSize < 1 {
+		c.batchSize = 1
+	}
+	if c.batchTimeout <= 0 {
+		c.batchTimeout = defaultBatchTimeout
+	}
+	if c.retryAttempts < 1 {
+		c.retryAttempts = 1
+	}
+	return c
+}
+
+// Handlers - реестр обработчиков, в него можно добавить свои типы и топики до Run
+func (c *Consumer) Handlers() *Registry {
+	return c.handlers
+}
+
+// UseBreaker включает предохранитель БД: при недоступной базе чтение останавливается до её восстановления
+// Вызывается до Run
+func (c *Consumer) UseBreaker(b *Breaker) {
+	c.breaker = b
+}
+
+// Run читает сообщения и раздаёт их воркерам, пока не отменится контекст
+// После отмены новые сообщения не читаются, а уже прочитанные дообрабатываются и коммитятся
+func (c *Consumer) Run(ctx context.Context) {
+	slog.Info("Kafka consumer запущен", "workers", c.workers, "ordering", c.ordering, "batch_size", c.batchSize)
+
+	// workCtx не отменяется вместе с ctx, чтобы дренаж не падал на отменённом контексте,
+	// но ограничен drainTimeout после остановки
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	offsets := newOffsetTracker()
+	results := make(chan []result, c.workers)
+
+	// Пинг БД при открытом предохранителе, живёт до конца дренажа
+	if c.breaker != nil {
+		go c.breaker.Run(workCtx)
+	}
+
+	// Запускаем воркеров, у каждого своя очередь - так сохраняется порядок
+	var wg sync.WaitGroup
+	queues := make([]chan kafka.Message, c.workers)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, queueSize)
+		wg.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer wg.Done()
+			c.work(workCtx, queue, results)
+		}(queues[i])
+	}
+
+	// Коммиттер один, поэтому оффсеты одной партиции никогда не откатываются назад
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		c.commitLoop(workCtx, offsets, results)
+	}()
+
+	c.fetchLoop(ctx, offsets, queues)
+
+	// Дренаж: закрываем очереди и ждём воркеров не дольше drainTimeout
+	slog.Info("Kafka consumer останавливается, дообрабатываем прочитанные сообщения")
+	for _, queue := range queues {
+		close(queue)
+	}
+	timer := time.AfterFunc(c.drainTimeout, cancelWork)
+	defer timer.Stop()
+
+	wg.Wait()
+	close(results)
+	<-committed
+
+	slog.Info("Kafka consumer завершен")
+}
+
+// fetchLoop читает сообщения и раскладывает их по очередям воркеров
+func (c *Consumer) fetchLoop(ctx context.Context, offsets *offsetTracker, queues []chan kafka.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// Пока БД недоступна, новые сообщения не читаем
+		if err := c.breaker.Wait(ctx); err != nil {
+			return
+		}
+
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Ошибка чтения сообщения из Kafka", logger.Err(err))
+			continue
+		}
+
+		fetched(msg)
+
+		// Сначала регистрируем оффсет, потом отдаём воркеру - иначе коммиттер может его пропустить
+		offsets.track(msg)
+		queues[c.route(msg)] <- msg
+	}
+}
+
+// work обрабатывает сообщения своей очереди строго по порядку
+// Сообщения собираются в пачку до batchSize штук или batchTimeout, пачка обрабатывается целиком
+func (c *Consumer) work(ctx context.Context, queue <-chan kafka.Message, results chan<- []result) {
+	batch := make([]kafka.Message, 0, c.batchSize)
+	for msg := range queue {
+		batch = append(batch[:0], msg)
+		open := c.collect(queue, &batch)
+
+		results <- c.processBatch(ctx, batch)
+		if !open {
+			return
+		}
+	}
+}
+
+// collect добирает пачку из очереди, пока не наберётся batchSize или не истечёт batchTimeout
+// Возвращает false, если очередь закрыта
+func (c *Consumer) collect(queue <-chan kafka.Message, batch *[]kafka.Message) bool {
+	if len(*batch) >= c.batchSize {
+		return true
+	}
+
+	timer := time.NewTimer(c.batchTimeout)
+	defer timer.Stop()
+
+	for len(*batch) < c.batchSize {
+		select {
+		case msg, ok := <-queue:
+			if !ok {
+				return false
+			}
+			*batch = append(*batch, msg)
+		case <-timer.C:
+			return true
+		}
+	}
+	return true
+}
+
+// processBatch делит пачку на подряд идущие сообщения одного обработчика и обрабатывает их по порядку,
+// так сообщения разных типов из одной партиции не переставляются местами
+func (c *Consumer) processBatch(ctx context.Context, msgs []kafka.Message) []result {
+	results := make([]result, len(msgs))
+
+	for start := 0; start < len(msgs); {
+		key, h, err := c.handlers.resolve(msgs[start])
+		if err != nil {
+			msgLog(msgs[start]).Warn("Нет обработчика для сообщения", logger.Err(err))
+			results[start] = result{msg: msgs[start], err: err}
+			start++
+			continue
+		}
+
+		end := start + 1
+		for end < len(msgs) {
+			next, _, err := c.handlers.resolve(msgs[end])
+			if err != nil || next != key {
+				break
+			}
+			end++
+		}
+
+		for i, err := range c.handleOnce(ctx, h, msgs[start:end]) {
+			if err != nil {
+				msgLog(msgs[start+i]).Warn("Ошибка обработки сообщения", logger.Err(err))
+			}
+			results[start+i] = result{msg: msgs[start+i], err: err}
+		}
+		start = end
+	}
+
+	return results
+}
+
+// handle вызывает обработчик для сообщений одного типа, пачкой если он это умеет
+// Временные ошибки (например, БД) повторяются до retryAttempts раз с нарастающей паузой
+func (c *Consumer) handle(ctx context.Context, h Handler, msgs []kafka.Message) []error {
+	errs := make([]error, len(msgs))
+
+	// Пачку повторяем после обработки целиком: сообщения пачки независимы
+	if bh, ok := h.(BatchHandler); ok && len(msgs) > 1 {
+		if err := c.breaker.Wait(ctx); err != nil {
+			for i := range errs {
+				errs[i] = err
+			}
+			return errs
+		}
+		ctx, span := startBatch(ctx, msgs)
+		errs = bh.HandleBatch(ctx, msgs)
+		for i, msg := range msgs {
+			c.breaker.Record(errs[i])
+			errs[i] = c.retry(ctx, h, msg, errs[i])
+		}
+		endBatch(span, errs)
+		return errs
+	}
+
+	// Поштучно повторяем сразу, чтобы не обогнать следующее сообщение
+	// Спан сообщения охватывает и повторы
+	for i, msg := range msgs {
+		ctx, span := startConsume(ctx, msg)
+		errs[i] = c.retry(ctx, h, msg, c.call(ctx, h, msg))
+		tracing.End(span, errs[i])
+	}
+	return errs
+}
+
+// call - один вызов обработчика: ждёт, пока БД доступна, и сообщает итог предохранителю
+func (c *Consumer) call(ctx context.Context, h Handler, msg kafka.Message) error {
+	if err := c.breaker.Wait(ctx); err != nil {
+		return err
+	}
+	err := h.Handle(ctx, msg)
+	c.breaker.Record(err)
+	return err
+}
+
+// retry повторяет обработку сообщения, пока ошибка временная и попытки не кончились
+// Пока предохранитель открыт, попытки не тратятся: сообщение ждёт восстановления БД
+func (c *Consumer) retry(ctx context.Context, h Handler, msg kafka.Message, err error) error {
+	for attempt := 1; retryable(err) && ctx.Err() == nil; {
+		if c.breaker.Open() {
+			msgLog(msg).Warn("Сообщение ждёт восстановления БД")
+		} else {
+			if attempt >= c.retryAttempts {
+				break
+			}
+			msgLog(msg).Warn("Повтор обработки сообщения", "attempt", attempt, "max_attempts", c.retryAttempts-1, logger.Err(err))
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(c.retryBackoff * time.Duration(attempt)):
+			}
+			attempt++
+		}
+		err = c.call(ctx, h, msg)
+	}
+	return err
+}
+
+// commitLoop коммитит оффсеты, как только все предыдущие сообщения партиции обработаны
+// Оффсеты всех партиций, продвинувшихся после пачки, коммитятся одним вызовом
+func (c *Consumer) commitLoop(ctx context.Context, offsets *offsetTracker, results <-chan []result) {
+	for batch := range results {
+		var commits []kafka.Message
+		latest := make(map[topicPartition]int)
+
+		for _, res := range batch {
+			finished(res.msg, res.err)
+			if res.err == nil {
+				msgLog(res.msg).Info("Консьюмер кафки обработал сообщение")
+			}
+
+			msg, ok := offsets.done(res.msg, res.err == nil)
+			if !ok {
+				continue
+			}
+
+			// По каждой партиции коммитим только самый свежий оффсет
+			key := topicPartition{topic: msg.Topic, partition: msg.Partition}
+			if i, seen := latest[key]; seen {
+				commits[i] = msg
+				continue
+			}
+			latest[key] = len(commits)
+			commits = append(commits, msg)
+		}
+		if len(commits) == 0 {
+			continue
+		}
+
+		// Посылаем сигнал в Kafka, что мы обработали сообщения партиций до этих оффсетов включительно
+		start := time.Now()
+		if err := c.reader.CommitMessages(ctx, commits...); err != nil {
+			slog.Error("Ошибка коммита сообщений", logger.Err(err))
+		}
+		observe(stageCommit, start)
+	}
+}
+
+// route выбирает воркера для сообщения в зависимости от режима порядка
+func (c *Consumer) route(msg kafka.Message) int {
+	h := fnv.New32a()
+	if c.ordering == OrderingKey && len(msg.Key) > 0 {
+		h.Write(msg.Key)
+	} else {
+		fmt.Fprintf(h, "%s/%d", msg.Topic, msg.Partition)
+	}
+	return int(h.Sum32() % uint32(c.workers))
+}
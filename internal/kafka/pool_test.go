@@ -0,0 +1,305 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeReader - MessageReader поверх среза сообщений, запоминает коммиты
+// Когда сообщения заканчиваются, FetchMessage блокируется до отмены контекста, как настоящий reader
+type fakeReader struct {
+	mu        sync.Mutex
+	msgs      []kafka.Message
+	commits   []kafka.Message
+	onDrained func()
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.msgs) > 0 {
+		msg := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		if len(r.msgs) == 0 && r.onDrained != nil {
+			r.onDrained()
+		}
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits = append(r.commits, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+// orderMessage - корректное сообщение с заказом uid в заданной партиции и оффсете
+func orderMessage(uid string, partition int, offset int64) kafka.Message {
+	return kafka.Message{
+		Topic:     "orders",
+		Partition: partition,
+		Offset:    offset,
+		Key:       []byte(uid),
+		Value:     []byte(strings.ReplaceAll(validOrderJSON, "full123", uid)),
+	}
+}
+
+// TestConsumer_PartitionOrdering проверяет параллельную обработку с сохранением порядка:
+// 1) 3 партиции по 20 сообщений, 4 воркера
+// 2) Внутри каждой партиции SaveOrder вызывается строго по возрастанию оффсетов
+// 3) Коммиты по каждой партиции не откатываются назад, последний коммит - последний оффсет
+func TestConsumer_PartitionOrdering(t *testing.T) {
+	const partitions, perPartition = 3, 20
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{}
+	for off := 0; off < perPartition; off++ {
+		for p := 0; p < partitions; p++ {
+			reader.msgs = append(reader.msgs, orderMessage(fmt.Sprintf("p%do%d", p, off), p, int64(off)))
+		}
+	}
+
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	var mu sync.Mutex
+	saved := make(map[string][]string) // партиция -> order_uid в порядке сохранения
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.AnythingOfType("models.Order")).
+		RunAndReturn(func(_ context.Context, o models.Order) error {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			p := o.OrderUID[:2]
+			saved[p] = append(saved[p], o.OrderUID)
+			if len(saved["p0"])+len(saved["p1"])+len(saved["p2"]) == partitions*perPartition {
+				cancel()
+			}
+			return nil
+		})
+	cache.EXPECT().SetCache(mock.Anything, mock.Anything).Return()
+
+	NewConsumer(reader, repo, cache, config.KafkaConfig{Workers: 4}).Run(ctx)
+
+	for p := 0; p < partitions; p++ {
+		key := fmt.Sprintf("p%d", p)
+		var want []string
+		for off := 0; off < perPartition; off++ {
+			want = append(want, fmt.Sprintf("p%do%d", p, off))
+		}
+		assert.Equal(t, want, saved[key], "порядок сохранения партиции %d", p)
+	}
+
+	last := make(map[int]int64)
+	for _, c := range reader.commits {
+		prev, ok := last[c.Partition]
+		if ok {
+			assert.Greater(t, c.Offset, prev, "коммит партиции %d откатился назад", c.Partition)
+		}
+		last[c.Partition] = c.Offset
+	}
+	for p := 0; p < partitions; p++ {
+		assert.Equal(t, int64(perPartition-1), last[p])
+	}
+}
+
+// TestConsumer_KeyOrderingCommitsInOrder проверяет режим порядка по ключу:
+// 1) Два заказа с разными ключами в одной партиции попадают к разным воркерам
+// 2) Первый (оффсет 0) обрабатывается дольше второго (оффсет 1)
+// 3) Оффсет 1 не коммитится раньше, чем обработан оффсет 0 - коммит один, сразу на оффсет 1
+func TestConsumer_KeyOrderingCommitsInOrder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	c := NewConsumer(nil, repo, cache, config.KafkaConfig{Workers: 2, Ordering: OrderingKey})
+
+	// Подбираем ключи, которые попадают к разным воркерам
+	slow := orderMessage("slow", 0, 0)
+	var fast kafka.Message
+	for i := 0; ; i++ {
+		fast = orderMessage(fmt.Sprintf("fast%d", i), 0, 1)
+		if c.route(fast) != c.route(slow) {
+			break
+		}
+	}
+
+	reader := &fakeReader{msgs: []kafka.Message{slow, fast}}
+
+	fastSaved := make(chan struct{})
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.AnythingOfType("models.Order")).
+		RunAndReturn(func(_ context.Context, o models.Order) error {
+			if o.OrderUID == "slow" {
+				// Медленный заказ ждёт, пока быстрый уже сохранится
+				<-fastSaved
+				cancel()
+				return nil
+			}
+			close(fastSaved)
+			return nil
+		})
+	cache.EXPECT().SetCache(mock.Anything, mock.Anything).Return()
+
+	c.reader = reader
+	c.Run(ctx)
+
+	if assert.Len(t, reader.commits, 1) {
+		assert.Equal(t, int64(1), reader.commits[0].Offset)
+	}
+}
+
+// TestConsumer_DrainOnCancel проверяет graceful shutdown:
+// контекст отменяется сразу после чтения последнего сообщения,
+// но все прочитанные сообщения всё равно сохраняются и коммитятся
+func TestConsumer_DrainOnCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{
+		msgs: []kafka.Message{
+			orderMessage("a1", 0, 0),
+			orderMessage("a2", 0, 1),
+			orderMessage("a3", 0, 2),
+		},
+		onDrained: cancel,
+	}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.AnythingOfType("models.Order")).
+		RunAndReturn(func(ctx context.Context, _ models.Order) error {
+			// Контекст обработки не должен быть отменён вместе с контекстом чтения
+			return ctx.Err()
+		}).
+		Times(3)
+	cache.EXPECT().SetCache(mock.Anything, mock.Anything).Return().Times(3)
+
+	NewConsumer(reader, repo, cache, config.KafkaConfig{Workers: 2}).Run(ctx)
+
+	if assert.NotEmpty(t, reader.commits) {
+		assert.Equal(t, int64(2), reader.commits[len(reader.commits)-1].Offset)
+	}
+}
+
+// TestOffsetTracker проверяет коммит только по непрерывному префиксу обработанных оффсетов
+func TestOffsetTracker(t *testing.T) {
+	tr := newOffsetTracker()
+	m := func(off int64) kafka.Message { return kafka.Message{Topic: "orders", Partition: 0, Offset: off} }
+
+	for off := int64(0); off < 4; off++ {
+		tr.track(m(off))
+	}
+
+	// Оффсет 2 готов раньше 0 и 1 - коммитить нечего
+	_, ok := tr.done(m(2), true)
+	assert.False(t, ok)
+
+	// Оффсет 0 с ошибкой - сам не коммитится
+	_, ok = tr.done(m(0), false)
+	assert.False(t, ok)
+
+	// Оффсет 1 готов - префикс 0..2 обработан, коммитим последний успешный (2)
+	msg, ok := tr.done(m(1), true)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), msg.Offset)
+
+	// Оффсет 3 с ошибкой - коммитить нечего
+	_, ok = tr.done(m(3), false)
+	assert.False(t, ok)
+}
+
+// TestConsumer_Batch проверяет пакетную обработку:
+// 1) 3 корректных заказа и 1 битое сообщение попадают в одну пачку
+// 2) Корректные заказы сохраняются одним вызовом SaveOrders, SaveOrder не вызывается
+// 3) Оффсеты коммитятся одним вызовом CommitMessages на последний оффсет партиции
+func TestConsumer_Batch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{msgs: []kafka.Message{
+		orderMessage("b1", 0, 0),
+		orderMessage("b2", 0, 1),
+		{Topic: "orders", Partition: 0, Offset: 2, Value: []byte("{bad json")},
+		orderMessage("b3", 0, 3),
+	}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	repo.EXPECT().
+		SaveOrders(mock.Anything, mock.MatchedBy(func(orders []models.Order) bool { return len(orders) == 3 })).
+		RunAndReturn(func(context.Context, []models.Order) error {
+			cancel()
+			return nil
+		}).
+		Once()
+	cache.EXPECT().SetCache(mock.Anything, mock.Anything).Return().Times(3)
+
+	NewConsumer(reader, repo, cache, config.KafkaConfig{BatchSize: 4, BatchTimeout: time.Second}).Run(ctx)
+
+	if assert.Len(t, reader.commits, 1) {
+		assert.Equal(t, int64(3), reader.commits[0].Offset)
+	}
+}
+
+// TestConsumer_BatchFallback проверяет откат на поштучное сохранение:
+// 1) SaveOrders для пачки возвращает ошибку
+// 2) Каждый заказ сохраняется через SaveOrder, второй из них падает
+// 3) Первый и третий попадают в кэш, коммитится последний оффсет (упавший заказ не блокирует остальные)
+func TestConsumer_BatchFallback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reader := &fakeReader{msgs: []kafka.Message{
+		orderMessage("f1", 0, 0),
+		orderMessage("f2", 0, 1),
+		orderMessage("f3", 0, 2),
+	}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	repo.EXPECT().SaveOrders(mock.Anything, mock.Anything).Return(assert.AnError).Once()
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.AnythingOfType("models.Order")).
+		RunAndReturn(func(_ context.Context, o models.Order) error {
+			switch o.OrderUID {
+			case "f2":
+				return assert.AnError
+			case "f3":
+				cancel()
+			}
+			return nil
+		}).
+		Times(3)
+	cache.EXPECT().SetCache("f1", mock.Anything).Return().Once()
+	cache.EXPECT().SetCache("f3", mock.Anything).Return().Once()
+
+	NewConsumer(reader, repo, cache, config.KafkaConfig{BatchSize: 3, BatchTimeout: time.Second}).Run(ctx)
+
+	if assert.Len(t, reader.commits, 1) {
+		assert.Equal(t, int64(2), reader.commits[0].Offset)
+	}
+}
@@ -3,15 +3,18 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"math/rand"
 	"time"
 
 	"github.com/go-faker/faker/v4"
 	"github.com/segmentio/kafka-go"
 
+	"github.com/fathersson/wb-demo-service/internal/codec"
 	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/logger"
 	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/tracing"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.53.5 --name=MessageWriter --output=./kafkamocks --with-expecter
@@ -19,19 +22,46 @@ type MessageWriter interface {
 	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
 }
 
-// NewWriter — создаёт Kafka producer
-func NewWriter(cfg config.KafkaConfig) *kafka.Writer {
+// NewWriter — создаёт Kafka producer для основного топика заказов
+func NewWriter(cfg config.KafkaConfig) (*kafka.Writer, error) {
+	return NewTopicWriter(cfg, cfg.Topic)
+}
+
+// NewTopicWriter — создаёт Kafka producer для заданного топика (например, orders.stored)
+// Брокеры, TLS/SASL, сжатие, подтверждения и батчинг берутся из конфига
+func NewTopicWriter(cfg config.KafkaConfig, topic string) (*kafka.Writer, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := compressionCodec(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	acks, err := requiredAcks(cfg.RequiredAcks)
+	if err != nil {
+		return nil, err
+	}
+
 	return &kafka.Writer{
-		Addr:         kafka.TCP(cfg.Broker),
-		Topic:        cfg.Topic,
+		Addr:         kafka.TCP(cfg.Brokers...),
+		Topic:        topic,
 		Balancer:     &kafka.LeastBytes{},
-		RequiredAcks: kafka.RequireOne,
-	}
+		Transport:    transport,
+		Compression:  codec,
+		RequiredAcks: acks,
+		BatchSize:    cfg.WriterBatchSize,
+		BatchBytes:   cfg.WriterBatchBytes,
+		BatchTimeout: cfg.WriterBatchTimeout,
+	}, nil
 }
 
+// generatorSource - источник сообщений генератора в заголовке source
+const generatorSource = "wb-demo-service/generator"
+
 // Generator - каждые 10 секунд отправляет заказ
 func Generator(writer MessageWriter, ctx context.Context) {
-	log.Println("Kafka producer запущен")
+	slog.Info("Kafka producer запущен")
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
@@ -40,30 +70,51 @@ func Generator(writer MessageWriter, ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Writer остановлен")
+			slog.Info("Writer остановлен")
 			return
 		case <-ticker.C:
-			var data []byte
+			var data, key []byte
 			var err error
+			kind := "valid"
 
 			if sendValid {
 				// генерируем валидный заказ
 				order := generateOrder()
 				data, err = json.Marshal(order)
 				if err != nil {
-					log.Println("Ошибка сериализации:", err)
+					slog.Error("Ошибка сериализации", logger.Err(err))
 					continue
 				}
-				log.Printf("Отправлен ВАЛИДНЫЙ заказ %s", order.OrderUID)
+				// Ключ - order_uid: сообщения одного заказа попадают в одну партицию
+				key = []byte(order.OrderUID)
+				slog.Info("Отправлен ВАЛИДНЫЙ заказ", logger.KeyOrderUID, order.OrderUID)
 			} else {
 				// генерируем битое сообщение
+				kind = "broken"
 				data = BrokenOrder()
-				log.Printf("Отправлено БИТОЕ сообщение: %s", string(data))
+				slog.Info("Отправлено БИТОЕ сообщение", "body", string(data))
 			}
 
-			err = writer.WriteMessages(ctx, kafka.Message{Value: data})
+			// Метаданные передаём заголовками, тело остаётся "сырым" JSON заказа
+			headers := EnvelopeHeaders(models.Envelope{
+				SchemaVersion: OrderSchemaVersion,
+				EventID:       faker.UUIDHyphenated(),
+				ProducedAt:    time.Now(),
+				Source:        generatorSource,
+			})
+
+			headers = append(headers, kafka.Header{Key: codec.ContentTypeHeader, Value: []byte(codec.JSON{}.ContentType())})
+
+			// Каждое сообщение генератора начинает новый трейс, traceparent уходит в заголовках
+			msg := kafka.Message{Key: key, Value: data, Headers: headers}
+			sendCtx, span := startProduce(ctx, &msg)
+			err = writer.WriteMessages(sendCtx, msg)
+			tracing.End(span, err)
 			if err != nil {
-				log.Println("Ошибка отправки:", err)
+				slog.Error("Ошибка отправки", "kind", kind, logger.Err(err))
+				generatorSent.Inc(kind, "error")
+			} else {
+				generatorSent.Inc(kind, "sent")
 			}
 
 			// меняем флаг: следующее сообщение будет другого типа
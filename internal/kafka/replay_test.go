@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// replayFixture - партиция 0 с оффсетами 0..4, где 4 уже за концом диапазона [0, 4)
+// 0 - новый заказ, 1 - битый JSON, 2 - совпадает с БД, 3 - в БД сохранён иначе, 4 - не должен читаться
+func replayFixture(t *testing.T) (ReaderFactory, *repomocks.OrderRepository, *cachemocks.CacheInterface) {
+	msgs := []kafka.Message{
+		orderMessage("new1", 0, 0),
+		{Partition: 0, Offset: 1, Value: []byte("{bad json")},
+		orderMessage("same1", 0, 2),
+		orderMessage("diff1", 0, 3),
+		orderMessage("late1", 0, 4),
+	}
+
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+
+	same, err := DecodeOrder(nil, msgs[2])
+	assert.NoError(t, err)
+	diff, err := DecodeOrder(nil, msgs[3])
+	assert.NoError(t, err)
+	diff.TrackNumber = "OLDTRACK"
+
+	repo.EXPECT().GetOrderById(mock.Anything, "new1").Return(models.Order{}, sql.ErrNoRows).Once()
+	repo.EXPECT().GetOrderById(mock.Anything, "same1").Return(same, nil).Once()
+	repo.EXPECT().GetOrderById(mock.Anything, "diff1").Return(diff, nil).Once()
+
+	open := func(partition int, offset int64) (MessageReader, error) {
+		assert.Equal(t, 0, partition)
+		assert.Equal(t, int64(0), offset)
+		return &fakeReader{msgs: msgs}, nil
+	}
+	return open, repo, cache
+}
+
+// TestReplay_DryRun проверяет dry-run: сообщения проходят пайплайн и сравниваются с БД,
+// но ничего не сохраняется, а чтение останавливается на конце диапазона
+func TestReplay_DryRun(t *testing.T) {
+	open, repo, cache := replayFixture(t)
+
+	c := NewConsumer(nil, repo, cache, config.KafkaConfig{})
+	report, err := c.Replay(context.Background(), open, []PartitionRange{{Partition: 0, From: 0, To: 4}}, false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 4, report.Read)
+	assert.Equal(t, 3, report.Accepted)
+	assert.Equal(t, 1, report.Rejected)
+	assert.Equal(t, map[string]int{"decode": 1}, report.Reasons)
+	assert.Equal(t, 1, report.New)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, 1, report.Changed)
+	assert.Equal(t, 0, report.Written)
+
+	repo.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.Anything)
+	cache.AssertNotCalled(t, "SetCache", mock.Anything, mock.Anything)
+}
+
+// TestReplay_Write проверяет режим записи: новый заказ сохраняется тем же пайплайном,
+// изменившийся в БД не перезаписывается
+func TestReplay_Write(t *testing.T) {
+	open, repo, cache := replayFixture(t)
+
+	repo.EXPECT().
+		SaveOrder(mock.Anything, mock.MatchedBy(func(o models.Order) bool { return o.OrderUID == "new1" })).
+		Return(nil).
+		Once()
+	cache.EXPECT().SetCache("new1", mock.Anything).Return().Once()
+
+	c := NewConsumer(nil, repo, cache, config.KafkaConfig{})
+	report, err := c.Replay(context.Background(), open, []PartitionRange{{Partition: 0, From: 0, To: 4}}, true)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, report.New)
+	assert.Equal(t, 1, report.Written)
+	assert.Equal(t, 1, report.Changed)
+	assert.Equal(t, 0, report.Failed)
+}
+
+// TestReplay_MissingLastOffset проверяет, что переигрывание не зависает, если оффсета To-1 нет
+// (служебная запись транзакции или дыра после компакции): остаток диапазона считается пустым
+func TestReplay_MissingLastOffset(t *testing.T) {
+	c := NewConsumer(nil, nil, nil, config.KafkaConfig{})
+	c.replayIdle = 20 * time.Millisecond
+	open := func(int, int64) (MessageReader, error) {
+		return &fakeReader{msgs: []kafka.Message{
+			{Partition: 0, Offset: 0, Value: []byte("{bad json")},
+			{Partition: 0, Offset: 1, Value: []byte("{bad json")},
+		}}, nil
+	}
+
+	report, err := c.Replay(context.Background(), open, []PartitionRange{{Partition: 0, From: 0, To: 3}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Read)
+	assert.Equal(t, 2, report.Rejected)
+
+	// Отмена самого переигрывания - по-прежнему ошибка
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.Replay(ctx, open, []PartitionRange{{Partition: 0, From: 0, To: 3}}, false)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestReplay_EmptyRange проверяет, что пустой диапазон не открывает reader
+func TestReplay_EmptyRange(t *testing.T) {
+	c := NewConsumer(nil, nil, nil, config.KafkaConfig{})
+	open := func(int, int64) (MessageReader, error) {
+		t.Fatal("reader не должен открываться")
+		return nil, nil
+	}
+
+	report, err := c.Replay(context.Background(), open, []PartitionRange{{Partition: 1, From: 10, To: 10}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.Read)
+}
@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/fathersson/wb-demo-service/internal/cache"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/tracing"
+)
+
+var tracer = tracing.Tracer("github.com/fathersson/wb-demo-service/internal/kafka")
+
+// headerCarrier - заголовки сообщения Kafka для пропагатора W3C (traceparent, tracestate)
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	v, _ := header(kafka.Message{Headers: *c.headers}, key)
+	return v
+}
+
+// Set заменяет заголовок, а не добавляет второй: сообщение могут переотправить с уже проставленным traceparent
+func (c headerCarrier) Set(key, value string) {
+	*c.headers = slices.DeleteFunc(*c.headers, func(h kafka.Header) bool { return h.Key == key })
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// Inject записывает контекст трейса из ctx в заголовки сообщения
+// Его должен вызывать любой продюсер, чтобы консьюмер продолжил тот же трейс
+func Inject(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{&msg.Headers})
+}
+
+// extract - контекст трейса из заголовков сообщения
+func extract(ctx context.Context, msg kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{&msg.Headers})
+}
+
+// messageAttrs - атрибуты сообщения по соглашениям OpenTelemetry для messaging
+func messageAttrs(msg kafka.Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", msg.Topic),
+		attribute.String("messaging.destination.partition.id", strconv.Itoa(msg.Partition)),
+		attribute.Int64("messaging.kafka.offset", msg.Offset),
+		attribute.String("messaging.kafka.message.key", string(msg.Key)),
+	}
+}
+
+// startProduce - спан отправки сообщения; контекст спана записывается в его заголовки
+func startProduce(ctx context.Context, msg *kafka.Message) (context.Context, trace.Span) {
+	ctx, span := tracer.Start(ctx, "send "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation.type", "send"),
+			attribute.String("messaging.kafka.message.key", string(msg.Key)),
+		))
+	Inject(ctx, msg)
+	return ctx, span
+}
+
+// startConsume - спан обработки сообщения, продолжающий трейс продюсера из заголовков
+func startConsume(ctx context.Context, msg kafka.Message) (context.Context, trace.Span) {
+	return tracer.Start(extract(ctx, msg), "process "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(append(messageAttrs(msg), attribute.String("messaging.operation.type", "process"))...))
+}
+
+// startBatch - спан обработки пачки: у сообщений пачки разные трейсы, поэтому они связаны ссылками
+func startBatch(ctx context.Context, msgs []kafka.Message) (context.Context, trace.Span) {
+	links := make([]trace.Link, 0, len(msgs))
+	for _, msg := range msgs {
+		if sc := trace.SpanContextFromContext(extract(ctx, msg)); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+	return tracer.Start(ctx, "process "+msgs[0].Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msgs[0].Topic),
+			attribute.String("messaging.operation.type", "process"),
+			attribute.Int("messaging.batch.message_count", len(msgs)),
+		))
+}
+
+// endBatch завершает спан пачки, помечая ошибкой, если не обработано хотя бы одно сообщение
+func endBatch(span trace.Span, errs []error) {
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("не обработано %d из %d сообщений", failed, len(errs)))
+	}
+	span.End()
+}
+
+// setCache кладёт заказ в кэш под спаном cache.set
+func setCache(ctx context.Context, c cache.CacheInterface, order models.Order) {
+	_, span := tracer.Start(ctx, "cache.set", trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	c.SetCache(order.OrderUID, order)
+	span.End()
+}
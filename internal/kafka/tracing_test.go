@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+)
+
+var (
+	spanRecorder *tracetest.SpanRecorder
+	recorderOnce sync.Once
+)
+
+// recordSpans ставит глобальный провайдер с записью спанов
+// Трейсер пакета привязывается к первому провайдеру, поэтому он один на все тесты, а спаны фильтруются по трейсу
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorderOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+// spansOf - завершённые спаны трейса по имени
+func spansOf(rec *tracetest.SpanRecorder, traceID trace.TraceID) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range rec.Ended() {
+		if s.SpanContext().TraceID() == traceID {
+			spans[s.Name()] = s
+		}
+	}
+	return spans
+}
+
+// TestInject проверяет запись traceparent в заголовки: повторный Inject заменяет заголовок, а не дублирует
+func TestInject(t *testing.T) {
+	recordSpans(t)
+	ctx, span := otel.Tracer("test").Start(context.Background(), "producer")
+	defer span.End()
+
+	msg := kafka.Message{Headers: []kafka.Header{{Key: "type", Value: []byte(TypeOrder)}}}
+	Inject(ctx, &msg)
+	Inject(ctx, &msg)
+
+	assert.Len(t, msg.Headers, 2)
+	got := trace.SpanContextFromContext(extract(context.Background(), msg))
+	assert.Equal(t, span.SpanContext().TraceID(), got.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), got.SpanID())
+	assert.True(t, got.IsRemote())
+}
+
+// TestConsumer_TraceFromHeaders проверяет продолжение трейса продюсера:
+// 1) Сообщение приходит с traceparent в заголовках
+// 2) Спан обработки - дочерний к спану продюсера
+// 3) Разбор, валидация и запись в кэш - дочерние спаны обработки
+func TestConsumer_TraceFromHeaders(t *testing.T) {
+	rec := recordSpans(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg := orderMessage("traced1", 0, 0)
+	prodCtx, prodSpan := otel.Tracer("test").Start(context.Background(), "producer")
+	Inject(prodCtx, &msg)
+	prodSpan.End()
+
+	reader := &fakeReader{msgs: []kafka.Message{msg}}
+	repo := repomocks.NewOrderRepository(t)
+	cache := cachemocks.NewCacheInterface(t)
+	repo.EXPECT().SaveOrder(mock.Anything, mock.Anything).Return(nil).Once()
+	cache.EXPECT().SetCache("traced1", mock.Anything).Return().Once()
+
+	reader.onDrained = func() { time.AfterFunc(100*time.Millisecond, cancel) }
+	NewConsumer(reader, repo, cache, config.KafkaConfig{}).Run(ctx)
+
+	spans := spansOf(rec, prodSpan.SpanContext().TraceID())
+	require.Contains(t, spans, "process orders")
+	process := spans["process orders"]
+	assert.Equal(t, trace.SpanKindConsumer, process.SpanKind())
+	assert.Equal(t, prodSpan.SpanContext().SpanID(), process.Parent().SpanID())
+
+	for _, name := range []string{"order.decode", "order.validate", "cache.set"} {
+		if assert.Contains(t, spans, name) {
+			assert.Equal(t, process.SpanContext().SpanID(), spans[name].Parent().SpanID(), name)
+		}
+	}
+}
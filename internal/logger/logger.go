@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/redact"
+)
+
+// Ключи атрибутов, общие для всех логов сервиса
+const (
+	KeyOrderUID  = "order_uid"
+	KeyTopic     = "topic"
+	KeyPartition = "partition"
+	KeyOffset    = "offset"
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+	KeyError     = "error"
+)
+
+// Форматы вывода
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New создаёт логгер с уровнем и форматом из конфига
+// Все строки проходят через маскирование: персональные данные не попадают в лог,
+// даже если их передали атрибутом или вписали в текст сообщения
+func New(cfg config.LogConfig, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr}
+
+	var h slog.Handler
+	if cfg.Format == FormatText {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// Setup делает логгер из конфига логгером по умолчанию для slog и стандартного log
+func Setup(cfg config.LogConfig) *slog.Logger {
+	l := New(cfg, os.Stdout)
+	slog.SetDefault(l)
+	return l
+}
+
+// Err - ошибка атрибутом с общим ключом
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// sensitive - атрибуты верхнего уровня, которые маскируются по имени
+// Вложенные группы (доставка, оплата) маскируют себя сами через LogValue
+var sensitive = map[string]func(string) string{
+	"name":    redact.Name,
+	"phone":   redact.Phone,
+	"email":   redact.Email,
+	"address": redact.String,
+	"zip":     redact.String,
+}
+
+// replaceAttr - слой маскирования для каждого атрибута, включая текст сообщения
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if mask, ok := sensitive[a.Key]; ok && len(groups) == 0 && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, mask(a.Value.String()))
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact.Text(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, redact.Text(err.Error()))
+		}
+	}
+	return a
+}
+
+type requestIDKey struct{}
+
+// WithRequestID кладёт идентификатор запроса в контекст, он попадёт во все записи с этим контекстом
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID - идентификатор запроса из контекста
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler добавляет к записи request_id и идентификаторы трейса из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()), slog.String(KeySpanID, sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
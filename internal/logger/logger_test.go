@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestNew_Redaction проверяет, что в JSON-лог не попадают персональные данные:
+// 1) Заказ атрибутом - доставка и оплата замаскированы, order_uid виден
+// 2) Email и телефон в тексте сообщения и в ошибке замаскированы
+// 3) request_id берётся из контекста
+func TestNew_Redaction(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(config.LogConfig{Level: "info", Format: FormatJSON}, &buf)
+
+	order := models.Order{
+		OrderUID: "abc123",
+		Delivery: models.Delivery{Name: "Ivan Ivanov", Phone: "+79991234567", City: "Moscow", Address: "Lenina 1", Email: "ivan@mail.ru"},
+		Payment:  models.Payment{Transaction: "abc123", Currency: "RUB", Provider: "wbpay", Amount: 1817, Bank: "alpha"},
+	}
+	ctx := WithRequestID(context.Background(), "req-1")
+	l.InfoContext(ctx, "Письмо для ivan@mail.ru", "order", order, "phone", "+79991234567",
+		Err(errors.New("не дозвонились до +7 999 123-45-67")))
+
+	out := buf.String()
+	for _, secret := range []string{"Ivan Ivanov", "79991234567", "Lenina", "ivan@mail.ru", "alpha", "1817", "123-45-67"} {
+		assert.NotContains(t, out, secret)
+	}
+
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "Письмо для i***@mail.ru", rec["msg"])
+	assert.Equal(t, "req-1", rec[KeyRequestID])
+	assert.Equal(t, "+7********67", rec["phone"])
+	delivery := rec["order"].(map[string]any)["delivery"].(map[string]any)
+	assert.Equal(t, "Moscow", delivery["city"])
+	assert.Equal(t, "+7********67", delivery["phone"])
+	assert.Equal(t, "abc123", rec["order"].(map[string]any)[KeyOrderUID])
+}
+
+// TestNew_LevelAndFormat проверяет фильтрацию по уровню и текстовый формат
+func TestNew_LevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(config.LogConfig{Level: "warn", Format: FormatText}, &buf)
+
+	l.Info("не попадёт")
+	l.Warn("попадёт", KeyOrderUID, "abc")
+
+	assert.NotContains(t, buf.String(), "не попадёт")
+	assert.True(t, strings.HasPrefix(buf.String(), "time="))
+	assert.Contains(t, buf.String(), "level=WARN msg=попадёт order_uid=abc")
+	assert.False(t, l.Enabled(context.Background(), slog.LevelInfo))
+}
+
+// TestNew_TraceIDs проверяет, что идентификаторы трейса из контекста попадают в запись
+func TestNew_TraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(config.LogConfig{Level: "info", Format: FormatJSON}, &buf)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	l.InfoContext(ctx, "в трейсе")
+	l.Info("без трейса")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", rec[KeyTraceID])
+	assert.Equal(t, "00f067aa0ba902b7", rec[KeySpanID])
+	assert.NotContains(t, lines[1], KeyTraceID)
+}
@@ -0,0 +1,290 @@
+// Package masking - ответы API по роли клиента: поля моделей помечены классами чувствительности
+// (тег sensitivity), а файл политики говорит, что каждая роль видит из каждого класса
+package masking
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/redact"
+)
+
+// Action - что делать с полем класса
+type Action string
+
+const (
+	Show Action = "show" // поле как есть
+	Mask Action = "mask" // строка маскируется, остальные типы не выводятся
+	Omit Action = "omit" // поле не выводится
+)
+
+// rank - от строгого к открытому, из нескольких ролей берётся самое открытое действие
+var rank = map[Action]int{Omit: 0, Mask: 1, Show: 2}
+
+// Rules - действие по классу; класса нет - поле показывается
+type Rules map[string]Action
+
+// Policy - правила по ролям и правила для клиента без известной роли
+// Класс, которого нет в правилах роли, берётся из Default
+type Policy struct {
+	Default Rules            `json:"default"`
+	Roles   map[string]Rules `json:"roles"`
+}
+
+// Load читает политику из JSON-файла и проверяет действия и классы по тегам models.Order
+func Load(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("некорректная политика %s: %w", path, err)
+	}
+
+	classes := Classes(models.Order{})
+	check := func(who string, rules Rules) error {
+		for class, action := range rules {
+			if !slices.Contains(classes, class) {
+				return fmt.Errorf("%s: неизвестный класс %q, известны %v", who, class, classes)
+			}
+			if _, ok := rank[action]; !ok {
+				return fmt.Errorf("%s: действие для %s должно быть show, mask или omit, получено %q", who, class, action)
+			}
+		}
+		return nil
+	}
+	if err := check("default", p.Default); err != nil {
+		return nil, err
+	}
+	for role, rules := range p.Roles {
+		if err := check("роль "+role, rules); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// Rules - правила для клиента с ролями roles; роли, которых нет в политике, не учитываются
+func (p *Policy) Rules(roles []string) Rules {
+	var known []Rules
+	for _, role := range roles {
+		if rules, ok := p.Roles[role]; ok {
+			known = append(known, rules)
+		}
+	}
+	if len(known) == 0 {
+		return p.Default
+	}
+
+	merged := make(Rules)
+	for _, rules := range known {
+		for class := range p.classes(rules) {
+			action := rules.action(class, p.Default)
+			if cur, ok := merged[class]; !ok || rank[action] > rank[cur] {
+				merged[class] = action
+			}
+		}
+	}
+	return merged
+}
+
+// classes - классы из правил роли и из Default
+func (p *Policy) classes(rules Rules) map[string]struct{} {
+	set := make(map[string]struct{}, len(rules)+len(p.Default))
+	for class := range rules {
+		set[class] = struct{}{}
+	}
+	for class := range p.Default {
+		set[class] = struct{}{}
+	}
+	return set
+}
+
+// action - действие роли для класса, если роль о нём молчит - из fallback, иначе Show
+func (r Rules) action(class string, fallback Rules) Action {
+	if a, ok := r[class]; ok {
+		return a
+	}
+	if a, ok := fallback[class]; ok {
+		return a
+	}
+	return Show
+}
+
+// Apply - v в виде, который видит клиент с ролями roles; у nil-политики - v без изменений
+// Результат кодируется в JSON с теми же именами и порядком полей, что и v
+func (p *Policy) Apply(v any, roles []string) any {
+	if p == nil {
+		return v
+	}
+	return shape(reflect.ValueOf(v), p.Rules(roles))
+}
+
+// Classes - классы чувствительности из тегов v и вложенных структур, без повторов
+func Classes(v any) []string {
+	var classes []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == timeType {
+			return
+		}
+		for _, f := range fieldsOf(t) {
+			if f.class != "" && !slices.Contains(classes, f.class) {
+				classes = append(classes, f.class)
+			}
+			walk(t.Field(f.index).Type)
+		}
+	}
+	walk(reflect.TypeOf(v))
+	return classes
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field - поле структуры в JSON: имя, omitempty и класс с маской из тега sensitivity
+type field struct {
+	index     int
+	name      string
+	omitempty bool
+	class     string
+	mask      func(string) string
+}
+
+// masks - маски строк по второму элементу тега sensitivity, без него значение скрывается целиком
+var masks = map[string]func(string) string{
+	"":      redact.String,
+	"name":  redact.Name,
+	"phone": redact.Phone,
+	"email": redact.Email,
+}
+
+// fields - разобранные поля по типу, теги читаются один раз
+var fields sync.Map // reflect.Type -> []field
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fields.Load(t); ok {
+		return cached.([]field)
+	}
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		f := field{index: i, name: name, omitempty: slices.Contains(strings.Split(opts, ","), "omitempty")}
+		class, kind, _ := strings.Cut(sf.Tag.Get("sensitivity"), ",")
+		f.class, f.mask = class, masks[kind]
+		if f.mask == nil {
+			panic(fmt.Sprintf("masking: неизвестная маска %q у поля %s.%s", kind, t.Name(), sf.Name))
+		}
+		out = append(out, f)
+	}
+	fields.Store(t, out)
+	return out
+}
+
+// object - JSON-объект с полями в порядке структуры
+type object []member
+
+type member struct {
+	name  string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(m.name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// shape применяет правила к значению: структуры становятся object, срезы - []any
+func shape(v reflect.Value, rules Rules) any {
+	switch {
+	case !v.IsValid():
+		return nil
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return shape(v.Elem(), rules)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		if v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = shape(v.Index(i), rules)
+		}
+		return out
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		var out object
+		for _, f := range fieldsOf(v.Type()) {
+			fv := v.Field(f.index)
+			if f.omitempty && isEmpty(fv) {
+				continue
+			}
+			switch rules.action(f.class, nil) {
+			case Omit:
+				continue
+			case Mask:
+				if fv.Kind() != reflect.String {
+					continue
+				}
+				out = append(out, member{f.name, f.mask(fv.String())})
+			default:
+				out = append(out, member{f.name, shape(fv, rules)})
+			}
+		}
+		return out
+	}
+	return v.Interface()
+}
+
+// isEmpty - пустое ли значение для omitempty, по тем же правилам, что в encoding/json
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
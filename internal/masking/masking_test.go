@@ -0,0 +1,120 @@
+package masking
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:          "b563feb7b2b84b6test",
+		TrackNumber:       "WBILMTESTTRACK",
+		InternalSignature: "sig",
+		CustomerID:        "test",
+		DateCreated:       time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay",
+			Amount: 1817, PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{{ChrtID: 9934930, Price: 453, Name: "Mascaras", TotalPrice: 317}},
+	}
+}
+
+// render - ответ в виде map для проверки полей
+func render(t *testing.T, v any) map[string]any {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	var m map[string]any
+	require.NoError(t, json.Unmarshal(b, &m))
+	return m
+}
+
+func testPolicy(t *testing.T) *Policy {
+	p, err := Load(filepath.Join("..", "..", "field_policy.json"))
+	require.NoError(t, err)
+	return p
+}
+
+// TestApply_Roles
+// Проверяет политику из field_policy.json:
+// 1) support видит адрес, но телефон и имя замаскированы, реквизиты оплаты скрыты, суммы не выводятся
+// 2) finance видит оплату, но не адрес
+// 3) клиент без известной роли получает правила default
+func TestApply_Roles(t *testing.T) {
+	p := testPolicy(t)
+	order := testOrder()
+
+	support := render(t, p.Apply(order, []string{"support"}))
+	delivery := support["delivery"].(map[string]any)
+	payment := support["payment"].(map[string]any)
+	assert.Equal(t, "+9*******00", delivery["phone"])
+	assert.Equal(t, "T***", delivery["name"])
+	assert.Equal(t, "t***@gmail.com", delivery["email"])
+	assert.Equal(t, "Ploshad Mira 15", delivery["address"])
+	assert.Equal(t, "***", payment["transaction"])
+	assert.Equal(t, "USD", payment["currency"])
+	assert.NotContains(t, payment, "amount")
+	assert.NotContains(t, support, "internal_signature")
+
+	finance := render(t, p.Apply(order, []string{"finance"}))
+	delivery = finance["delivery"].(map[string]any)
+	assert.NotContains(t, delivery, "address")
+	assert.NotContains(t, delivery, "city")
+	assert.Equal(t, float64(1817), finance["payment"].(map[string]any)["amount"])
+
+	anonymous := render(t, p.Apply(order, []string{"unknown"}))
+	assert.Equal(t, map[string]any{"currency": "USD", "provider": "wbpay"}, anonymous["payment"])
+	assert.Equal(t, "***", anonymous["delivery"].(map[string]any)["zip"])
+	assert.Equal(t, "b563feb7b2b84b6test", anonymous["order_uid"])
+}
+
+// TestApply_ShowAll проверяет, что при открытых классах ответ совпадает с обычным JSON заказа, включая порядок полей
+func TestApply_ShowAll(t *testing.T) {
+	p := testPolicy(t)
+	order := testOrder()
+
+	want, err := json.Marshal(order)
+	require.NoError(t, err)
+	got, err := json.Marshal(p.Apply(order, []string{"admin"}))
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+
+	var nilPolicy *Policy
+	assert.Equal(t, order, nilPolicy.Apply(order, nil))
+}
+
+// TestRules_MultipleRoles проверяет, что из нескольких ролей берётся самое открытое действие
+func TestRules_MultipleRoles(t *testing.T) {
+	p := testPolicy(t)
+	rules := p.Rules([]string{"support", "finance"})
+	assert.Equal(t, Show, rules["address"])
+	assert.Equal(t, Show, rules["payment"])
+	assert.Equal(t, Mask, rules["contact"])
+	assert.Equal(t, Omit, rules["internal"])
+}
+
+// TestLoad_Invalid проверяет, что неизвестные классы и действия отклоняются при загрузке
+func TestLoad_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"неизвестный класс":    `{"default": {"card": "omit"}}`,
+		"неизвестное действие": `{"roles": {"support": {"contact": "hide"}}}`,
+		"лишнее поле":          `{"rules": {}}`,
+	} {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+		_, err := Load(path)
+		assert.Error(t, err, name)
+	}
+	assert.Equal(t, []string{"contact", "address", "payment", "internal"}, Classes(models.Order{}))
+}
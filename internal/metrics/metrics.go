@@ -0,0 +1,320 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType - текстовый формат Prometheus
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets - границы гистограмм длительности по умолчанию, в секундах
+var DefBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector - метрика (или группа метрик), которая умеет записать себя в текстовом формате
+type Collector interface {
+	Name() string
+	Collect(w io.Writer)
+}
+
+// Registry - набор метрик, который отдаётся на GET /metrics в текстовом формате Prometheus
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+	names      map[string]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Register добавляет метрики в реестр, повторное имя - ошибка программиста
+func (r *Registry) Register(cs ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range cs {
+		if r.names[c.Name()] {
+			panic(fmt.Sprintf("метрика %s уже зарегистрирована", c.Name()))
+		}
+		r.names[c.Name()] = true
+		r.collectors = append(r.collectors, c)
+	}
+}
+
+// GaugeFunc регистрирует метрику, значение которой при каждом опросе берётся из fn
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.Register(&funcMetric{name: name, help: help, kind: "gauge", value: fn})
+}
+
+// CounterFunc регистрирует растущий счётчик, значение которого при каждом опросе берётся из fn
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.Register(&funcMetric{name: name, help: help, kind: "counter", value: fn})
+}
+
+// ServeHTTP отдаёт все метрики в порядке регистрации
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, c := range collectors {
+		c.Collect(&buf)
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// funcMetric - метрика без меток со значением из функции
+type funcMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func() float64
+}
+
+func (m *funcMetric) Name() string { return m.name }
+
+func (m *funcMetric) Collect(w io.Writer) {
+	writeHeader(w, m.name, m.help, m.kind)
+	writeSample(w, m.name, "", m.value())
+}
+
+// vec - значения метрики по наборам меток
+// Ключ - значения меток через \xff, порядок вывода - по ключу, чтобы вывод был стабильным
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*T
+	newT   func() *T
+}
+
+// newVec создаёт значения метрики; у метрики без меток значение есть сразу, чтобы она выводилась с нуля
+func newVec[T any](name, help string, labels []string, newT func() *T) *vec[T] {
+	v := &vec[T]{name: name, help: help, labels: labels, values: make(map[string]*T), newT: newT}
+	if len(labels) == 0 {
+		v.values[""] = newT()
+	}
+	return v
+}
+
+func (v *vec[T]) Name() string { return v.name }
+
+// get - значение для набора меток, создаётся при первом обращении; вызывается под mu
+func (v *vec[T]) get(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("метрика %s: ожидается %d меток, передано %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	t, ok := v.values[key]
+	if !ok {
+		t = v.newT()
+		v.values[key] = t
+	}
+	return t
+}
+
+// each обходит значения в порядке меток; вызывается под mu
+func (v *vec[T]) each(fn func(labels string, t *T)) {
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		var values []string
+		if len(v.labels) > 0 {
+			values = strings.Split(k, "\xff")
+		}
+		fn(formatLabels(v.labels, values), v.values[k])
+	}
+}
+
+// Counter - растущий счётчик с метками
+type Counter struct {
+	*vec[float64]
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newVec(name, help, labels, func() *float64 { return new(float64) })}
+}
+
+// Inc увеличивает счётчик для набора меток на единицу
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add увеличивает счётчик для набора меток на v (v >= 0)
+func (c *Counter) Add(v float64, labels ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.get(labels) += v
+}
+
+// Value - текущее значение для набора меток
+func (c *Counter) Value(labels ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.get(labels)
+}
+
+func (c *Counter) Collect(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	c.each(func(labels string, v *float64) {
+		writeSample(w, c.name, labels, *v)
+	})
+}
+
+// Gauge - значение с метками, которое может расти и уменьшаться
+type Gauge struct {
+	*vec[float64]
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newVec(name, help, labels, func() *float64 { return new(float64) })}
+}
+
+// Set задаёт значение для набора меток
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.get(labels) = v
+}
+
+// Add меняет значение для набора меток на v
+func (g *Gauge) Add(v float64, labels ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.get(labels) += v
+}
+
+// Value - текущее значение для набора меток
+func (g *Gauge) Value(labels ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return *g.get(labels)
+}
+
+func (g *Gauge) Collect(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeHeader(w, g.name, g.help, "gauge")
+	g.each(func(labels string, v *float64) {
+		writeSample(w, g.name, labels, *v)
+	})
+}
+
+// histogram - наблюдения одного набора меток
+type histogram struct {
+	counts []uint64 // по бакетам, не накопительно
+	count  uint64
+	sum    float64
+}
+
+// Histogram - распределение значений (обычно длительностей в секундах) по бакетам
+type Histogram struct {
+	*vec[histogram]
+	buckets []float64
+}
+
+// NewHistogram создаёт гистограмму с возрастающими границами buckets (nil - DefBuckets)
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	h := &Histogram{buckets: buckets}
+	h.vec = newVec(name, help, labels, func() *histogram {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	})
+	return h
+}
+
+// Observe добавляет наблюдение для набора меток
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labels)
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count - число наблюдений для набора меток
+func (h *Histogram) Count(labels ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.get(labels).count
+}
+
+func (h *Histogram) Collect(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	h.each(func(labels string, s *histogram) {
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", withLabel(labels, "le", formatFloat(le)), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", withLabel(labels, "le", "+Inf"), float64(s.count))
+		writeSample(w, h.name+"_sum", labels, s.sum)
+		writeSample(w, h.name+"_count", labels, float64(s.count))
+	})
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func writeSample(w io.Writer, name, labels string, v float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(v))
+}
+
+// formatLabels - {a="x",b="y"} или пустая строка без меток
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escape.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel добавляет метку к уже отформатированным
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
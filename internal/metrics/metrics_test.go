@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRegistry проверяет вывод метрик в порядке регистрации и запрет повторных имён
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.GaugeFunc("queue_size", "Размер очереди", func() float64 { return 2.5 })
+	r.CounterFunc("opens_total", "Открытий", func() float64 { return 3 })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP queue_size Размер очереди
+# TYPE queue_size gauge
+queue_size 2.5
+# HELP opens_total Открытий
+# TYPE opens_total counter
+opens_total 3
+`, w.Body.String())
+
+	assert.Panics(t, func() { r.GaugeFunc("queue_size", "", func() float64 { return 0 }) })
+}
+
+// TestLabeledMetrics проверяет счётчики, gauge и гистограммы с метками
+func TestLabeledMetrics(t *testing.T) {
+	requests := NewCounter("requests_total", "Запросы", "route", "code")
+	requests.Inc("/order", "200")
+	requests.Add(2, "/order", "404")
+	inflight := NewGauge("inflight", "В работе")
+	inflight.Add(3)
+	inflight.Add(-1)
+	latency := NewHistogram("latency_seconds", "Задержка", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "/order")
+	latency.Observe(0.5, "/order")
+	latency.Observe(5, "/order")
+
+	r := NewRegistry()
+	r.Register(requests, inflight, latency)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, `# HELP requests_total Запросы
+# TYPE requests_total counter
+requests_total{route="/order",code="200"} 1
+requests_total{route="/order",code="404"} 2
+# HELP inflight В работе
+# TYPE inflight gauge
+inflight 2
+# HELP latency_seconds Задержка
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/order",le="0.1"} 1
+latency_seconds_bucket{route="/order",le="1"} 2
+latency_seconds_bucket{route="/order",le="+Inf"} 3
+latency_seconds_sum{route="/order"} 5.55
+latency_seconds_count{route="/order"} 3
+`, w.Body.String())
+
+	assert.Panics(t, func() { requests.Inc("/order") })
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Envelope — конверт сообщения Kafka: метаданные события и само событие в Payload
+// Продюсеры могут слать конверт в теле сообщения или те же поля заголовками рядом с "сырым" JSON
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	EventID       string          `json:"event_id,omitempty"`
+	ProducedAt    time.Time       `json:"produced_at,omitempty"`
+	Source        string          `json:"source,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
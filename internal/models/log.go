@@ -0,0 +1,41 @@
+package models
+
+import (
+	"log/slog"
+
+	"github.com/fathersson/wb-demo-service/internal/redact"
+)
+
+// LogValue - заказ в логах: идентификаторы и состав без персональных данных и реквизитов оплаты
+func (o Order) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("order_uid", o.OrderUID),
+		slog.String("track_number", o.TrackNumber),
+		slog.Any("delivery", o.Delivery),
+		slog.Any("payment", o.Payment),
+		slog.Int("items", len(o.Items)),
+	)
+}
+
+// LogValue - доставка в логах: город виден, остальное замаскировано
+func (d Delivery) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("name", redact.Name(d.Name)),
+		slog.String("phone", redact.Phone(d.Phone)),
+		slog.String("zip", redact.String(d.Zip)),
+		slog.String("city", d.City),
+		slog.String("address", redact.String(d.Address)),
+		slog.String("region", redact.String(d.Region)),
+		slog.String("email", redact.Email(d.Email)),
+	)
+}
+
+// LogValue - оплата в логах: только валюта и провайдер, реквизиты и суммы скрыты
+func (p Payment) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("transaction", redact.String(p.Transaction)),
+		slog.String("currency", p.Currency),
+		slog.String("provider", p.Provider),
+		slog.String("bank", redact.String(p.Bank)),
+	)
+}
@@ -5,6 +5,8 @@ import (
 )
 
 // Order — корневая структура заказа
+// Тег sensitivity - класс чувствительности поля и маска строки (см. internal/masking):
+// contact - кто заказал, address - куда везти, payment - реквизиты и суммы оплаты, internal - служебное
 type Order struct {
 	OrderUID          string   `json:"order_uid" validate:"required,alphanum"` // Буквы и цифры
 	TrackNumber       string   `json:"track_number" validate:"required"`       // Не пустая строка
@@ -13,8 +15,8 @@ type Order struct {
 	Payment           Payment  `json:"payment" validate:"required"`
 	Items             []Item   `json:"items" validate:"required,min=1"`
 	Locale            string   `json:"locale,omitempty" validate:"omitempty"` // Только буквы
-	InternalSignature string   `json:"internal_signature,omitempty"`
-	CustomerID        string   `json:"customer_id,omitempty" validate:"omitempty"`
+	InternalSignature string   `json:"internal_signature,omitempty" sensitivity:"internal"`
+	CustomerID        string   `json:"customer_id,omitempty" validate:"omitempty" sensitivity:"contact"`
 	DeliveryService   string   `json:"delivery_service,omitempty" validate:"omitempty"`
 	ShardKey          string   `json:"shardkey,omitempty" validate:"omitempty,numeric"`
 	SmID              int      `json:"sm_id,omitempty" validate:"omitempty,min=0"`
@@ -26,27 +28,27 @@ type Order struct {
 
 // Delivery — данные доставки
 type Delivery struct {
-	Name    string `json:"name" validate:"required"`
-	Phone   string `json:"phone" validate:"required"`
-	Zip     string `json:"zip" validate:"required,numeric"`
-	City    string `json:"city" validate:"required"`
-	Address string `json:"address" validate:"required"`
-	Region  string `json:"region,omitempty" validate:"omitempty"`
-	Email   string `json:"email,omitempty" validate:"omitempty,email"`
+	Name    string `json:"name" validate:"required" sensitivity:"contact,name"`
+	Phone   string `json:"phone" validate:"required" sensitivity:"contact,phone"`
+	Zip     string `json:"zip" validate:"required,numeric" sensitivity:"address"`
+	City    string `json:"city" validate:"required" sensitivity:"address"`
+	Address string `json:"address" validate:"required" sensitivity:"address"`
+	Region  string `json:"region,omitempty" validate:"omitempty" sensitivity:"address"`
+	Email   string `json:"email,omitempty" validate:"omitempty,email" sensitivity:"contact,email"`
 }
 
 // Payment — платёжные данные
 type Payment struct {
-	Transaction  string `json:"transaction" validate:"required,alphanum"`
-	RequestID    string `json:"request_id,omitempty"`
+	Transaction  string `json:"transaction" validate:"required,alphanum" sensitivity:"payment"`
+	RequestID    string `json:"request_id,omitempty" sensitivity:"payment"`
 	Currency     string `json:"currency" validate:"required"`
 	Provider     string `json:"provider" validate:"required"`
-	Amount       int    `json:"amount" validate:"required,min=0"`     // сумма в целых единицах (например, копейки/центы или просто рубли — договоритесь внутри команды)
-	PaymentDT    int64  `json:"payment_dt" validate:"required,min=0"` // unix timestamp (seconds). Альтернатива — ISO date -> use Order.DateCreated
-	Bank         string `json:"bank,omitempty" validate:"omitempty"`
-	DeliveryCost int    `json:"delivery_cost" validate:"required,min=0"` // стоимость доставки
-	GoodsTotal   int    `json:"goods_total" validate:"required,min=0"`   // сумма товаров
-	CustomFee    int    `json:"custom_fee,omitempty" validate:"omitempty,min=0"`
+	Amount       int    `json:"amount" validate:"required,min=0" sensitivity:"payment"`     // сумма в целых единицах (например, копейки/центы или просто рубли — договоритесь внутри команды)
+	PaymentDT    int64  `json:"payment_dt" validate:"required,min=0" sensitivity:"payment"` // unix timestamp (seconds). Альтернатива — ISO date -> use Order.DateCreated
+	Bank         string `json:"bank,omitempty" validate:"omitempty" sensitivity:"payment"`
+	DeliveryCost int    `json:"delivery_cost" validate:"required,min=0" sensitivity:"payment"` // стоимость доставки
+	GoodsTotal   int    `json:"goods_total" validate:"required,min=0" sensitivity:"payment"`   // сумма товаров
+	CustomFee    int    `json:"custom_fee,omitempty" validate:"omitempty,min=0" sensitivity:"payment"`
 }
 
 // Item — один товар в заказе
@@ -63,3 +65,14 @@ type Item struct {
 	Brand       string `json:"brand,omitempty" validate:"omitempty"`
 	Status      int    `json:"status,omitempty" validate:"omitempty,min=0"`
 }
+
+// OrderStatusUpdate — сообщение об изменении статуса товаров заказа
+type OrderStatusUpdate struct {
+	OrderUID string `json:"order_uid" validate:"required,alphanum"`
+	Status   int    `json:"status" validate:"min=0"`
+}
+
+// OrderDeletion — сообщение об удалении заказа
+type OrderDeletion struct {
+	OrderUID string `json:"order_uid" validate:"required,alphanum"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Типы событий в таблице outbox
+const (
+	EventOrderStored = "order.stored" // заказ сохранён в БД
+)
+
+// OutboxEvent — событие из таблицы outbox, ожидающее публикации в Kafka
+type OutboxEvent struct {
+	ID        int64
+	EventType string
+	OrderUID  string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// OrderStoredEvent — тело события "заказ сохранён" для топика orders.stored
+// Персональные данные не публикуем, только идентификаторы
+type OrderStoredEvent struct {
+	OrderUID    string    `json:"order_uid"`
+	TrackNumber string    `json:"track_number"`
+	CustomerID  string    `json:"customer_id,omitempty"`
+	StoredAt    time.Time `json:"stored_at"`
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIP - адрес клиента для лимита
+// X-Forwarded-For учитывается, только если запрос пришёл от доверенного прокси: цепочка читается справа,
+// доверенные прокси пропускаются, первый чужой адрес - клиент. Иначе заголовок мог бы подделать сам клиент
+type ClientIP struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIP - определение адреса с доверенными прокси trusted
+func NewClientIP(trusted []netip.Prefix) *ClientIP {
+	return &ClientIP{trusted: trusted}
+}
+
+// Of - адрес клиента запроса
+func (c *ClientIP) Of(r *http.Request) string {
+	remote := parseAddr(r.RemoteAddr)
+	if !remote.IsValid() {
+		return r.RemoteAddr
+	}
+	if !c.isTrusted(remote) {
+		return remote.String()
+	}
+
+	client := remote
+	values := r.Header.Values("X-Forwarded-For")
+	for i := len(values) - 1; i >= 0; i-- {
+		hops := strings.Split(values[i], ",")
+		for j := len(hops) - 1; j >= 0; j-- {
+			addr := parseAddr(strings.TrimSpace(hops[j]))
+			if !addr.IsValid() {
+				// Мусор в цепочке: дальше неё доверять нельзя, клиент - последний проверенный адрес
+				return client.String()
+			}
+			client = addr
+			if !c.isTrusted(addr) {
+				return addr.String()
+			}
+		}
+	}
+	return client.String()
+}
+
+func (c *ClientIP) isTrusted(addr netip.Addr) bool {
+	if c == nil {
+		return false
+	}
+	for _, p := range c.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr - IP из "host:port" или просто IP, IPv4 в IPv6 приводится к IPv4
+func parseAddr(s string) netip.Addr {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
@@ -0,0 +1,197 @@
+// Package ratelimit - ограничение частоты запросов по клиенту: token bucket на каждый маршрут,
+// число клиентов в памяти ограничено, давно не заходившие вытесняются
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/metrics"
+)
+
+// Result - решение по запросу и данные для заголовков RateLimit-*
+type Result struct {
+	Allowed    bool
+	Limit      int           // ёмкость ведра (burst)
+	Remaining  int           // сколько запросов ещё можно сделать подряд
+	Reset      time.Duration // когда ведро наполнится целиком
+	RetryAfter time.Duration // когда появится следующий токен, если запрос отклонён
+}
+
+// Limits - лимиты по маршрутам; nil пропускает все запросы
+type Limits struct {
+	def        config.RouteLimit
+	routes     map[string]config.RouteLimit
+	maxClients int
+	ip         *ClientIP
+	now        func() time.Time
+
+	mu       sync.Mutex
+	limiters map[string]*limiter // по маршруту, создаются при первом запросе
+}
+
+// New создаёт лимиты по конфигу, выключенное ограничение - nil
+func New(cfg config.RateLimitConfig) (*Limits, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	routes, err := cfg.ParseRoutes()
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := cfg.ParseTrustedProxies()
+	if err != nil {
+		return nil, err
+	}
+	return &Limits{
+		def:        config.RouteLimit{RPS: cfg.RPS, Burst: cfg.Burst},
+		routes:     routes,
+		maxClients: cfg.MaxClients,
+		ip:         NewClientIP(trusted),
+		now:        time.Now,
+		limiters:   make(map[string]*limiter),
+	}, nil
+}
+
+// Allow списывает токен клиента key на маршруте route; у маршрута с RPS 0 ограничения нет
+func (l *Limits) Allow(route, key string) Result {
+	if l == nil {
+		return Result{Allowed: true}
+	}
+	lim := l.limiter(route)
+	if lim == nil {
+		return Result{Allowed: true}
+	}
+	return lim.allow(key, l.now())
+}
+
+// ClientIP - адрес клиента с учётом доверенных прокси, ключ лимита для запросов без аутентификации
+func (l *Limits) ClientIP(r *http.Request) string {
+	return l.ip.Of(r)
+}
+
+// Clients - сколько клиентов сейчас в памяти по всем маршрутам
+func (l *Limits) Clients() int {
+	l.mu.Lock()
+	limiters := make([]*limiter, 0, len(l.limiters))
+	for _, lim := range l.limiters {
+		if lim != nil {
+			limiters = append(limiters, lim)
+		}
+	}
+	l.mu.Unlock()
+
+	n := 0
+	for _, lim := range limiters {
+		n += lim.clients()
+	}
+	return n
+}
+
+func (l *Limits) limiter(route string) *limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lim, ok := l.limiters[route]; ok {
+		return lim
+	}
+	limit, ok := l.routes[route]
+	if !ok {
+		limit = l.def
+	}
+	var lim *limiter
+	if limit.RPS > 0 {
+		lim = newLimiter(limit, l.maxClients)
+	}
+	l.limiters[route] = lim
+	return lim
+}
+
+// Register добавляет в реестр число клиентов в памяти
+func (l *Limits) Register(reg *metrics.Registry) {
+	reg.GaugeFunc("http_rate_limit_clients", "Клиентов с вёдрами лимита в памяти", func() float64 {
+		return float64(l.Clients())
+	})
+}
+
+// limiter - вёдра клиентов одного маршрута в порядке последнего обращения
+type limiter struct {
+	rate  float64
+	burst float64
+	max   int
+
+	mu      sync.Mutex
+	order   *list.List // *bucket, в начале - последний обратившийся
+	buckets map[string]*list.Element
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(limit config.RouteLimit, max int) *limiter {
+	return &limiter{
+		rate:    limit.RPS,
+		burst:   float64(limit.Burst),
+		max:     max,
+		order:   list.New(),
+		buckets: make(map[string]*list.Element),
+	}
+}
+
+// allow пополняет ведро за прошедшее время и списывает токен, если он есть
+func (l *limiter) allow(key string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, now)
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	res := Result{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.after(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.after(l.burst - b.tokens)
+	return res
+}
+
+// bucket - ведро клиента; новый клиент получает полное ведро, самый давний вытесняется при переполнении
+func (l *limiter) bucket(key string, now time.Time) *bucket {
+	if e, ok := l.buckets[key]; ok {
+		l.order.MoveToFront(e)
+		return e.Value.(*bucket)
+	}
+	if l.order.Len() >= l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+	b := &bucket{key: key, tokens: l.burst, last: now}
+	l.buckets[key] = l.order.PushFront(b)
+	return b
+}
+
+// after - за сколько накопятся tokens токенов
+func (l *limiter) after(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// clients - сколько клиентов маршрута сейчас в памяти
+func (l *limiter) clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLimits - 1 запрос в секунду с burst 2, /healthz без лимита, часы управляются тестом
+func testLimits(t *testing.T, maxClients int) (*Limits, *time.Time) {
+	l, err := New(config.RateLimitConfig{Enabled: true, RPS: 1, Burst: 2, MaxClients: maxClients,
+		Routes: []string{"/healthz=0", "/schema/order.json=0.5:1"}})
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+// TestAllow_TokenBucket
+// Проверяет token bucket: burst запросов подряд, затем 429 с RetryAfter до следующего токена,
+// пополнение со временем и независимые вёдра у разных клиентов
+func TestAllow_TokenBucket(t *testing.T) {
+	l, now := testLimits(t, 100)
+
+	res := l.Allow("/order/{id}", "a")
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, res)
+	assert.True(t, l.Allow("/order/{id}", "a").Allowed)
+
+	res = l.Allow("/order/{id}", "a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 2*time.Second, res.Reset)
+
+	assert.True(t, l.Allow("/order/{id}", "b").Allowed, "у другого клиента своё ведро")
+
+	*now = now.Add(500 * time.Millisecond)
+	res = l.Allow("/order/{id}", "a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	*now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("/order/{id}", "a").Allowed)
+}
+
+// TestAllow_Routes проверяет лимиты по маршрутам: RPS 0 - без ограничения, переопределённый маршрут - свой лимит
+func TestAllow_Routes(t *testing.T) {
+	l, _ := testLimits(t, 100)
+
+	for range 10 {
+		assert.Equal(t, Result{Allowed: true}, l.Allow("/healthz", "a"))
+	}
+	assert.True(t, l.Allow("/schema/order.json", "a").Allowed)
+	res := l.Allow("/schema/order.json", "a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 2*time.Second, res.RetryAfter)
+
+	var disabled *Limits
+	assert.True(t, disabled.Allow("/order/{id}", "a").Allowed)
+}
+
+// TestAllow_MaxClients проверяет, что клиентов в памяти не больше MaxClients: давно не заходивший вытесняется
+func TestAllow_MaxClients(t *testing.T) {
+	l, _ := testLimits(t, 2)
+
+	l.Allow("/order/{id}", "a")
+	l.Allow("/order/{id}", "a")
+	l.Allow("/order/{id}", "b")
+	l.Allow("/order/{id}", "c") // вытесняет a
+	assert.Equal(t, 2, l.Clients())
+
+	// a вернулся с полным ведром, вытеснив b
+	assert.Equal(t, 1, l.Allow("/order/{id}", "a").Remaining)
+	assert.Equal(t, 2, l.Clients())
+}
+
+// TestClientIP
+// Проверяет адрес клиента: X-Forwarded-For учитывается только от доверенного прокси,
+// цепочка читается справа до первого недоверенного адреса
+func TestClientIP(t *testing.T) {
+	ip := NewClientIP([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"без прокси", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"клиент подделал XFF", "203.0.113.7:5000", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"через прокси", "10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"подделка за прокси", "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"цепочка прокси", "10.0.0.2:5000", []string{"198.51.100.1", "10.0.0.3"}, "198.51.100.1"},
+		{"мусор в цепочке", "10.0.0.2:5000", []string{"garbage, 10.0.0.3"}, "10.0.0.3"},
+		{"IPv4 в IPv6", "[::ffff:203.0.113.7]:5000", nil, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/order/id1", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			assert.Equal(t, tt.want, ip.Of(r))
+		})
+	}
+}
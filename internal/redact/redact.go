@@ -0,0 +1,76 @@
+package redact
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask - замена скрытого значения
+const Mask = "***"
+
+// String скрывает значение целиком, пустое остаётся пустым
+func String(s string) string {
+	if s == "" {
+		return ""
+	}
+	return Mask
+}
+
+// Name оставляет первую букву: "Ivan Ivanov" -> "I***"
+func Name(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return ""
+	}
+	return string(r) + Mask
+}
+
+// Phone оставляет код страны и две последние цифры: "+79991234567" -> "+7********67"
+func Phone(s string) string {
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits <= 4 {
+		return String(s)
+	}
+
+	var b strings.Builder
+	seen := 0
+	for _, r := range s {
+		switch {
+		case r < '0' || r > '9':
+			b.WriteRune(r)
+		case seen < 1 && strings.HasPrefix(s, "+"), seen >= digits-2:
+			b.WriteRune(r)
+			seen++
+		default:
+			b.WriteByte('*')
+			seen++
+		}
+	}
+	return b.String()
+}
+
+// Email оставляет первую букву имени и домен: "ivan@mail.ru" -> "i***@mail.ru"
+func Email(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok {
+		return String(s)
+	}
+	return Name(local) + "@" + domain
+}
+
+var (
+	emailRe = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`)
+	phoneRe = regexp.MustCompile(`\+\d[\d\s()-]{8,16}\d`)
+)
+
+// Text скрывает email и телефоны в международном формате в произвольном тексте (сообщения логов, ошибки)
+func Text(s string) string {
+	s = emailRe.ReplaceAllStringFunc(s, Email)
+	return phoneRe.ReplaceAllStringFunc(s, Phone)
+}
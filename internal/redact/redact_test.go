@@ -0,0 +1,25 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMask проверяет маскирование отдельных значений
+func TestMask(t *testing.T) {
+	assert.Equal(t, "+7********67", Phone("+79991234567"))
+	assert.Equal(t, "***-***-**69", Phone("201-886-0269"))
+	assert.Equal(t, "***", Phone("123"))
+	assert.Equal(t, "i***@mail.ru", Email("ivan@mail.ru"))
+	assert.Equal(t, "И***", Name("Иван Иванов"))
+	assert.Equal(t, "***", String("ул. Ленина, 1"))
+	assert.Equal(t, "", String(""))
+}
+
+// TestText проверяет маскирование email и телефонов внутри текста, остальное не меняется
+func TestText(t *testing.T) {
+	assert.Equal(t,
+		"контакт i***@mail.ru, тел. +7 (***) ***-**-67, заказ 12345678901234567890",
+		Text("контакт ivan@mail.ru, тел. +7 (999) 123-45-67, заказ 12345678901234567890"))
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// Типы ошибок репозитория: вызывающий код решает по ним, что ответить клиенту
+// Исходная ошибка драйвера остаётся в цепочке, errors.Is(err, sql.ErrNoRows) продолжает работать
+var (
+	ErrNotFound    = errors.New("запись не найдена")
+	ErrUnavailable = errors.New("база данных недоступна")        // временная ошибка, запрос стоит повторить
+	ErrInternal    = errors.New("внутренняя ошибка базы данных") // повтор не поможет: ошибка в запросе или данных
+	ErrDuplicate   = errors.New("заказ уже сохранён")            // вместе с ErrInternal: order_uid уже есть в базе
+)
+
+// uniqueViolation - ошибка PostgreSQL unique_violation в таблице table
+func uniqueViolation(err error, table string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Table == table
+}
+
+// classify оборачивает ошибку драйвера в один из типов репозитория, nil остаётся nil
+// Отмена контекста возвращается как есть: клиент ушёл или сервис останавливается, с базой всё в порядке
+func classify(err error) error {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return err
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrUnavailable), errors.Is(err, ErrInternal):
+		return err
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case transient(err):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return fmt.Errorf("%w: %w", ErrInternal, err)
+}
+
+// transient - ошибка связи с базой или её перегрузки, а не самого запроса
+func transient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", // connection exception
+			"53", // insufficient resources
+			"57": // operator intervention: БД перезапускается или выключается
+			return true
+		}
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"55P03": // lock_not_available
+			return true
+		}
+	}
+	return false
+}
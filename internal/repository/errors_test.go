@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"нет строк", sql.ErrNoRows, ErrNotFound},
+		{"таймаут", context.DeadlineExceeded, ErrUnavailable},
+		{"плохое соединение", driver.ErrBadConn, ErrUnavailable},
+		{"обрыв соединения", io.ErrUnexpectedEOF, ErrUnavailable},
+		{"сеть", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrUnavailable},
+		{"база перезапускается", &pq.Error{Code: "57P03"}, ErrUnavailable},
+		{"нет соединения", &pq.Error{Code: "08006"}, ErrUnavailable},
+		{"дедлок", &pq.Error{Code: "40P01"}, ErrUnavailable},
+		{"синтаксис", &pq.Error{Code: "42601"}, ErrInternal},
+		{"сканирование", errors.New("sql: Scan error on column index 3"), ErrInternal},
+	}
+	for _, tt := range tests {
+		err := classify(tt.err)
+		assert.ErrorIs(t, err, tt.want, tt.name)
+		assert.ErrorIs(t, err, tt.err, tt.name)
+	}
+	assert.NoError(t, classify(nil))
+
+	// отмена - не недоступность базы: не 503 и не ошибка для предохранителя консьюмера
+	canceled := classify(fmt.Errorf("запрос: %w", context.Canceled))
+	assert.ErrorIs(t, canceled, context.Canceled)
+	assert.NotErrorIs(t, canceled, ErrUnavailable)
+	assert.NotErrorIs(t, canceled, ErrInternal)
+
+	// повторная классификация не меняет тип
+	assert.Equal(t, classify(sql.ErrNoRows).Error(), classify(classify(sql.ErrNoRows)).Error())
+}
+
+// TestGetOrderById_Errors проверяет типы ошибок GetOrderById:
+// нет заказа - ErrNotFound, обрыв соединения - ErrUnavailable, несовпадение колонок - ErrInternal
+func TestGetOrderById_Errors(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	repo := NewPostgresRepo(db)
+
+	mock.ExpectQuery("SELECT .* FROM orders").WillReturnError(sql.ErrNoRows)
+	_, err := repo.GetOrderById(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// driver.ErrBadConn database/sql повторяет сам, поэтому обрыв - сетевая ошибка
+	mock.ExpectQuery("SELECT .* FROM orders").WillReturnError(&net.OpError{Op: "read", Err: io.ErrUnexpectedEOF})
+	_, err = repo.GetOrderById(context.Background(), "id1")
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	mock.ExpectQuery("SELECT .* FROM orders").WillReturnRows(sqlmock.NewRows([]string{"order_uid"}).AddRow("id1"))
+	_, err = repo.GetOrderById(context.Background(), "id1")
+	assert.ErrorIs(t, err, ErrInternal)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestSaveOrder_Errors проверяет типы ошибок сохранения: дубль order_uid - ErrInternal и ErrDuplicate,
+// обрыв соединения - ErrUnavailable; драйверная ошибка остаётся в цепочке
+func TestSaveOrder_Errors(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+	repo := NewPostgresRepo(db)
+
+	dup := &pq.Error{Code: "23505", Table: "orders", Constraint: "orders_pkey"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO orders").WillReturnError(dup)
+	mock.ExpectRollback()
+	err := repo.SaveOrder(context.Background(), models.Order{OrderUID: "id1"})
+	assert.ErrorIs(t, err, ErrInternal)
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.ErrorIs(t, err, dup)
+
+	// Дубль в другой таблице (например, transaction у другого заказа) - ошибка данных, а не повтор
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO orders").WillReturnRows(sqlmock.NewRows([]string{"order_uid"}).AddRow("id2"))
+	mock.ExpectExec("INSERT INTO delivery").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO payment").WillReturnError(&pq.Error{Code: "23505", Table: "payment"})
+	mock.ExpectRollback()
+	err = repo.SaveOrder(context.Background(), models.Order{OrderUID: "id2"})
+	assert.ErrorIs(t, err, ErrInternal)
+	assert.NotErrorIs(t, err, ErrDuplicate)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders").WillReturnError(&net.OpError{Op: "read", Err: io.ErrUnexpectedEOF})
+	mock.ExpectRollback()
+	err = repo.SaveOrders(context.Background(), []models.Order{{OrderUID: "id1"}, {OrderUID: "id2"}})
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
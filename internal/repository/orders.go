@@ -3,24 +3,50 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/tracing"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.53.5 --name=OrderRepository --output=./repomocks --with-expecter
 type OrderRepository interface {
 	SaveOrder(ctx context.Context, order models.Order) error
+	SaveOrders(ctx context.Context, orders []models.Order) error
+	UpdateOrderStatus(ctx context.Context, orderUID string, status int) error
+	DeleteOrder(ctx context.Context, orderUID string) error
 	GetOrderById(ctx context.Context, orderUID string) (models.Order, error)
 	Query(query string, args ...any) (*sql.Rows, error)
 	QueryRow(query string, args ...any) *sql.Row
 }
 
+//go:generate go run github.com/vektra/mockery/v2@v2.53.5 --name=OutboxRepository --output=./repomocks --with-expecter
+type OutboxRepository interface {
+	FetchOutbox(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	MarkOutboxSent(ctx context.Context, ids []int64) error
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.53.5 --name=DedupRepository --output=./repomocks --with-expecter
+type DedupRepository interface {
+	ProcessedEvents(ctx context.Context, ids []string) (map[string]bool, error)
+	MarkProcessed(ctx context.Context, ids []string) error
+	PurgeProcessed(ctx context.Context, before time.Time) (int64, error)
+}
+
 type PostgresRepo struct {
 	db *sql.DB
+	q  traced // запросы вне транзакций, каждый со своим спаном
 }
 
 func NewPostgresRepo(db *sql.DB) *PostgresRepo {
-	return &PostgresRepo{db: db}
+	return &PostgresRepo{db: db, q: traced{db}}
 }
 
 func (r *PostgresRepo) Query(query string, args ...any) (*sql.Rows, error) {
@@ -32,16 +58,26 @@ func (r *PostgresRepo) QueryRow(query string, args ...any) *sql.Row {
 }
 
 // SaveOrder сохраняет заказ и все связанные данные в базе в одной транзакции
-func (r *PostgresRepo) SaveOrder(ctx context.Context, order models.Order) error {
+// В той же транзакции пишет событие order.stored в outbox, чтобы оно не потерялось при падении.
+// Ошибки типизированы: ErrUnavailable - база недоступна, ErrInternal - нарушение ограничений или данные,
+// в том числе ErrDuplicate - заказ с таким order_uid уже сохранён
+func (r *PostgresRepo) SaveOrder(ctx context.Context, order models.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "SaveOrder", trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() {
+		err = classify(err)
+		tracing.End(span, err)
+	}()
+
 	// Начало транзакции
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	q := traced{tx}
 
 	// Таблица orders
 	var orderUID string
-	err = tx.QueryRowContext(ctx,
+	err = q.QueryRowContext(ctx,
 		`INSERT INTO orders 
 		(order_uid, track_number, entry, locale, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
@@ -51,11 +87,14 @@ func (r *PostgresRepo) SaveOrder(ctx context.Context, order models.Order) error
 	).Scan(&orderUID)
 	if err != nil {
 		tx.Rollback()
+		if uniqueViolation(err, "orders") {
+			return fmt.Errorf("%w: %w: %w", ErrInternal, ErrDuplicate, err)
+		}
 		return err
 	}
 
 	// Таблица delivery
-	_, err = tx.ExecContext(ctx,
+	_, err = q.ExecContext(ctx,
 		`INSERT INTO delivery
 		(order_uid, name, phone, zip, city, address, region, email)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
@@ -68,7 +107,7 @@ func (r *PostgresRepo) SaveOrder(ctx context.Context, order models.Order) error
 	}
 
 	// Таблица payment
-	_, err = tx.ExecContext(ctx,
+	_, err = q.ExecContext(ctx,
 		`INSERT INTO payment
 		(order_uid, transaction, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
@@ -82,7 +121,7 @@ func (r *PostgresRepo) SaveOrder(ctx context.Context, order models.Order) error
 
 	// Таблица items
 	for _, item := range order.Items {
-		_, err = tx.ExecContext(ctx,
+		_, err = q.ExecContext(ctx,
 			`INSERT INTO items
 			(order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
 			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
@@ -94,6 +133,23 @@ func (r *PostgresRepo) SaveOrder(ctx context.Context, order models.Order) error
 		}
 	}
 
+	// Таблица outbox - событие публикуется relay только если транзакция закоммитится
+	payload, err := storedEventPayload(order)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO outbox
+		(event_type, order_uid, payload)
+		VALUES ($1, $2, $3)`,
+		models.EventOrderStored, orderUID, payload,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Коммитим транзакцию
 	if err := tx.Commit(); err != nil {
 		return err
@@ -103,22 +159,165 @@ func (r *PostgresRepo) SaveOrder(ctx context.Context, order models.Order) error
 	return nil
 }
 
+// maxParams - лимит плейсхолдеров в одном запросе PostgreSQL
+const maxParams = 65535
+
+// storedEventPayload - тело события order.stored для outbox
+func storedEventPayload(order models.Order) ([]byte, error) {
+	payload, err := json.Marshal(models.OrderStoredEvent{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		CustomerID:  order.CustomerID,
+		StoredAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации события outbox: %w", err)
+	}
+	return payload, nil
+}
+
+// SaveOrders сохраняет пачку заказов в одной транзакции многострочными INSERT
+// Если хотя бы один заказ не сохранился, откатывается вся пачка - вызывающий решает, сохранять ли по одному.
+// Ошибки типизированы так же, как у SaveOrder
+func (r *PostgresRepo) SaveOrders(ctx context.Context, orders []models.Order) (err error) {
+	if len(orders) == 0 {
+		return nil
+	}
+	ctx, span := tracer.Start(ctx, "SaveOrders", trace.WithAttributes(attribute.Int("orders.count", len(orders))))
+	defer func() {
+		err = classify(err)
+		tracing.End(span, err)
+	}()
+
+	// Раскладываем заказы по строкам таблиц
+	var orderRows, deliveryRows, paymentRows, itemRows, outboxRows [][]any
+	for _, order := range orders {
+		payload, err := storedEventPayload(order)
+		if err != nil {
+			return err
+		}
+		outboxRows = append(outboxRows, []any{models.EventOrderStored, order.OrderUID, payload})
+
+		orderRows = append(orderRows, []any{
+			order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.CustomerID,
+			order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated, order.OofShard,
+		})
+		deliveryRows = append(deliveryRows, []any{
+			order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
+			order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+		})
+		paymentRows = append(paymentRows, []any{
+			order.OrderUID, order.Payment.Transaction, order.Payment.Currency, order.Payment.Provider, order.Payment.Amount,
+			order.Payment.PaymentDT, order.Payment.Bank, order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
+		})
+		for _, item := range order.Items {
+			itemRows = append(itemRows, []any{
+				order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+			})
+		}
+	}
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	inserts := []struct {
+		table   string
+		columns []string
+		rows    [][]any
+	}{
+		{"orders", []string{"order_uid", "track_number", "entry", "locale", "customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard"}, orderRows},
+		{"delivery", []string{"order_uid", "name", "phone", "zip", "city", "address", "region", "email"}, deliveryRows},
+		{"payment", []string{"order_uid", "transaction", "currency", "provider", "amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"}, paymentRows},
+		{"items", []string{"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale", "size", "total_price", "nm_id", "brand", "status"}, itemRows},
+		{"outbox", []string{"event_type", "order_uid", "payload"}, outboxRows},
+	}
+	for _, ins := range inserts {
+		if err := insertRows(ctx, traced{tx}, ins.table, ins.columns, ins.rows); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Коммитим транзакцию
+	return tx.Commit()
+}
+
+// insertRows вставляет строки одним или несколькими многострочными INSERT,
+// разбивая их так, чтобы не превысить лимит плейсхолдеров
+func insertRows(ctx context.Context, q querier, table string, columns []string, rows [][]any) error {
+	perQuery := maxParams / len(columns)
+	for start := 0; start < len(rows); start += perQuery {
+		end := min(start+perQuery, len(rows))
+
+		var sb strings.Builder
+		args := make([]any, 0, (end-start)*len(columns))
+		fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+		for i, row := range rows[start:end] {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(")
+			for j := range row {
+				if j > 0 {
+					sb.WriteString(", ")
+				}
+				fmt.Fprintf(&sb, "$%d", len(args)+j+1)
+			}
+			sb.WriteString(")")
+			args = append(args, row...)
+		}
+
+		if _, err := q.ExecContext(ctx, sb.String(), args...); err != nil {
+			return fmt.Errorf("ошибка вставки в %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
 // Берем заказ по order_uid из бд
+// Ошибки типизированы: ErrNotFound - заказа нет, ErrUnavailable - база недоступна, ErrInternal - остальное
 func (r *PostgresRepo) GetOrderById(ctx context.Context, orderUID string) (models.Order, error) {
+	order, err := r.getOrder(ctx, orderUID)
+	if err != nil {
+		return models.Order{}, classify(err)
+	}
+	return order, nil
+}
+
+// Колонки, которые читает getOrder, в порядке Scan
+// Список явный: в таблицах есть колонки, которых нет в модели (items.id, payment.order_uid).
+// Необязательные по схеме колонки читаются через COALESCE (date_created - через sql.NullTime):
+// NULL в них - пустое значение поля, а не ошибка Scan
+const (
+	orderColumns = `order_uid, track_number, COALESCE(entry, ''), COALESCE(locale, ''), COALESCE(customer_id, ''),
+		COALESCE(delivery_service, ''), COALESCE(shardkey, ''), COALESCE(sm_id, 0), date_created, COALESCE(oof_shard, '')`
+	deliveryColumns = `name, phone, zip, city, address, COALESCE(region, ''), COALESCE(email, '')`
+	paymentColumns  = `transaction, currency, provider, amount, payment_dt, COALESCE(bank, ''), delivery_cost, goods_total,
+		COALESCE(custom_fee, 0)`
+	itemColumns = `chrt_id, COALESCE(track_number, ''), price, COALESCE(rid, ''), name, COALESCE(sale, 0), COALESCE(size, ''),
+		COALESCE(total_price, 0), COALESCE(nm_id, 0), COALESCE(brand, ''), COALESCE(status, 0)`
+)
+
+func (r *PostgresRepo) getOrder(ctx context.Context, orderUID string) (models.Order, error) {
 	var order models.Order
 
 	// Запрос в бд, данные таблицы orders
-	err := r.db.QueryRowContext(ctx, "SELECT * FROM orders WHERE order_uid = $1", orderUID).Scan(
+	var created sql.NullTime
+	err := r.q.QueryRowContext(ctx, "SELECT "+orderColumns+" FROM orders WHERE order_uid = $1", orderUID).Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
 		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID,
-		&order.DateCreated, &order.OofShard,
+		&created, &order.OofShard,
 	)
 	if err != nil {
 		return models.Order{}, err
 	}
+	order.DateCreated = created.Time
 
 	// Запрос в бд, данные таблицы delivery
-	err = r.db.QueryRowContext(ctx, "SELECT * FROM delivery WHERE order_uid = $1", orderUID).Scan(
+	err = r.q.QueryRowContext(ctx, "SELECT "+deliveryColumns+" FROM delivery WHERE order_uid = $1", orderUID).Scan(
 		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
 		&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region,
 		&order.Delivery.Email,
@@ -128,7 +327,7 @@ func (r *PostgresRepo) GetOrderById(ctx context.Context, orderUID string) (model
 	}
 
 	// Запрос в бд, данные таблицы payment
-	err = r.db.QueryRowContext(ctx, "SELECT * FROM payment WHERE transaction = $1", orderUID).Scan(
+	err = r.q.QueryRowContext(ctx, "SELECT "+paymentColumns+" FROM payment WHERE order_uid = $1", orderUID).Scan(
 		&order.Payment.Transaction, &order.Payment.Currency, &order.Payment.Provider,
 		&order.Payment.Amount, &order.Payment.PaymentDT, &order.Payment.Bank,
 		&order.Payment.DeliveryCost, &order.Payment.GoodsTotal, &order.Payment.CustomFee,
@@ -138,7 +337,7 @@ func (r *PostgresRepo) GetOrderById(ctx context.Context, orderUID string) (model
 	}
 
 	// Запрос в бд, данные таблицы items
-	rows, err := r.db.QueryContext(ctx, "SELECT * FROM items WHERE order_uid = $1", orderUID)
+	rows, err := r.q.QueryContext(ctx, "SELECT "+itemColumns+" FROM items WHERE order_uid = $1 ORDER BY id", orderUID)
 	if err != nil {
 		return models.Order{}, err
 	}
@@ -154,6 +353,110 @@ func (r *PostgresRepo) GetOrderById(ctx context.Context, orderUID string) (model
 		}
 		order.Items = append(order.Items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return models.Order{}, err
+	}
 
 	return order, nil
 }
+
+// UpdateOrderStatus проставляет статус всем товарам заказа
+// Возвращает ErrNotFound (и sql.ErrNoRows), если у заказа нет товаров (заказа нет в базе)
+func (r *PostgresRepo) UpdateOrderStatus(ctx context.Context, orderUID string, status int) error {
+	res, err := r.q.ExecContext(ctx, `UPDATE items SET status = $1 WHERE order_uid = $2`, status, orderUID)
+	if err != nil {
+		return classify(err)
+	}
+	return classify(requireAffected(res))
+}
+
+// DeleteOrder удаляет заказ, delivery/payment/items удаляются каскадом
+// Возвращает ErrNotFound (и sql.ErrNoRows), если заказа нет в базе
+func (r *PostgresRepo) DeleteOrder(ctx context.Context, orderUID string) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM orders WHERE order_uid = $1`, orderUID)
+	if err != nil {
+		return classify(err)
+	}
+	return classify(requireAffected(res))
+}
+
+// requireAffected - sql.ErrNoRows, если запрос не затронул ни одной строки
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FetchOutbox возвращает неотправленные события outbox в порядке записи
+func (r *PostgresRepo) FetchOutbox(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT id, event_type, order_uid, payload, created_at
+		FROM outbox
+		WHERE sent_at IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.EventType, &e.OrderUID, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// MarkOutboxSent помечает события отправленными, повторно relay их не публикует
+func (r *PostgresRepo) MarkOutboxSent(ctx context.Context, ids []int64) error {
+	_, err := r.q.ExecContext(ctx, `UPDATE outbox SET sent_at = now() WHERE id = ANY($1)`, pq.Array(ids))
+	return err
+}
+
+// ProcessedEvents возвращает, какие из событий уже обработаны
+func (r *PostgresRepo) ProcessedEvents(ctx context.Context, ids []string) (map[string]bool, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT event_id FROM processed_events WHERE event_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		seen[id] = true
+	}
+
+	return seen, rows.Err()
+}
+
+// MarkProcessed отмечает события обработанными, повторная отметка ничего не меняет
+func (r *PostgresRepo) MarkProcessed(ctx context.Context, ids []string) error {
+	_, err := r.q.ExecContext(ctx,
+		`INSERT INTO processed_events (event_id)
+		SELECT unnest($1::text[])
+		ON CONFLICT (event_id) DO NOTHING`, pq.Array(ids))
+	return err
+}
+
+// PurgeProcessed удаляет отметки старше before, возвращает число удалённых
+func (r *PostgresRepo) PurgeProcessed(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM processed_events WHERE processed_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
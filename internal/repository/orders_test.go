@@ -3,7 +3,10 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,7 +19,7 @@ import (
 // Мы создаём sqlmock, задаем ожидаемые SQL-запросы, их аргументы,
 // возвращаемые значения и подтверждаем, что транзакция выполняется
 // строго в правильной последовательности: BEGIN - INSERT orders -
-// INSERT delivery - INSERT payment - INSERT items - COMMIT
+// INSERT delivery - INSERT payment - INSERT items - INSERT outbox - COMMIT
 func TestSaveOrder_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -99,6 +102,15 @@ func TestSaveOrder_Success(t *testing.T) {
 			order.Items[0].Brand, order.Items[0].Status).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
+	// Ожидаем INSERT события order.stored в outbox в той же транзакции
+	mock.ExpectExec(regexp.QuoteMeta(
+		`INSERT INTO outbox
+        (event_type, order_uid, payload)
+        VALUES ($1, $2, $3)`,
+	)).
+		WithArgs(models.EventOrderStored, order.OrderUID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
 	// Ожидаем коммит транзакции
 	mock.ExpectCommit()
 
@@ -146,7 +158,7 @@ func TestGetOrderById_NotFound(t *testing.T) {
 
 	// Мы ожидаем SELECT по order_uid,
 	// и он должен вернуть sql.ErrNoRows
-	mock.ExpectQuery("SELECT \\* FROM orders").
+	mock.ExpectQuery("SELECT order_uid, .* FROM orders").
 		WithArgs("missing").
 		WillReturnError(sql.ErrNoRows)
 
@@ -156,3 +168,239 @@ func TestGetOrderById_NotFound(t *testing.T) {
 	// И все ожидания должны быть выполнены
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+// columns - имена колонок из списка SELECT, выражения вроде COALESCE(x, ”) дают x
+func columns(list string) []string {
+	return regexp.MustCompile(`\b[a-z_]+\b`).FindAllString(list, -1)
+}
+
+// TestGetOrder_ColumnsInSchema сверяет колонки, которые читает getOrder, со схемой internal/db/schema.sql:
+// каждая колонка есть в своей таблице, а колонки без NOT NULL читаются через COALESCE
+func TestGetOrder_ColumnsInSchema(t *testing.T) {
+	ddl, err := os.ReadFile(filepath.Join("..", "db", "schema.sql"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	tables := make(map[string]map[string]bool) // таблица -> колонка -> может ли быть NULL
+	for _, m := range regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\);`).FindAllStringSubmatch(string(ddl), -1) {
+		cols := make(map[string]bool)
+		for _, line := range strings.Split(m[2], "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				cols[fields[0]] = !strings.Contains(line, "NOT NULL") && !strings.Contains(line, "PRIMARY KEY")
+			}
+		}
+		tables[m[1]] = cols
+	}
+
+	for table, list := range map[string]string{
+		"orders":   orderColumns,
+		"delivery": deliveryColumns,
+		"payment":  paymentColumns,
+		"items":    itemColumns,
+	} {
+		if !assert.Contains(t, tables, table) {
+			continue
+		}
+		for _, col := range columns(list) {
+			nullable, ok := tables[table][col]
+			assert.True(t, ok, "%s.%s нет в схеме", table, col)
+			if nullable && col != "date_created" { // date_created читается в sql.NullTime
+				assert.Contains(t, list, "COALESCE("+col+",", "%s.%s может быть NULL", table, col)
+			}
+		}
+	}
+}
+
+// TestGetOrderById_Success проверяет чтение заказа: колонки каждого SELECT совпадают с целями Scan
+func TestGetOrderById_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + orderColumns + " FROM orders")).WithArgs("b563").
+		WillReturnRows(sqlmock.NewRows(columns(orderColumns)).
+			AddRow("b563", "WBILMTESTTRACK", "WBIL", "en", "test", "meest", "9", 99, created, "1"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + deliveryColumns + " FROM delivery")).WithArgs("b563").
+		WillReturnRows(sqlmock.NewRows(columns(deliveryColumns)).
+			AddRow("Test Testov", "+9720000000", "2639809", "Kiryat Mozkin", "Ploshad Mira 15", "Kraiot", "test@gmail.com"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + paymentColumns + " FROM payment")).WithArgs("b563").
+		WillReturnRows(sqlmock.NewRows(columns(paymentColumns)).
+			AddRow("b563", "USD", "wbpay", 1817, 1637907727, "alpha", 1500, 317, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + itemColumns + " FROM items")).WithArgs("b563").
+		WillReturnRows(sqlmock.NewRows(columns(itemColumns)).
+			AddRow(9934930, "WBILMTESTTRACK", 453, "ab42", "Mascaras", 30, "0", 317, 2389212, "Vivienne Sabo", 202))
+
+	order, err := NewPostgresRepo(db).GetOrderById(context.Background(), "b563")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "WBILMTESTTRACK", order.TrackNumber)
+	assert.Equal(t, created, order.DateCreated)
+	assert.Equal(t, "Kraiot", order.Delivery.Region)
+	assert.Equal(t, 1817, order.Payment.Amount)
+	if assert.Len(t, order.Items, 1) {
+		assert.Equal(t, 202, order.Items[0].Status)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Пачка из двух заказов сохраняется одной транзакцией:
+// BEGIN - один многострочный INSERT на каждую таблицу, включая outbox - COMMIT
+func TestSaveOrders_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+	orders := []models.Order{
+		{OrderUID: "id1", Items: []models.Item{{ChrtID: 1, Name: "A"}, {ChrtID: 2, Name: "B"}}},
+		{OrderUID: "id2", Items: []models.Item{{ChrtID: 3, Name: "C"}}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(
+		`INSERT INTO orders (order_uid, track_number, entry, locale, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10), ($11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`,
+	)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO delivery`)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO payment`)).WillReturnResult(sqlmock.NewResult(0, 2))
+	// 3 товара по 12 колонок - последний плейсхолдер $36
+	mock.ExpectExec(`INSERT INTO items .* \(\$25, .*\$36\)$`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox (event_type, order_uid, payload) VALUES ($1, $2, $3), ($4, $5, $6)`)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.SaveOrders(context.Background(), orders)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Ошибка вставки в середине пачки откатывает всю транзакцию
+func TestSaveOrders_Rollback(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+	orders := []models.Order{{OrderUID: "id1"}, {OrderUID: "id2"}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO delivery").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	err := repo.SaveOrders(context.Background(), orders)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Неотправленные события outbox читаются по порядку id с лимитом
+func TestFetchOutbox(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT id, event_type, order_uid, payload, created_at\\s+FROM outbox\\s+WHERE sent_at IS NULL").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_type", "order_uid", "payload", "created_at"}).
+			AddRow(1, models.EventOrderStored, "id1", []byte(`{"order_uid":"id1"}`), now).
+			AddRow(2, models.EventOrderStored, "id2", []byte(`{"order_uid":"id2"}`), now))
+
+	events, err := repo.FetchOutbox(context.Background(), 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, int64(1), events[0].ID)
+		assert.Equal(t, "id2", events[1].OrderUID)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// События помечаются отправленными одним UPDATE по списку id
+func TestMarkOutboxSent(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET sent_at = now() WHERE id = ANY($1)`)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := repo.MarkOutboxSent(context.Background(), []int64{1, 2})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestUpdateOrderStatus_NotFound проверяет, что смена статуса несуществующего заказа возвращает sql.ErrNoRows
+func TestUpdateOrderStatus_NotFound(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE items SET status = $1 WHERE order_uid = $2`)).
+		WithArgs(202, "nope").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.UpdateOrderStatus(context.Background(), "nope", 202)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeleteOrder_Success проверяет удаление заказа одним запросом (остальное удаляется каскадом)
+func TestDeleteOrder_Success(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM orders WHERE order_uid = $1`)).
+		WithArgs("test123").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.DeleteOrder(context.Background(), "test123")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestProcessedEvents проверяет, что возвращаются только уже обработанные события
+func TestProcessedEvents(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT event_id FROM processed_events WHERE event_id = ANY($1)`)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"event_id"}).AddRow("e1"))
+
+	seen, err := repo.ProcessedEvents(context.Background(), []string{"e1", "e2"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"e1": true}, seen)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestMarkAndPurgeProcessed проверяет отметку событий и удаление просроченных отметок
+func TestMarkAndPurgeProcessed(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := NewPostgresRepo(db)
+	before := time.Now()
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO processed_events (event_id) SELECT unnest($1::text[]) ON CONFLICT (event_id) DO NOTHING`)).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM processed_events WHERE processed_at < $1`)).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+
+	assert.NoError(t, repo.MarkProcessed(context.Background(), []string{"e1", "e2"}))
+	n, err := repo.PurgeProcessed(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
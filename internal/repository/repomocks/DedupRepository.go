@@ -0,0 +1,201 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+
+	time "time"
+)
+
+// DedupRepository is an autogenerated mock type for the DedupRepository type
+type DedupRepository struct {
+	mock.Mock
+}
+
+type DedupRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *DedupRepository) EXPECT() *DedupRepository_Expecter {
+	return &DedupRepository_Expecter{mock: &_m.Mock}
+}
+
+// MarkProcessed provides a mock function with given fields: ctx, ids
+func (_m *DedupRepository) MarkProcessed(ctx context.Context, ids []string) error {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkProcessed")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) error); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DedupRepository_MarkProcessed_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkProcessed'
+type DedupRepository_MarkProcessed_Call struct {
+	*mock.Call
+}
+
+// MarkProcessed is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []string
+func (_e *DedupRepository_Expecter) MarkProcessed(ctx interface{}, ids interface{}) *DedupRepository_MarkProcessed_Call {
+	return &DedupRepository_MarkProcessed_Call{Call: _e.mock.On("MarkProcessed", ctx, ids)}
+}
+
+func (_c *DedupRepository_MarkProcessed_Call) Run(run func(ctx context.Context, ids []string)) *DedupRepository_MarkProcessed_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]string))
+	})
+	return _c
+}
+
+func (_c *DedupRepository_MarkProcessed_Call) Return(_a0 error) *DedupRepository_MarkProcessed_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *DedupRepository_MarkProcessed_Call) RunAndReturn(run func(context.Context, []string) error) *DedupRepository_MarkProcessed_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ProcessedEvents provides a mock function with given fields: ctx, ids
+func (_m *DedupRepository) ProcessedEvents(ctx context.Context, ids []string) (map[string]bool, error) {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ProcessedEvents")
+	}
+
+	var r0 map[string]bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) (map[string]bool, error)); ok {
+		return rf(ctx, ids)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) map[string]bool); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]bool)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DedupRepository_ProcessedEvents_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ProcessedEvents'
+type DedupRepository_ProcessedEvents_Call struct {
+	*mock.Call
+}
+
+// ProcessedEvents is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []string
+func (_e *DedupRepository_Expecter) ProcessedEvents(ctx interface{}, ids interface{}) *DedupRepository_ProcessedEvents_Call {
+	return &DedupRepository_ProcessedEvents_Call{Call: _e.mock.On("ProcessedEvents", ctx, ids)}
+}
+
+func (_c *DedupRepository_ProcessedEvents_Call) Run(run func(ctx context.Context, ids []string)) *DedupRepository_ProcessedEvents_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]string))
+	})
+	return _c
+}
+
+func (_c *DedupRepository_ProcessedEvents_Call) Return(_a0 map[string]bool, _a1 error) *DedupRepository_ProcessedEvents_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *DedupRepository_ProcessedEvents_Call) RunAndReturn(run func(context.Context, []string) (map[string]bool, error)) *DedupRepository_ProcessedEvents_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgeProcessed provides a mock function with given fields: ctx, before
+func (_m *DedupRepository) PurgeProcessed(ctx context.Context, before time.Time) (int64, error) {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeProcessed")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int64, error)); ok {
+		return rf(ctx, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DedupRepository_PurgeProcessed_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgeProcessed'
+type DedupRepository_PurgeProcessed_Call struct {
+	*mock.Call
+}
+
+// PurgeProcessed is a helper method to define mock.On call
+//   - ctx context.Context
+//   - before time.Time
+func (_e *DedupRepository_Expecter) PurgeProcessed(ctx interface{}, before interface{}) *DedupRepository_PurgeProcessed_Call {
+	return &DedupRepository_PurgeProcessed_Call{Call: _e.mock.On("PurgeProcessed", ctx, before)}
+}
+
+func (_c *DedupRepository_PurgeProcessed_Call) Run(run func(ctx context.Context, before time.Time)) *DedupRepository_PurgeProcessed_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(time.Time))
+	})
+	return _c
+}
+
+func (_c *DedupRepository_PurgeProcessed_Call) Return(_a0 int64, _a1 error) *DedupRepository_PurgeProcessed_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *DedupRepository_PurgeProcessed_Call) RunAndReturn(run func(context.Context, time.Time) (int64, error)) *DedupRepository_PurgeProcessed_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewDedupRepository creates a new instance of DedupRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewDedupRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *DedupRepository {
+	mock := &DedupRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -24,6 +24,53 @@ func (_m *OrderRepository) EXPECT() *OrderRepository_Expecter {
 	return &OrderRepository_Expecter{mock: &_m.Mock}
 }
 
+// DeleteOrder provides a mock function with given fields: ctx, orderUID
+func (_m *OrderRepository) DeleteOrder(ctx context.Context, orderUID string) error {
+	ret := _m.Called(ctx, orderUID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteOrder")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, orderUID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// OrderRepository_DeleteOrder_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DeleteOrder'
+type OrderRepository_DeleteOrder_Call struct {
+	*mock.Call
+}
+
+// DeleteOrder is a helper method to define mock.On call
+//   - ctx context.Context
+//   - orderUID string
+func (_e *OrderRepository_Expecter) DeleteOrder(ctx interface{}, orderUID interface{}) *OrderRepository_DeleteOrder_Call {
+	return &OrderRepository_DeleteOrder_Call{Call: _e.mock.On("DeleteOrder", ctx, orderUID)}
+}
+
+func (_c *OrderRepository_DeleteOrder_Call) Run(run func(ctx context.Context, orderUID string)) *OrderRepository_DeleteOrder_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *OrderRepository_DeleteOrder_Call) Return(_a0 error) *OrderRepository_DeleteOrder_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *OrderRepository_DeleteOrder_Call) RunAndReturn(run func(context.Context, string) error) *OrderRepository_DeleteOrder_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetOrderById provides a mock function with given fields: ctx, orderUID
 func (_m *OrderRepository) GetOrderById(ctx context.Context, orderUID string) (models.Order, error) {
 	ret := _m.Called(ctx, orderUID)
@@ -256,6 +303,101 @@ func (_c *OrderRepository_SaveOrder_Call) RunAndReturn(run func(context.Context,
 	return _c
 }
 
+// SaveOrders provides a mock function with given fields: ctx, orders
+func (_m *OrderRepository) SaveOrders(ctx context.Context, orders []models.Order) error {
+	ret := _m.Called(ctx, orders)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveOrders")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []models.Order) error); ok {
+		r0 = rf(ctx, orders)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// OrderRepository_SaveOrders_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveOrders'
+type OrderRepository_SaveOrders_Call struct {
+	*mock.Call
+}
+
+// SaveOrders is a helper method to define mock.On call
+//   - ctx context.Context
+//   - orders []models.Order
+func (_e *OrderRepository_Expecter) SaveOrders(ctx interface{}, orders interface{}) *OrderRepository_SaveOrders_Call {
+	return &OrderRepository_SaveOrders_Call{Call: _e.mock.On("SaveOrders", ctx, orders)}
+}
+
+func (_c *OrderRepository_SaveOrders_Call) Run(run func(ctx context.Context, orders []models.Order)) *OrderRepository_SaveOrders_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]models.Order))
+	})
+	return _c
+}
+
+func (_c *OrderRepository_SaveOrders_Call) Return(_a0 error) *OrderRepository_SaveOrders_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *OrderRepository_SaveOrders_Call) RunAndReturn(run func(context.Context, []models.Order) error) *OrderRepository_SaveOrders_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateOrderStatus provides a mock function with given fields: ctx, orderUID, status
+func (_m *OrderRepository) UpdateOrderStatus(ctx context.Context, orderUID string, status int) error {
+	ret := _m.Called(ctx, orderUID, status)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOrderStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) error); ok {
+		r0 = rf(ctx, orderUID, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// OrderRepository_UpdateOrderStatus_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateOrderStatus'
+type OrderRepository_UpdateOrderStatus_Call struct {
+	*mock.Call
+}
+
+// UpdateOrderStatus is a helper method to define mock.On call
+//   - ctx context.Context
+//   - orderUID string
+//   - status int
+func (_e *OrderRepository_Expecter) UpdateOrderStatus(ctx interface{}, orderUID interface{}, status interface{}) *OrderRepository_UpdateOrderStatus_Call {
+	return &OrderRepository_UpdateOrderStatus_Call{Call: _e.mock.On("UpdateOrderStatus", ctx, orderUID, status)}
+}
+
+func (_c *OrderRepository_UpdateOrderStatus_Call) Run(run func(ctx context.Context, orderUID string, status int)) *OrderRepository_UpdateOrderStatus_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(int))
+	})
+	return _c
+}
+
+func (_c *OrderRepository_UpdateOrderStatus_Call) Return(_a0 error) *OrderRepository_UpdateOrderStatus_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *OrderRepository_UpdateOrderStatus_Call) RunAndReturn(run func(context.Context, string, int) error) *OrderRepository_UpdateOrderStatus_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewOrderRepository creates a new instance of OrderRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewOrderRepository(t interface {
@@ -0,0 +1,143 @@
+// Code generated by mockery v2.53.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	context "context"
+
+	models "github.com/fathersson/wb-demo-service/internal/models"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// OutboxRepository is an autogenerated mock type for the OutboxRepository type
+type OutboxRepository struct {
+	mock.Mock
+}
+
+type OutboxRepository_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *OutboxRepository) EXPECT() *OutboxRepository_Expecter {
+	return &OutboxRepository_Expecter{mock: &_m.Mock}
+}
+
+// FetchOutbox provides a mock function with given fields: ctx, limit
+func (_m *OutboxRepository) FetchOutbox(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FetchOutbox")
+	}
+
+	var r0 []models.OutboxEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]models.OutboxEvent, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []models.OutboxEvent); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.OutboxEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// OutboxRepository_FetchOutbox_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FetchOutbox'
+type OutboxRepository_FetchOutbox_Call struct {
+	*mock.Call
+}
+
+// FetchOutbox is a helper method to define mock.On call
+//   - ctx context.Context
+//   - limit int
+func (_e *OutboxRepository_Expecter) FetchOutbox(ctx interface{}, limit interface{}) *OutboxRepository_FetchOutbox_Call {
+	return &OutboxRepository_FetchOutbox_Call{Call: _e.mock.On("FetchOutbox", ctx, limit)}
+}
+
+func (_c *OutboxRepository_FetchOutbox_Call) Run(run func(ctx context.Context, limit int)) *OutboxRepository_FetchOutbox_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int))
+	})
+	return _c
+}
+
+func (_c *OutboxRepository_FetchOutbox_Call) Return(_a0 []models.OutboxEvent, _a1 error) *OutboxRepository_FetchOutbox_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *OutboxRepository_FetchOutbox_Call) RunAndReturn(run func(context.Context, int) ([]models.OutboxEvent, error)) *OutboxRepository_FetchOutbox_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// MarkOutboxSent provides a mock function with given fields: ctx, ids
+func (_m *OutboxRepository) MarkOutboxSent(ctx context.Context, ids []int64) error {
+	ret := _m.Called(ctx, ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkOutboxSent")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) error); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// OutboxRepository_MarkOutboxSent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'MarkOutboxSent'
+type OutboxRepository_MarkOutboxSent_Call struct {
+	*mock.Call
+}
+
+// MarkOutboxSent is a helper method to define mock.On call
+//   - ctx context.Context
+//   - ids []int64
+func (_e *OutboxRepository_Expecter) MarkOutboxSent(ctx interface{}, ids interface{}) *OutboxRepository_MarkOutboxSent_Call {
+	return &OutboxRepository_MarkOutboxSent_Call{Call: _e.mock.On("MarkOutboxSent", ctx, ids)}
+}
+
+func (_c *OutboxRepository_MarkOutboxSent_Call) Run(run func(ctx context.Context, ids []int64)) *OutboxRepository_MarkOutboxSent_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].([]int64))
+	})
+	return _c
+}
+
+func (_c *OutboxRepository_MarkOutboxSent_Call) Return(_a0 error) *OutboxRepository_MarkOutboxSent_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *OutboxRepository_MarkOutboxSent_Call) RunAndReturn(run func(context.Context, []int64) error) *OutboxRepository_MarkOutboxSent_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewOutboxRepository creates a new instance of OutboxRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewOutboxRepository(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *OutboxRepository {
+	mock := &OutboxRepository{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/fathersson/wb-demo-service/internal/tracing"
+)
+
+var tracer = tracing.Tracer("github.com/fathersson/wb-demo-service/internal/repository")
+
+// querier - общее у *sql.DB и *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// traced открывает спан на каждый SQL-запрос
+// В атрибуты попадает только текст запроса: аргументы содержат персональные данные
+type traced struct {
+	q querier
+}
+
+func (t traced) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startStatement(ctx, query)
+	res, err := t.q.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+func (t traced) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startStatement(ctx, query)
+	rows, err := t.q.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+// QueryRowContext завершает спан сразу: ошибка *sql.Row станет известна только при Scan
+func (t traced) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startStatement(ctx, query)
+	row := t.q.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+func startStatement(ctx context.Context, query string) (context.Context, trace.Span) {
+	op, table := statementName(query)
+	name := op
+	if table != "" {
+		name += " " + table
+	}
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", op),
+			attribute.String("db.collection.name", table),
+			attribute.String("db.query.text", query),
+		))
+}
+
+// statementName - операция и таблица запроса для имени спана: "INSERT orders", "SELECT delivery"
+func statementName(query string) (op, table string) {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	op = strings.ToUpper(fields[0])
+
+	// таблица идёт после FROM, INTO или UPDATE
+	for i, f := range fields[:len(fields)-1] {
+		switch strings.ToUpper(f) {
+		case "FROM", "INTO", "UPDATE":
+			return op, strings.Trim(fields[i+1], `"(`)
+		}
+	}
+	return op, ""
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		query, op, table string
+	}{
+		{"INSERT INTO orders \n(order_uid) VALUES ($1)", "INSERT", "orders"},
+		{"SELECT * FROM delivery WHERE order_uid = $1", "SELECT", "delivery"},
+		{"UPDATE items SET status = $1", "UPDATE", "items"},
+		{"delete from processed_events where processed_at < $1", "DELETE", "processed_events"},
+		{"SELECT 1", "SELECT", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		op, table := statementName(tt.query)
+		assert.Equal(t, tt.op, op, tt.query)
+		assert.Equal(t, tt.table, table, tt.query)
+	}
+}
+
+// TestSaveOrder_Spans проверяет трейсинг сохранения заказа:
+// 1) SaveOrder - спан-родитель, каждый запрос транзакции - отдельный дочерний спан
+// 2) В атрибуты попадает текст запроса, но не аргументы с персональными данными
+func TestSaveOrder_Spans(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	order := models.Order{
+		OrderUID: "id1",
+		Delivery: models.Delivery{Name: "Ivan Petrov", Phone: "+79991234567"},
+		Items:    []models.Item{{ChrtID: 1}, {ChrtID: 2}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO orders").WillReturnRows(sqlmock.NewRows([]string{"order_uid"}).AddRow("id1"))
+	mock.ExpectExec("INSERT INTO delivery").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO payment").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO items").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO items").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, NewPostgresRepo(db).SaveOrder(context.Background(), order))
+
+	var parent sdktrace.ReadOnlySpan
+	var statements []string
+	for _, s := range rec.Ended() {
+		if s.Name() == "SaveOrder" {
+			parent = s
+		}
+	}
+	require.NotNil(t, parent)
+	for _, s := range rec.Ended() {
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			continue
+		}
+		statements = append(statements, s.Name())
+		for _, attr := range s.Attributes() {
+			assert.NotContains(t, attr.Value.Emit(), "Ivan")
+		}
+	}
+	assert.Equal(t, []string{"INSERT orders", "INSERT delivery", "INSERT payment", "INSERT items", "INSERT items", "INSERT outbox"}, statements)
+}
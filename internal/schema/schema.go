@@ -0,0 +1,318 @@
+// Package schema - JSON Schema заказа, выведенная из models.Order и её тегов validate,
+// чтобы продюсеры и консьюмер проверяли заказ по одному контракту
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Draft - версия JSON Schema
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// OrderID - $id схемы заказа, по нему же она отдаётся HTTP-сервером
+const OrderID = "/schema/order.json"
+
+// Регулярные выражения тегов validate, те же, что в go-playground/validator
+const (
+	alphanumPattern = "^[a-zA-Z0-9]+$"
+	numericPattern  = "^[-+]?[0-9]+(?:\\.[0-9]+)?$"
+)
+
+// Schema - узел JSON Schema, только ключевые слова, которые даёт перевод тегов validate
+type Schema struct {
+	Schema           string             `json:"$schema,omitempty"`
+	ID               string             `json:"$id,omitempty"`
+	Title            string             `json:"title,omitempty"`
+	Type             string             `json:"type,omitempty"`
+	Format           string             `json:"format,omitempty"`
+	Pattern          string             `json:"pattern,omitempty"`
+	MinLength        *int               `json:"minLength,omitempty"`
+	MaxLength        *int               `json:"maxLength,omitempty"`
+	Minimum          *int               `json:"minimum,omitempty"`
+	ExclusiveMinimum *int               `json:"exclusiveMinimum,omitempty"`
+	MinItems         *int               `json:"minItems,omitempty"`
+	Const            any                `json:"const,omitempty"`
+	Not              *Schema            `json:"not,omitempty"`
+	AnyOf            []*Schema          `json:"anyOf,omitempty"`
+	Items            *Schema            `json:"items,omitempty"`
+	Properties       map[string]*Schema `json:"properties,omitempty"`
+	Required         []string           `json:"required,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate строит схему для структуры по тегам json и validate
+// Поддерживаются правила required, omitempty, alphanum, numeric, email, min, gt и dive, остальные теги пропускаются.
+// Как и validator, элементы срезов проверяются только с dive, без него в схеме остаются одни типы полей
+func Generate(v any) (*Schema, error) {
+	return generate(reflect.TypeOf(v), true)
+}
+
+func generate(t reflect.Type, rules bool) (*Schema, error) {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t.Kind() == reflect.Pointer:
+		return generate(t.Elem(), rules)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Slice:
+		items, err := generate(t.Elem(), false)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		return generateObject(t, rules)
+	default:
+		return nil, fmt.Errorf("тип %s не поддерживается", t)
+	}
+}
+
+func generateObject(t reflect.Type, rules bool) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		tag := f.Tag.Get("validate")
+		prop, err := generate(f.Type, rules)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		if !rules {
+			s.Properties[name] = prop
+			continue
+		}
+
+		// dive: правила после него относятся к элементам среза
+		tag, elemTag, dive := cutDive(tag)
+		if dive && prop.Type == "array" {
+			if prop.Items, err = generate(f.Type.Elem(), true); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			}
+			if _, err := applyRules(prop.Items, elemTag); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			}
+		}
+
+		required, err := applyRules(prop, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s, nil
+}
+
+// cutDive делит тег validate на правила самого поля и правила элементов после dive
+func cutDive(tag string) (field, elem string, dive bool) {
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == "dive" {
+			return strings.Join(rules[:i], ","), strings.Join(rules[i+1:], ","), true
+		}
+	}
+	return tag, "", false
+}
+
+// applyRules переводит тег validate в ограничения схемы поля, возвращает, обязательно ли поле
+func applyRules(s *Schema, tag string) (bool, error) {
+	if tag == "" || tag == "-" {
+		return false, nil
+	}
+
+	rules := &Schema{}
+	var required, omitempty bool
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+			switch s.Type {
+			case "string":
+				rules.MinLength = maxInt(rules.MinLength, 1)
+			case "integer", "number":
+				// required в validator - не нулевое значение
+				rules.Not = &Schema{Const: 0}
+			}
+		case "omitempty":
+			omitempty = true
+		case "alphanum":
+			rules.Pattern = alphanumPattern
+		case "numeric":
+			rules.Pattern = numericPattern
+		case "email":
+			rules.Format = "email"
+		case "min", "gt":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				return false, fmt.Errorf("некорректный параметр %q", rule)
+			}
+			if name == "gt" {
+				// gt для строк и массивов - длина больше n, т.е. не меньше n+1
+				if s.Type == "integer" || s.Type == "number" {
+					rules.ExclusiveMinimum = &n
+					continue
+				}
+				n++
+			}
+			switch s.Type {
+			case "string":
+				rules.MinLength = maxInt(rules.MinLength, n)
+			case "array":
+				rules.MinItems = &n
+			default:
+				rules.Minimum = &n
+			}
+		}
+	}
+
+	// omitempty: правила проверяются только для непустого значения
+	if omitempty && !rules.acceptsZero() {
+		s.AnyOf = []*Schema{zeroValue(s.Type), rules}
+		return required, nil
+	}
+	s.merge(rules)
+	return required, nil
+}
+
+// acceptsZero - проходит ли нулевое значение (пустая строка, 0) эти правила
+func (s *Schema) acceptsZero() bool {
+	if s.Pattern != "" || s.Format != "" || (s.MinLength != nil && *s.MinLength > 0) || (s.MinItems != nil && *s.MinItems > 0) {
+		return false
+	}
+	if s.Not != nil || s.ExclusiveMinimum != nil && *s.ExclusiveMinimum >= 0 || s.Minimum != nil && *s.Minimum > 0 {
+		return false
+	}
+	return true
+}
+
+// zeroValue - схема нулевого значения типа
+func zeroValue(typ string) *Schema {
+	switch typ {
+	case "string":
+		return &Schema{MaxLength: new(int)}
+	case "array":
+		return &Schema{Const: []any{}}
+	default:
+		return &Schema{Const: 0}
+	}
+}
+
+// merge переносит ограничения из o
+func (s *Schema) merge(o *Schema) {
+	if o.Format != "" {
+		s.Format = o.Format
+	}
+	if o.Pattern != "" {
+		s.Pattern = o.Pattern
+	}
+	if o.MinLength != nil {
+		s.MinLength = o.MinLength
+	}
+	if o.Minimum != nil {
+		s.Minimum = o.Minimum
+	}
+	if o.ExclusiveMinimum != nil {
+		s.ExclusiveMinimum = o.ExclusiveMinimum
+	}
+	if o.MinItems != nil {
+		s.MinItems = o.MinItems
+	}
+	if o.Not != nil {
+		s.Not = o.Not
+	}
+}
+
+func maxInt(cur *int, n int) *int {
+	if cur != nil && *cur > n {
+		return cur
+	}
+	return &n
+}
+
+// Order - схема заказа models.Order
+func Order() *Schema {
+	s, err := Generate(models.Order{})
+	if err != nil {
+		// Модель фиксирована на этапе компиляции, ошибка - баг в тегах
+		panic(fmt.Sprintf("схема заказа: %v", err))
+	}
+	s.Schema = Draft
+	s.ID = OrderID
+	s.Title = "Order"
+	return s
+}
+
+// orderJSON - схема заказа в JSON, считается один раз
+var orderJSON = sync.OnceValue(func() []byte {
+	data, _ := json.MarshalIndent(Order(), "", "  ")
+	return data
+})
+
+// OrderJSON - схема заказа в JSON для отдачи продюсерам
+func OrderJSON() []byte {
+	return orderJSON()
+}
+
+// orderValidator - скомпилированная схема заказа
+var orderValidator = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(OrderJSON()))
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	if err := c.AddResource(OrderID, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(OrderID)
+})
+
+// ValidateOrder проверяет "сырой" JSON заказа по схеме, до декодирования в models.Order
+func ValidateOrder(data []byte) error {
+	s, err := orderValidator()
+	if err != nil {
+		return fmt.Errorf("схема заказа не скомпилировалась: %w", err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return s.Validate(doc)
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+// validOrder - полностью корректный заказ
+const validOrder = `{
+	"order_uid": "full123",
+	"track_number": "TRACK001",
+	"delivery": {"name":"Ivan","phone":"+79990000000","zip":"123456","city":"Moscow","address":"Street 1","email":""},
+	"payment": {"transaction":"full123","currency":"RUB","provider":"bank","amount":1000,"payment_dt":1234567890,"delivery_cost":200,"goods_total":800},
+	"items": [{"chrt_id":1,"name":"Item","price":100,"total_price":100,"rid":""}],
+	"shardkey": "9"
+}`
+
+// TestOrder_Rules проверяет перевод тегов validate в ключевые слова JSON Schema
+func TestOrder_Rules(t *testing.T) {
+	s := Order()
+
+	assert.Equal(t, []string{"order_uid", "track_number", "delivery", "payment", "items"}, s.Required)
+
+	uid := s.Properties["order_uid"]
+	assert.Equal(t, alphanumPattern, uid.Pattern)
+	assert.Equal(t, 1, *uid.MinLength)
+
+	assert.Equal(t, 1, *s.Properties["items"].MinItems)
+	assert.Equal(t, "date-time", s.Properties["date_created"].Format)
+
+	// omitempty: пустое значение разрешено, непустое проверяется
+	email := s.Properties["delivery"].Properties["email"]
+	if assert.Len(t, email.AnyOf, 2) {
+		assert.Equal(t, 0, *email.AnyOf[0].MaxLength)
+		assert.Equal(t, "email", email.AnyOf[1].Format)
+	}
+
+	// min=0 без required пропускает ноль и так, без anyOf
+	custom := s.Properties["payment"].Properties["custom_fee"]
+	assert.Empty(t, custom.AnyOf)
+	assert.Equal(t, 0, *custom.Minimum)
+
+	// Без dive validator не проверяет элементы items, в схеме у них только типы
+	chrtID := s.Properties["items"].Items.Properties["chrt_id"]
+	assert.Equal(t, "integer", chrtID.Type)
+	assert.Nil(t, chrtID.ExclusiveMinimum)
+	assert.Empty(t, s.Properties["items"].Items.Required)
+}
+
+// TestGenerate_Dive проверяет, что с dive правила элементов переносятся в items
+func TestGenerate_Dive(t *testing.T) {
+	type item struct {
+		ID int `json:"id" validate:"required,gt=0"`
+	}
+	type list struct {
+		Items []item   `json:"items" validate:"required,min=1,dive"`
+		Tags  []string `json:"tags" validate:"dive,alphanum"`
+	}
+
+	s, err := Generate(list{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"items"}, s.Required)
+	assert.Equal(t, 1, *s.Properties["items"].MinItems)
+	assert.Equal(t, []string{"id"}, s.Properties["items"].Items.Required)
+	assert.Equal(t, 0, *s.Properties["items"].Items.Properties["id"].ExclusiveMinimum)
+	assert.Equal(t, alphanumPattern, s.Properties["tags"].Items.Pattern)
+}
+
+// TestValidateOrder_MatchesValidator проверяет, что JSON Schema и теги validate согласны:
+// каждый пример одинаково принимается или отклоняется и схемой, и validator
+func TestValidateOrder_MatchesValidator(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch func(o map[string]any)
+		valid bool
+	}{
+		{"корректный", func(map[string]any) {}, true},
+		{"без items", func(o map[string]any) { delete(o, "items") }, false},
+		{"пустые items", func(o map[string]any) { o["items"] = []any{} }, false},
+		{"order_uid не alphanum", func(o map[string]any) { o["order_uid"] = "a-1" }, false},
+		{"shardkey не numeric", func(o map[string]any) { o["shardkey"] = "x" }, false},
+		{"некорректный email", func(o map[string]any) { o["delivery"].(map[string]any)["email"] = "not_email" }, false},
+		{"корректный email", func(o map[string]any) { o["delivery"].(map[string]any)["email"] = "ivan@example.com" }, true},
+		{"нулевая сумма", func(o map[string]any) { o["payment"].(map[string]any)["amount"] = 0 }, false},
+		{"отрицательная комиссия", func(o map[string]any) { o["payment"].(map[string]any)["custom_fee"] = -1 }, false},
+		{"нулевой chrt_id (без dive не проверяется)", func(o map[string]any) {
+			o["items"].([]any)[0].(map[string]any)["chrt_id"] = 0
+		}, true},
+	}
+
+	validate := validator.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc map[string]any
+			assert.NoError(t, json.Unmarshal([]byte(validOrder), &doc))
+			tt.patch(doc)
+			data, _ := json.Marshal(doc)
+
+			schemaErr := ValidateOrder(data)
+
+			var order models.Order
+			assert.NoError(t, json.Unmarshal(data, &order))
+			tagErr := validate.Struct(order)
+
+			assert.Equal(t, tt.valid, schemaErr == nil, "JSON Schema: %v", schemaErr)
+			assert.Equal(t, tt.valid, tagErr == nil, "validate: %v", tagErr)
+		})
+	}
+}
+
+// TestValidateOrder_Types проверяет то, что validator не видит: неверные типы и не JSON
+func TestValidateOrder_Types(t *testing.T) {
+	assert.Error(t, ValidateOrder([]byte(`{"order_uid": 123, "track_number": 777}`)))
+	assert.Error(t, ValidateOrder([]byte(`{bad json`)))
+}
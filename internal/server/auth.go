@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/fathersson/wb-demo-service/internal/auth"
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/masking"
+	"github.com/fathersson/wb-demo-service/internal/ratelimit"
+)
+
+// Коды ошибок доступа
+const (
+	CodeUnauthorized = "unauthorized" // нет ключа или токена, либо они не прошли проверку
+	CodeForbidden    = "forbidden"    // клиент известен, но у него нет нужного скоупа
+)
+
+// realm - область защиты в WWW-Authenticate
+const realm = "wb-demo-service"
+
+// public - маршрут без аутентификации
+const public = ""
+
+// router - ServeMux, у каждого маршрута которого указан требуемый скоуп
+// Лимит и проверка доступа выполняются уже после выбора маршрута: на неизвестный путь или метод
+// по-прежнему 404 и 405, а r.Pattern остаётся на запросе, который видят метрики и трейсинг
+type router struct {
+	mux    *http.ServeMux
+	auth   *auth.Authenticator // nil - аутентификация выключена
+	policy *masking.Policy     // nil - поля ответов не маскируются
+	limits *ratelimit.Limits   // nil - без ограничения частоты
+
+	cacheControl map[string]string // Cache-Control по шаблону маршрута без метода
+}
+
+// handle регистрирует маршрут; scope public - доступен всем
+func (rt *router) handle(pattern, scope string, h http.Handler) {
+	rt.mux.Handle(pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secured := scope != public && rt.auth.Enabled()
+
+		// Клиента определяем до лимита: аутентифицированный расходует свой лимит, а не лимит адреса,
+		// запросы с неверными ключами - лимит адреса, чтобы перебор ключей тоже упирался в 429
+		var p auth.Principal
+		var err error
+		if secured {
+			p, err = rt.auth.Authenticate(r)
+		}
+		if !rt.allow(w, r, p, err) {
+			return
+		}
+
+		if secured {
+			if !rt.authorize(w, r, p, err, scope) {
+				return
+			}
+			r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		}
+		if cc, ok := rt.cacheControl[routeOf(r)]; ok {
+			w.Header().Set("Cache-Control", cc)
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
+
+func (rt *router) handleFunc(pattern, scope string, h http.HandlerFunc) {
+	rt.handle(pattern, scope, h)
+}
+
+// authorize проверяет результат аутентификации и скоуп, при отказе отвечает 401 или 403
+func (rt *router) authorize(w http.ResponseWriter, r *http.Request, p auth.Principal, err error, scope string) bool {
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+		writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "нужен заголовок X-API-Key или Authorization: Bearer")
+		return false
+	case err != nil:
+		slog.InfoContext(r.Context(), "Отказ в аутентификации", logger.Err(err))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`", error="invalid_token"`)
+		writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "ключ API или токен недействителен")
+		return false
+	case !p.HasScope(scope):
+		slog.InfoContext(r.Context(), "Недостаточно прав", "subject", p.Subject, "scope", scope)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`", error="insufficient_scope", scope="`+scope+`"`)
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "нужен скоуп "+scope)
+		return false
+	}
+	return true
+}
+
+// WithAuth включает проверку ключей и токенов на маршрутах со скоупом
+func WithAuth(a *auth.Authenticator) Option {
+	return func(rt *router) {
+		rt.auth = a
+	}
+}
+
+// WithFieldPolicy включает маскирование полей заказа по ролям клиента
+func WithFieldPolicy(p *masking.Policy) Option {
+	return func(rt *router) {
+		rt.policy = p
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/fathersson/wb-demo-service/internal/auth"
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/masking"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apiKey - запись AUTH_API_KEYS для ключа key
+func apiKey(name, key, scopes string) string {
+	sum := sha256.Sum256([]byte(key))
+	return name + ":" + hex.EncodeToString(sum[:]) + ":" + scopes
+}
+
+// authServer - сервер с двумя ключами: reader (orders:read) и ops (admin)
+func authServer(t *testing.T, cache *cachemocks.CacheInterface) *http.Server {
+	a, err := auth.New(context.Background(), config.AuthConfig{Enabled: true, APIKeys: []string{
+		apiKey("reader", "reader-key", "orders:read"),
+		apiKey("ops", "ops-key", "admin"),
+	}})
+	require.NoError(t, err)
+	return NewServer(config.HttpServer{Port: 8080}, cache, repomocks.NewOrderRepository(t),
+		WithAuth(a), WithMetrics(http.NotFoundHandler()), WithProbes(NewReadiness(0)))
+}
+
+func get(srv *http.Server, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		req.Header.Set(auth.APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestAuth_Orders
+// Проверяет защиту /order/{id}:
+// 1) Без ключа - 401 с WWW-Authenticate, кэш не запрашивается
+// 2) Неизвестный ключ - 401 invalid_token
+// 3) Ключ с orders:read - 200
+func TestAuth_Orders(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	srv := authServer(t, cache)
+
+	w := get(srv, "/order/id1", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), CodeUnauthorized)
+	assert.Equal(t, `Bearer realm="wb-demo-service"`, w.Header().Get("WWW-Authenticate"))
+
+	w = get(srv, "/order/id1", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	cache.EXPECT().GetCache("id1").Return(models.Order{OrderUID: "id1"}, true)
+	w = get(srv, "/order/id1", "reader-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// TestAuth_Scopes
+// Проверяет скоупы маршрутов: /metrics требует admin (403 для orders:read),
+// admin открывает любой маршрут, а пробы, схема и несуществующие маршруты не требуют ключа
+func TestAuth_Scopes(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	srv := authServer(t, cache)
+
+	w := get(srv, "/metrics", "reader-key")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), CodeForbidden)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `scope="admin"`)
+
+	assert.Equal(t, http.StatusNotFound, get(srv, "/metrics", "ops-key").Code) // NotFoundHandler из теста
+
+	cache.EXPECT().GetCache("id2").Return(models.Order{OrderUID: "id2"}, true)
+	assert.Equal(t, http.StatusOK, get(srv, "/order/id2", "ops-key").Code)
+
+	assert.Equal(t, http.StatusOK, get(srv, "/healthz", "").Code)
+	assert.Equal(t, http.StatusOK, get(srv, "/schema/order.json", "").Code)
+	assert.Equal(t, http.StatusNotFound, get(srv, "/order/a/b", "").Code)
+}
+
+// TestAuth_FieldPolicy
+// Проверяет маскирование по роли ключа: support видит адрес, но не телефон целиком и не реквизиты оплаты,
+// ответ помечен Vary, чтобы общие кэши не отдали его другому клиенту; товары заказа проходят ту же политику
+func TestAuth_FieldPolicy(t *testing.T) {
+	a, err := auth.New(context.Background(), config.AuthConfig{
+		Enabled:     true,
+		APIKeys:     []string{apiKey("desk", "desk-key", "orders:read")},
+		APIKeyRoles: map[string]string{"desk": "support"},
+	})
+	require.NoError(t, err)
+	policy, err := masking.Load(filepath.Join("..", "..", "field_policy.json"))
+	require.NoError(t, err)
+
+	cache := cachemocks.NewCacheInterface(t)
+	order := models.Order{OrderUID: "id1",
+		Delivery: models.Delivery{Phone: "+79991234567", Address: "Ploshad Mira 15"},
+		Payment:  models.Payment{Transaction: "tx1", Amount: 1817},
+		Items:    []models.Item{{ChrtID: 1, Name: "Mascaras", Price: 453}}}
+	cache.EXPECT().GetCache("id1").Return(order, true)
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repomocks.NewOrderRepository(t), WithAuth(a), WithFieldPolicy(policy))
+
+	w := get(srv, "/order/id1", "desk-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"address":"Ploshad Mira 15"`)
+	assert.Contains(t, w.Body.String(), `"phone":"+7********67"`)
+	assert.Contains(t, w.Body.String(), `"transaction":"***"`)
+	assert.NotContains(t, w.Body.String(), `"amount"`)
+	assert.Subset(t, w.Header().Values("Vary"), []string{"Authorization", auth.APIKeyHeader})
+
+	w = get(srv, "/order/id1/items", "desk-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"chrt_id":1,"price":453,"name":"Mascaras","total_price":0}]`, w.Body.String())
+	assert.Subset(t, w.Header().Values("Vary"), []string{"Authorization", auth.APIKeyHeader})
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encodings - поддерживаемые сжатия в порядке предпочтения сервера при равных весах
+var encodings = []string{"zstd", "gzip"}
+
+// Кодировщики переиспользуются: zstd на каждый ответ заново выделял бы окно в мегабайты
+var (
+	gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	zstdPool = sync.Pool{New: func() any { return newZstd() }}
+)
+
+// Первый кодировщик создаётся при загрузке пакета: ошибка в опциях zstd роняет старт, а не первый ответ
+func init() {
+	zstdPool.Put(newZstd())
+}
+
+// newZstd - кодировщик zstd для ответов
+func newZstd() *zstd.Encoder {
+	// Окно 8 МБ - столько обязаны поддерживать браузеры для Content-Encoding: zstd (RFC 8878)
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+	if err != nil {
+		// Опции фиксированы на этапе компиляции, ошибка - баг в них
+		panic(fmt.Sprintf("кодировщик zstd: %v", err))
+	}
+	return enc
+}
+
+// Compress - middleware, сжимающий ответы gzip или zstd по Accept-Encoding
+// Сжимаются только текстовые типы не меньше MinSize байт; ответы на Range и уже сжатые идут как есть.
+// У сжатого ответа свой ETag с суффиксом кодировки, If-None-Match с ним сравнивается без суффикса
+func Compress(cfg config.CompressionConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: cfg.MinSize}
+			defer cw.Close()
+			values := r.Header.Values("If-None-Match")
+			if len(values) == 0 {
+				next.ServeHTTP(cw, r)
+				return
+			}
+
+			// Заголовки меняем на копии запроса, а найденный ServeMux шаблон возвращаем в исходный:
+			// по r.Pattern метрики и трейсинг снаружи подписывают маршрут
+			inner := r.WithContext(r.Context())
+			inner.Header = r.Header.Clone()
+			inner.Header.Del("If-None-Match")
+			for _, v := range values {
+				stripped := stripETagSuffix(v, encoding)
+				cw.suffixed = cw.suffixed || stripped != v
+				inner.Header.Add("If-None-Match", stripped)
+			}
+			next.ServeHTTP(cw, inner)
+			r.Pattern = inner.Pattern
+		})
+	}
+}
+
+// negotiateEncoding выбирает сжатие с наибольшим весом, "*" относится к не названным явно; "" - без сжатия
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	list := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, enc := range encodings {
+		q, found := 0.0, false
+		for _, a := range list {
+			if a.value == enc {
+				q, found = a.q, true
+				break
+			}
+		}
+		if !found {
+			for _, a := range list {
+				if a.value == "*" {
+					q = a.q
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// stripETagSuffix убирает суффикс кодировки из тегов If-None-Match: "abc-gzip" -> "abc"
+func stripETagSuffix(header, encoding string) string {
+	return strings.ReplaceAll(header, "-"+encoding+`"`, `"`)
+}
+
+// compressWriter решает, сжимать ли ответ, когда известны код, тип и размер
+// Пока размер неизвестен и меньше порога, тело копится в буфере
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	suffixed bool // клиент прислал тег сжатого ответа, в 304 вернуть его же
+
+	code    int
+	started bool // заголовок отправлен
+	pending bool // тело копится до порога
+	buf     []byte
+	enc     io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if code < 200 {
+		c.ResponseWriter.WriteHeader(code) // 1xx не окончательный ответ, решение ещё впереди
+		return
+	}
+	if c.started || c.code != 0 {
+		return
+	}
+	c.code = code
+	h := c.Header()
+	switch {
+	case code == http.StatusNotModified:
+		if c.suffixed {
+			c.tagETag()
+		}
+		c.send()
+	case code == http.StatusNoContent || h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")):
+		c.send()
+	default:
+		size, err := strconv.Atoi(h.Get("Content-Length"))
+		switch {
+		case err != nil:
+			c.pending = true
+		case size < c.minSize:
+			c.send()
+		default:
+			c.compress()
+		}
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if c.code == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case c.enc != nil:
+		return c.enc.Write(b)
+	case c.pending:
+		c.buf = append(c.buf, b...)
+		if len(c.buf) >= c.minSize {
+			c.compress()
+			if _, err := c.enc.Write(c.buf); err != nil {
+				return 0, err
+			}
+			c.buf = nil
+		}
+		return len(b), nil
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush отправляет накопленное: потоковый ответ сжимается, не дожидаясь порога
+func (c *compressWriter) Flush() {
+	if c.code == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.pending {
+		c.compress()
+		c.enc.Write(c.buf)
+		c.buf = nil
+	}
+	if f, ok := c.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close дописывает сжатый поток, а короткий ответ отдаёт как есть
+func (c *compressWriter) Close() error {
+	switch {
+	case c.pending:
+		c.pending = false
+		c.send()
+		_, err := c.ResponseWriter.Write(c.buf)
+		return err
+	case c.enc != nil:
+		err := c.enc.Close()
+		switch enc := c.enc.(type) {
+		case *gzip.Writer:
+			gzipPool.Put(enc)
+		case *zstd.Encoder:
+			zstdPool.Put(enc)
+		}
+		c.enc = nil
+		return err
+	}
+	return nil
+}
+
+// Unwrap нужен http.ResponseController
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// send отправляет заголовок без сжатия
+func (c *compressWriter) send() {
+	c.started = true
+	c.ResponseWriter.WriteHeader(c.code)
+}
+
+// compress отправляет заголовок сжатого ответа и готовит кодировщик
+func (c *compressWriter) compress() {
+	c.pending = false
+	h := c.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", c.encoding)
+	c.tagETag()
+	switch c.encoding {
+	case "gzip":
+		enc := gzipPool.Get().(*gzip.Writer)
+		enc.Reset(c.ResponseWriter)
+		c.enc = enc
+	case "zstd":
+		enc := zstdPool.Get().(*zstd.Encoder)
+		enc.Reset(c.ResponseWriter)
+		c.enc = enc
+	}
+	c.send()
+}
+
+// tagETag - у сжатого представления другие байты, строгий тег должен отличаться
+func (c *compressWriter) tagETag() {
+	h := c.Header()
+	if tag := h.Get("ETag"); strings.HasPrefix(tag, `"`) && strings.HasSuffix(tag, `"`) {
+		h.Set("ETag", strings.TrimSuffix(tag, `"`)+"-"+c.encoding+`"`)
+	}
+}
+
+// compressible - текстовые типы, которые хорошо сжимаются; картинки и архивы уже сжаты
+func compressible(contentType string) bool {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(media, "text/"), strings.HasSuffix(media, "+json"), strings.HasSuffix(media, "+xml"):
+		return true
+	}
+	switch media {
+	case MediaJSON, MediaNDJSON, "application/javascript", "application/xml":
+		return true
+	}
+	return false
+}
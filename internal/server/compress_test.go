@@ -0,0 +1,144 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNegotiateEncoding проверяет выбор сжатия: веса, предпочтение zstd при равных, "*" и отказ q=0
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"identity":              "",
+		"gzip":                  "gzip",
+		"gzip, deflate, br":     "gzip",
+		"gzip, zstd":            "zstd",
+		"zstd;q=0.5, gzip":      "gzip",
+		"*":                     "zstd",
+		"*, zstd;q=0":           "gzip",
+		"gzip;q=0, deflate":     "",
+		"GZIP;Q=1.0, br;q=0.9 ": "gzip",
+	}
+	for header, want := range tests {
+		assert.Equal(t, want, negotiateEncoding(header), header)
+	}
+}
+
+// TestCompress
+// Проверяет сжатие ответа заказа:
+// 1) gzip и zstd распаковываются в то же тело, что и без сжатия, ETag получает суффикс кодировки
+// 2) If-None-Match с тегом сжатого ответа - 304 с тем же тегом, в метриках - под шаблоном маршрута
+// 3) Ответ меньше порога и клиент без Accept-Encoding получают тело как есть, Vary всегда с Accept-Encoding
+func TestCompress(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	items := make([]models.Item, 20)
+	for i := range items {
+		items[i] = models.Item{ChrtID: i + 1, Name: "Mascaras", Brand: "Vivienne Sabo"}
+	}
+	cache.EXPECT().GetCache("big").Return(models.Order{OrderUID: "big", Items: items}, true)
+	cache.EXPECT().GetCache("small").Return(models.Order{OrderUID: "small"}, true)
+	srv := NewServer(config.HttpServer{Port: 8080, Compression: config.CompressionConfig{Enabled: true, MinSize: 1024}},
+		cache, repomocks.NewOrderRepository(t))
+
+	send := func(path, encoding, inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	plain := send("/order/big", "", "")
+	require.Equal(t, http.StatusOK, plain.Code)
+	assert.Empty(t, plain.Header().Get("Content-Encoding"))
+	assert.Contains(t, plain.Header().Values("Vary"), "Accept-Encoding")
+	tag := plain.Header().Get("ETag")
+
+	w := send("/order/big", "gzip", "")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	assert.Equal(t, strings.TrimSuffix(tag, `"`)+`-gzip"`, w.Header().Get("ETag"))
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, plain.Body.String(), string(body))
+
+	w = send("/order/big", "gzip, zstd", "")
+	assert.Equal(t, "zstd", w.Header().Get("Content-Encoding"))
+	dec, err := zstd.NewReader(w.Body)
+	require.NoError(t, err)
+	defer dec.Close()
+	body, err = io.ReadAll(dec)
+	require.NoError(t, err)
+	assert.Equal(t, plain.Body.String(), string(body))
+
+	zstdTag := w.Header().Get("ETag")
+	notModified := httpRequests.Value("/order/{id}", http.MethodGet, "304")
+	unmatched := httpRequests.Value("unmatched", http.MethodGet, "304")
+	w = send("/order/big", "zstd", zstdTag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, zstdTag, w.Header().Get("ETag"))
+	assert.Equal(t, notModified+1, httpRequests.Value("/order/{id}", http.MethodGet, "304"))
+	assert.Equal(t, unmatched, httpRequests.Value("unmatched", http.MethodGet, "304"))
+	assert.Equal(t, http.StatusNotModified, send("/order/big", "", tag).Code)
+
+	w = send("/order/small", "gzip", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), `"order_uid":"small"`)
+}
+
+// TestCompress_Streaming
+// Проверяет ответ без Content-Length: короткий уходит как есть после обработчика, длинный сжимается,
+// как только накопился порог; картинки не сжимаются
+func TestCompress_Streaming(t *testing.T) {
+	chunk := strings.Repeat("a", 600)
+	h := Compress(config.CompressionConfig{Enabled: true, MinSize: 1000})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		w.Write([]byte(chunk))
+		if r.URL.Query().Has("long") {
+			w.Write([]byte(chunk))
+		}
+	}))
+	send := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/?type=text/plain")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, chunk, w.Body.String())
+
+	w = send("/?type=text/plain&long")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, chunk+chunk, string(body))
+
+	w = send("/?type=image/png&long")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Len(t, w.Body.String(), 1200)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fathersson/wb-demo-service/internal/logger"
+)
+
+// WithCacheControl задаёт Cache-Control успешных ответов по шаблону маршрута без метода (/order/{id})
+// Ошибки заголовок не получают: 404 или 503 не должны оседать в кэшах клиентов
+func WithCacheControl(routes map[string]string) Option {
+	return func(rt *router) {
+		rt.cacheControl = routes
+	}
+}
+
+// writeRendered отдаёт v в типе media с ETag, а если версия клиента совпадает - 304 без тела
+// ETag считается по готовому телу ответа: у ролей с разным маскированием и у разных типов теги разные
+func writeRendered(w http.ResponseWriter, r *http.Request, media string, v any) {
+	body, contentType, err := render(r, media, v)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка формирования ответа", "media", media, logger.Err(err))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "не удалось сформировать ответ")
+		return
+	}
+	writeConditional(w, r, contentType, body)
+}
+
+// writeConditional отдаёт body со строгим ETag, а на совпавший If-None-Match - 304
+// Last-Modified не отдаётся: у заказа нет времени последнего изменения (date_created не меняется
+// при смене статуса), и If-Modified-Since давал бы 304 на изменённый заказ
+func writeConditional(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	tag := etag(body)
+	h := w.Header()
+	h.Set("ETag", tag)
+	if matchETag(r.Header.Values("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// etag - строгий тег по SHA-256 тела, 128 бит достаточно, чтобы версии не совпали
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchETag - слабое сравнение для If-None-Match: W/ у тега клиента не мешает совпадению
+func matchETag(values []string, tag string) bool {
+	for _, v := range values {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
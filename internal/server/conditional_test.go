@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConditionalGet
+// Проверяет условные запросы заказа:
+// 1) Ответ несёт строгий ETag и Cache-Control маршрута, но не Last-Modified
+// 2) If-None-Match с тем же тегом (в том числе W/ и в списке) - 304 без тела
+// 3) Устаревший тег - 200
+// 4) If-Modified-Since не учитывается: date_created не меняется вместе со статусом заказа
+func TestConditionalGet(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	created := time.Date(2021, 11, 26, 6, 22, 19, 500, time.UTC)
+	cache.EXPECT().GetCache("id1").Return(models.Order{OrderUID: "id1", DateCreated: created}, true)
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repomocks.NewOrderRepository(t),
+		WithCacheControl(map[string]string{"/order/{id}": "private, no-cache"}))
+
+	send := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/order/id1", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	tag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, tag)
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, tag, send("", "").Header().Get("ETag"), "тег стабилен для одного и того же ответа")
+
+	for _, inm := range []string{tag, "W/" + tag, `"other", ` + tag, "*"} {
+		w = send("If-None-Match", inm)
+		assert.Equal(t, http.StatusNotModified, w.Code, inm)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, tag, w.Header().Get("ETag"))
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	}
+
+	w = send("If-None-Match", `"stale"`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"order_uid":"id1"`)
+
+	w = send("If-Modified-Since", created.Add(time.Hour).Format(http.TimeFormat))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"order_uid":"id1"`)
+}
+
+// TestConditionalGet_Errors
+// Проверяет, что Cache-Control маршрута не попадает в ответы с ошибкой, а схема тоже получает ETag
+func TestConditionalGet_Errors(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	cache.EXPECT().GetCache("missing").Return(models.Order{}, false)
+	repo := repomocks.NewOrderRepository(t)
+	repo.EXPECT().GetOrderById(mock.Anything, "missing").Return(models.Order{}, repository.ErrNotFound)
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo, WithCacheControl(map[string]string{
+		"/order/{id}":        "private, no-cache",
+		"/schema/order.json": "public, max-age=300",
+	}))
+
+	w := get(srv, "/order/missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	w = get(srv, "/schema/order.json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+
+	req := httptest.NewRequest(http.MethodGet, "/schema/order.json", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
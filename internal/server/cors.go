@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+)
+
+// cors - разобранные настройки CORS
+type cors struct {
+	anyOrigin   bool
+	origins     []string
+	methods     []string
+	headers     []string // в нижнем регистре, заголовки сравниваются без учёта регистра
+	credentials bool
+
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+// CORS - middleware, отвечающий на preflight (OPTIONS) и добавляющий заголовки CORS к ответам
+// Запросы без Origin и с неразрешённого источника проходят без заголовков CORS - их отсечёт браузер.
+// Допустимые методы проверяет ServeMux по шаблонам маршрутов
+func CORS(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	c := &cors{
+		anyOrigin:     slices.Contains(cfg.AllowedOrigins, "*"),
+		origins:       cfg.AllowedOrigins,
+		methods:       cfg.AllowedMethods,
+		credentials:   cfg.AllowCredentials,
+		allowMethods:  strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:  strings.Join(cfg.AllowedHeaders, ", "),
+		exposeHeaders: strings.Join(cfg.ExposedHeaders, ", "),
+		maxAge:        strconv.Itoa(int(cfg.MaxAge.Seconds())),
+	}
+	for _, h := range cfg.AllowedHeaders {
+		c.headers = append(c.headers, strings.ToLower(h))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r, origin)
+				return
+			}
+
+			// Ответ зависит от Origin, кэши должны это учитывать
+			if !c.anyOrigin {
+				w.Header().Add("Vary", "Origin")
+			}
+			if origin != "" && c.allowOrigin(origin) {
+				c.setOrigin(w, origin)
+				if c.exposeHeaders != "" {
+					w.Header().Set("Access-Control-Expose-Headers", c.exposeHeaders)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// preflight отвечает 204 на OPTIONS от браузера
+// Если источник, метод или заголовки не разрешены, заголовки CORS не ставятся и браузер не отправит запрос
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	if c.allowOrigin(origin) && c.allowMethod(r.Header.Get("Access-Control-Request-Method")) &&
+		c.allowRequestHeaders(r.Header.Values("Access-Control-Request-Headers")) {
+		c.setOrigin(w, origin)
+		h.Set("Access-Control-Allow-Methods", c.allowMethods)
+		if c.allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+		}
+		if c.maxAge != "0" {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setOrigin - Access-Control-Allow-Origin и, если разрешено, Access-Control-Allow-Credentials
+// С credentials браузер не принимает "*", поэтому источник возвращается как есть
+func (c *cors) setOrigin(w http.ResponseWriter, origin string) {
+	if c.anyOrigin && !c.credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	return c.anyOrigin || slices.Contains(c.origins, origin)
+}
+
+// allowMethod - разрешён ли метод; HEAD разрешён вместе с GET
+func (c *cors) allowMethod(method string) bool {
+	return slices.Contains(c.methods, method) ||
+		(method == http.MethodHead && slices.Contains(c.methods, http.MethodGet))
+}
+
+// allowRequestHeaders - все ли заголовки из Access-Control-Request-Headers разрешены
+func (c *cors) allowRequestHeaders(values []string) bool {
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && !slices.Contains(c.headers, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
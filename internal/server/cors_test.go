@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// corsServer - сервер с CORS для двух источников и cookie
+func corsServer(t *testing.T) *http.Server {
+	cfg := config.HttpServer{Port: 8080, CORS: config.CORSConfig{
+		AllowedOrigins:   []string{"https://shop.example", "http://localhost:3000"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Content-Type", "X-Request-ID"},
+		ExposedHeaders:   []string{RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}}
+	return NewServer(cfg, cachemocks.NewCacheInterface(t), repomocks.NewOrderRepository(t))
+}
+
+func preflight(srv *http.Server, origin, method, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/order/id1", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestCORS_Preflight
+// Проверяет ответ на OPTIONS от браузера:
+// 1) Разрешённые источник, метод и заголовки - 204 с Allow-* и Max-Age, источник возвращается как есть (есть credentials)
+// 2) Чужой источник, неразрешённый метод или заголовок - 204 без заголовков CORS
+func TestCORS_Preflight(t *testing.T) {
+	srv := corsServer(t)
+
+	w := preflight(srv, "https://shop.example", http.MethodPost, "content-type, x-request-id")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://shop.example", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, X-Request-ID", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	for _, w := range []*httptest.ResponseRecorder{
+		preflight(srv, "https://evil.example", http.MethodGet, ""),
+		preflight(srv, "https://shop.example", http.MethodDelete, ""),
+		preflight(srv, "https://shop.example", http.MethodGet, "X-Secret"),
+	} {
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	}
+}
+
+// TestCORS_SimpleRequest
+// Проверяет обычный запрос из браузера: разрешённому источнику - Allow-Origin и Expose-Headers, чужому - ничего
+// Метод по-прежнему проверяет маршрут: POST к /schema/order.json - 405
+func TestCORS_SimpleRequest(t *testing.T) {
+	srv := corsServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/schema/order.json", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, RequestIDHeader, w.Header().Get("Access-Control-Expose-Headers"))
+
+	req = httptest.NewRequest(http.MethodGet, "/schema/order.json", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	req = httptest.NewRequest(http.MethodPost, "/schema/order.json", nil)
+	req.Header.Set("Origin", "https://shop.example")
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "https://shop.example", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+// TestCORS_AnyOrigin проверяет настройки по умолчанию: любой источник получает "*"
+func TestCORS_AnyOrigin(t *testing.T) {
+	cfg := config.HttpServer{Port: 8080, CORS: config.CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{http.MethodGet}}}
+	srv := NewServer(cfg, cachemocks.NewCacheInterface(t), repomocks.NewOrderRepository(t))
+
+	req := httptest.NewRequest(http.MethodGet, "/schema/order.json", nil)
+	req.Header.Set("Origin", "https://any.example")
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Empty(t, w.Header().Values("Vary"))
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Probe - проверка зависимости для /readyz, ошибка - зависимость недоступна
+// Проверка должна уважать ctx: на неё отводится таймаут Readiness
+type Probe func(ctx context.Context) error
+
+// ProbeResult - итог одной проверки в ответе /readyz
+type ProbeResult struct {
+	Status   string `json:"status"` // ok или fail
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Readiness - готов ли сервис принимать трафик: все зависимости отвечают и он не останавливается
+type Readiness struct {
+	timeout  time.Duration
+	stopping atomic.Bool
+
+	mu     sync.Mutex
+	probes map[string]Probe
+}
+
+// NewReadiness создаёт проверку готовности, timeout - на каждую зависимость
+func NewReadiness(timeout time.Duration) *Readiness {
+	return &Readiness{timeout: timeout, probes: make(map[string]Probe)}
+}
+
+// Add добавляет проверку зависимости
+func (r *Readiness) Add(name string, probe Probe) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probes[name] = probe
+}
+
+// Shutdown переводит /readyz в 503 до остановки сервера, чтобы балансировщик успел убрать инстанс
+func (r *Readiness) Shutdown() {
+	r.stopping.Store(true)
+}
+
+// Check параллельно запускает все проверки, каждую с таймаутом
+// Проверка, которая не уложилась в таймаут, считается упавшей, даже если не вернулась
+func (r *Readiness) Check(ctx context.Context) (bool, map[string]ProbeResult) {
+	r.mu.Lock()
+	probes := make(map[string]Probe, len(r.probes))
+	for name, probe := range r.probes {
+		probes[name] = probe
+	}
+	r.mu.Unlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ready := true
+	results := make(map[string]ProbeResult, len(probes))
+	for name, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := r.run(ctx, probe)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = res
+			if res.Status != "ok" {
+				ready = false
+			}
+		}()
+	}
+	wg.Wait()
+	return ready, results
+}
+
+// run - одна проверка с таймаутом
+func (r *Readiness) run(ctx context.Context, probe Probe) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- probe(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := ProbeResult{Status: "ok", Duration: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		res.Status, res.Error = "fail", err.Error()
+	}
+	return res
+}
+
+// WithProbes добавляет GET /healthz (процесс жив) и GET /readyz (зависимости доступны, 503 если нет)
+// Оркестратор проверяет их без ключей, поэтому оба маршрута открыты
+func WithProbes(r *Readiness) Option {
+	return func(rt *router) {
+		rt.handleFunc("GET /healthz", public, func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+		})
+
+		rt.handleFunc("GET /readyz", public, func(w http.ResponseWriter, req *http.Request) {
+			if r.stopping.Load() {
+				w.Header().Set("Cache-Control", "no-store")
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting_down"})
+				return
+			}
+
+			ready, checks := r.Check(req.Context())
+			status, code := "ready", http.StatusOK
+			if !ready {
+				status, code = "not_ready", http.StatusServiceUnavailable
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			writeJSON(w, code, map[string]any{
+				"status": status,
+				"checks": checks,
+			})
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readyz - ответ /readyz: код и разобранное тело
+func readyz(t *testing.T, srv *http.Server) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w.Code, body
+}
+
+// TestProbes
+// Проверяет /healthz и /readyz:
+// 1) /healthz отвечает 200 всегда
+// 2) Все зависимости отвечают - 200 и ready, по каждой - статус и длительность
+// 3) Упавшая и зависшая (дольше таймаута) зависимости - 503 и not_ready с ошибкой по каждой
+// 4) После Shutdown - 503 shutting_down без проверок
+func TestProbes(t *testing.T) {
+	readiness := NewReadiness(50 * time.Millisecond)
+	srv := NewServer(config.HttpServer{Port: 8080}, cachemocks.NewCacheInterface(t), repomocks.NewOrderRepository(t), WithProbes(readiness))
+
+	var dbErr error
+	readiness.Add("postgres", func(context.Context) error { return dbErr })
+	readiness.Add("cache", func(context.Context) error { return nil })
+
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"alive"}`, w.Body.String())
+
+	code, body := readyz(t, srv)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body["status"])
+	checks := body["checks"].(map[string]any)
+	assert.Equal(t, "ok", checks["postgres"].(map[string]any)["status"])
+	assert.Contains(t, checks["cache"], "duration")
+
+	dbErr = errors.New("connection refused")
+	readiness.Add("kafka", func(ctx context.Context) error {
+		time.Sleep(time.Second) // не смотрит на ctx
+		return nil
+	})
+	start := time.Now()
+	code, body = readyz(t, srv)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", body["status"])
+	checks = body["checks"].(map[string]any)
+	assert.Equal(t, map[string]any{"status": "fail", "error": "connection refused", "duration": checks["postgres"].(map[string]any)["duration"]}, checks["postgres"])
+	assert.Equal(t, context.DeadlineExceeded.Error(), checks["kafka"].(map[string]any)["error"])
+	assert.Equal(t, "ok", checks["cache"].(map[string]any)["status"])
+
+	readiness.Shutdown()
+	code, body = readyz(t, srv)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, map[string]any{"status": "shutting_down"}, body)
+}
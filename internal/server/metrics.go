@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/metrics"
+)
+
+// Метрики HTTP, в реестр их добавляет RegisterMetrics
+var (
+	httpRequests = metrics.NewCounter("http_requests_total",
+		"HTTP запросов по маршруту, методу и коду ответа", "route", "method", "code")
+	httpDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"Длительность обработки HTTP запросов по маршруту", nil, "route")
+	httpRateLimited = metrics.NewCounter("http_rate_limited_total",
+		"HTTP запросов, отклонённых лимитом частоты, по маршруту", "route")
+)
+
+// RegisterMetrics добавляет метрики HTTP сервера в реестр
+func RegisterMetrics(reg *metrics.Registry) {
+	reg.Register(httpRequests, httpDuration, httpRateLimited)
+}
+
+// statusRecorder запоминает код ответа
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Instrument - middleware, считающий запросы и их длительность
+// Маршрут - шаблон ServeMux (например, /order/), а не путь: так у метрик не растёт число меток
+func Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		route := routeOf(r)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(rec.code))
+		httpDuration.Observe(time.Since(start).Seconds(), route)
+	})
+}
+
+// routeOf - шаблон маршрута без метода ("GET /order/" - "/order/"), "unmatched" если маршрут не найден
+// ServeMux записывает найденный шаблон в r.Pattern
+func routeOf(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
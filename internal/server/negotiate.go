@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fathersson/wb-demo-service/internal/export"
+)
+
+// Типы ответов API, между которыми выбирает Accept
+const (
+	MediaJSON   = "application/json"
+	MediaNDJSON = "application/x-ndjson" // по объекту JSON на строку, только у списков
+	MediaCSV    = "text/csv"             // плоская выгрузка, см. internal/export
+)
+
+// CodeNotAcceptable - ни один тип из Accept маршрут отдать не может
+const CodeNotAcceptable = "not_acceptable"
+
+// accepted - значение заголовка Accept или Accept-Encoding с весом q
+type accepted struct {
+	value string
+	q     float64
+}
+
+// parseAccept разбирает "a;q=0.5, b" в список значений с весами, без q вес 1
+func parseAccept(header string) []accepted {
+	var list []accepted
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(part, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		a := accepted{value: value, q: 1}
+		for _, p := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(k, "q") {
+				if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+					a.q = q
+				}
+			}
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+// negotiate выбирает из offers тип с наибольшим весом в Accept, при равных весах - первый из offers
+// Без Accept отдаётся первый тип; "" - клиент не принимает ни один
+func negotiate(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+	list := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := mediaQ(list, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaQ - вес типа по самому точному совпадению: type/subtype, затем type/*, затем */*
+func mediaQ(list []accepted, media string) float64 {
+	group, _, _ := strings.Cut(media, "/")
+	q, specificity := 0.0, -1
+	for _, a := range list {
+		s := -1
+		switch a.value {
+		case media:
+			s = 2
+		case group + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = a.q, s
+		}
+	}
+	return q
+}
+
+// pretty - запрошен ли JSON с отступами (?pretty=1)
+func pretty(r *http.Request) bool {
+	v, err := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return err == nil && v
+}
+
+// render сериализует v в выбранный тип и возвращает тело с Content-Type
+func render(r *http.Request, media string, v any) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch media {
+	case MediaCSV:
+		if err := export.CSV(&buf, v); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), MediaCSV + "; charset=utf-8", nil
+	case MediaNDJSON:
+		// Список сначала кодируется как есть (с маскированием и omitempty), затем режется на элементы
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, "", err
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(b, &items); err != nil {
+			return nil, "", err
+		}
+		for _, item := range items {
+			buf.Write(item)
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), MediaNDJSON, nil
+	}
+	enc := json.NewEncoder(&buf)
+	if pretty(r) {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), MediaJSON, nil
+}
+
+// notAcceptable отвечает 406 со списком типов, которые маршрут умеет отдавать
+func notAcceptable(w http.ResponseWriter, r *http.Request, offers ...string) {
+	writeProblem(w, r, http.StatusNotAcceptable, CodeNotAcceptable, "маршрут отдаёт "+strings.Join(offers, ", "))
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNegotiate проверяет выбор типа по Accept: веса, маски type/* и */*, явный отказ q=0
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", MediaJSON},
+		{"*/*", MediaJSON},
+		{"text/csv", MediaCSV},
+		{"text/*", MediaCSV},
+		{"application/json;q=0.5, text/csv", MediaCSV},
+		{"application/x-ndjson, application/json;q=0.9", MediaNDJSON},
+		{"*/*;q=0.1, application/json;q=0", MediaNDJSON},
+		{"image/png", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		assert.Equal(t, tt.want, negotiate(req, itemsMedia...), tt.accept)
+	}
+}
+
+// TestOrderFormats
+// Проверяет представления заказа и его товаров:
+// 1) ?pretty=1 - JSON с отступами
+// 2) text/csv - строка на товар, колонки с путями полей
+// 3) NDJSON товаров - по объекту на строку, у заказа NDJSON нет - 406 not_acceptable
+func TestOrderFormats(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	order := models.Order{OrderUID: "id1", Items: []models.Item{{ChrtID: 1, Name: "a"}, {ChrtID: 2, Name: "b"}}}
+	cache.EXPECT().GetCache("id1").Return(order, true)
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repomocks.NewOrderRepository(t))
+
+	send := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/order/id1?pretty=1", "application/json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, MediaJSON, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "{\n  \"order_uid\": \"id1\",")
+	assert.Contains(t, w.Header().Values("Vary"), "Accept")
+
+	w = send("/order/id1", "text/csv")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "order_uid,track_number,delivery.name"), lines[0])
+	assert.Contains(t, lines[0], "items.chrt_id")
+
+	w = send("/order/id1/items", "application/x-ndjson")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, MediaNDJSON, w.Header().Get("Content-Type"))
+	assert.Equal(t, "{\"chrt_id\":1,\"price\":0,\"name\":\"a\",\"total_price\":0}\n{\"chrt_id\":2,\"price\":0,\"name\":\"b\",\"total_price\":0}\n", w.Body.String())
+
+	w = send("/order/id1", "application/x-ndjson")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Equal(t, CodeNotAcceptable, decodeProblem(t, w).Code)
+}
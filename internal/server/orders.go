@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/auth"
+	"github.com/fathersson/wb-demo-service/internal/cache"
+	"github.com/fathersson/wb-demo-service/internal/logger"
+	"github.com/fathersson/wb-demo-service/internal/masking"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
+	"github.com/fathersson/wb-demo-service/internal/schema"
+)
+
+// retryAfter - через сколько клиенту повторить запрос, если база недоступна
+const retryAfter = 5 * time.Second
+
+// statusClientClosed - клиент закрыл соединение до ответа (как 499 в nginx), в метриках не смешивается с 5xx
+const statusClientClosed = 499
+
+// orderHandlers - HTTP API заказов, зависимости передаются в NewServer
+type orderHandlers struct {
+	cache     cache.CacheInterface
+	db        repository.OrderRepository
+	dbTimeout time.Duration   // 0 - запрос к БД ограничен только контекстом запроса
+	policy    *masking.Policy // какие поля заказа видит роль клиента
+}
+
+// routes регистрирует маршруты API: методы и параметры задаются в шаблонах
+// Заказ содержит персональные данные и требует orders:read, схема - открытый контракт
+func (h *orderHandlers) routes(rt *router) {
+	rt.handleFunc("GET /order/{id}", auth.ScopeOrdersRead, h.getOrder)
+	rt.handleFunc("GET /order/{id}/items", auth.ScopeOrdersRead, h.getItems)
+	// /order/ без id и /order/a/b - не заказ, а не статика из web
+	rt.handleFunc("GET /order/", public, func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusNotFound, CodeNotFound, "ожидается /order/{id}")
+	})
+	rt.handleFunc("GET "+schema.OrderID, public, h.getSchema)
+}
+
+// Типы ответов заказа и списка его товаров, первый - по умолчанию
+var (
+	orderMedia = []string{MediaJSON, MediaCSV}
+	itemsMedia = []string{MediaJSON, MediaNDJSON, MediaCSV}
+)
+
+// getOrder отдаёт заказ в JSON или плоской выгрузкой CSV (строка на товар)
+func (h *orderHandlers) getOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	media := negotiate(r, orderMedia...)
+	if media == "" {
+		notAcceptable(w, r, orderMedia...)
+		return
+	}
+	order, ok := h.order(w, r)
+	if !ok {
+		return
+	}
+	h.writeOrder(w, r, media, order)
+}
+
+// getItems отдаёт товары заказа списком: JSON-массив, NDJSON или CSV
+func (h *orderHandlers) getItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	media := negotiate(r, itemsMedia...)
+	if media == "" {
+		notAcceptable(w, r, itemsMedia...)
+		return
+	}
+	order, ok := h.order(w, r)
+	if !ok {
+		return
+	}
+	writeRendered(w, r, media, h.mask(w, r, order.Items))
+}
+
+// order ищет заказ из пути в кэше, а при промахе - в БД с сохранением в кэш
+// Если заказ не получен, ответ с ошибкой уже отправлен
+func (h *orderHandlers) order(w http.ResponseWriter, r *http.Request) (models.Order, bool) {
+	id := r.PathValue("id")
+	l := slog.With(logger.KeyOrderUID, id)
+	ctx := r.Context()
+
+	// Получаем заказ из кэша
+	if order, ok := h.cache.GetCache(id); ok {
+		l.InfoContext(ctx, "Заказ в кеше найден")
+		return order, true
+	}
+	l.InfoContext(ctx, "Заказ в кеше не нашли")
+
+	// Получаем заказ из БД если в кеше нет
+	dbCtx, cancel := h.withTimeout(ctx)
+	defer cancel()
+	order, err := h.db.GetOrderById(dbCtx, id)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		l.InfoContext(ctx, "Заказ в БД не нашли")
+		writeProblem(w, r, http.StatusNotFound, CodeOrderNotFound, "заказ "+id+" не найден")
+		return models.Order{}, false
+	case errors.Is(err, context.Canceled):
+		// Ответ никто не прочитает, отмечаем запрос только в логах и метриках
+		l.InfoContext(ctx, "Клиент отменил запрос до ответа БД")
+		w.WriteHeader(statusClientClosed)
+		return models.Order{}, false
+	case errors.Is(err, repository.ErrUnavailable):
+		l.WarnContext(ctx, "БД недоступна, заказ не получен", logger.Err(err))
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeUnavailable, "база данных временно недоступна")
+		return models.Order{}, false
+	case err != nil:
+		l.ErrorContext(ctx, "Ошибка получения заказа из БД", logger.Err(err))
+		writeProblem(w, r, http.StatusInternalServerError, CodeInternal, "не удалось получить заказ")
+		return models.Order{}, false
+	}
+	l.InfoContext(ctx, "Заказ в БД найден")
+
+	// Сохраняем заказ в кэш
+	h.cache.SetCache(id, order)
+	return order, true
+}
+
+// writeOrder отдаёт заказ с полями, которые разрешены ролям клиента
+// В кэше заказ хранится целиком, маскируется только ответ; CSV строится из уже замаскированного заказа
+func (h *orderHandlers) writeOrder(w http.ResponseWriter, r *http.Request, media string, order models.Order) {
+	// Клиент, опрашивающий заказ, получает 304, пока ответ для его роли не изменился
+	writeRendered(w, r, media, h.mask(w, r, order))
+}
+
+// mask - v с полями, которые разрешены ролям клиента; заказ и его товары маскируются одной политикой
+func (h *orderHandlers) mask(w http.ResponseWriter, r *http.Request, v any) any {
+	if h.policy != nil {
+		// Ответ зависит от клиента, общие кэши не должны отдавать его другому
+		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", auth.APIKeyHeader)
+	}
+	return h.policy.Apply(v, auth.Roles(r.Context()))
+}
+
+// withTimeout ограничивает запрос к БД таймаутом из конфига
+func (h *orderHandlers) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if h.dbTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, h.dbTimeout)
+}
+
+// getSchema отдаёт JSON Schema заказа - контракт для продюсеров
+func (h *orderHandlers) getSchema(w http.ResponseWriter, r *http.Request) {
+	writeConditional(w, r, "application/schema+json", schema.OrderJSON())
+}
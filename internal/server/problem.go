@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fathersson/wb-demo-service/internal/logger"
+)
+
+// ProblemContentType - тип ответа с ошибкой по RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// Коды ошибок API: стабильны, клиенты могут на них опираться, в отличие от текста detail
+const (
+	CodeNotFound         = "not_found"           // маршрут не найден
+	CodeMethodNotAllowed = "method_not_allowed"  // маршрут есть, но не с этим методом
+	CodeOrderNotFound    = "order_not_found"     // заказа с таким order_uid нет
+	CodeUnavailable      = "service_unavailable" // база недоступна, запрос стоит повторить через Retry-After
+	CodeInternal         = "internal_error"      // непредвиденная ошибка сервера
+)
+
+// Problem - описание ошибки по RFC 7807 с расширениями code и request_id
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// problemType - URI типа ошибки, по нему же строится ссылка на документацию
+func problemType(code string) string {
+	return "/problems/" + code
+}
+
+// writeProblem отдаёт ошибку в формате application/problem+json
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	p := Problem{
+		Type:      problemType(code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logger.RequestID(r.Context()),
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Cache-Control") // Cache-Control маршрута относится только к успешным ответам
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeJSON отдаёт v в JSON с кодом code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Problems - middleware, заменяющий текстовые 404 и 405 от ServeMux на problem+json
+// Ответы найденных маршрутов не трогаются
+func Problems(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Маршрут не найден: ServeMux сам решает между 404, 405 и редиректом на канонический путь
+		cw := &captureWriter{header: make(http.Header)}
+		mux.ServeHTTP(cw, r)
+		switch cw.code {
+		case http.StatusNotFound:
+			writeProblem(w, r, http.StatusNotFound, CodeNotFound, "маршрут не найден")
+		case http.StatusMethodNotAllowed:
+			w.Header().Set("Allow", cw.header.Get("Allow"))
+			writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "метод "+r.Method+" не поддерживается маршрутом")
+		default:
+			for k, v := range cw.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(cw.code)
+			w.Write(cw.body)
+		}
+	})
+}
+
+// captureWriter запоминает ответ ServeMux, чтобы заменить его на problem+json
+type captureWriter struct {
+	header http.Header
+	code   int
+	body   []byte
+}
+
+func (c *captureWriter) Header() http.Header { return c.header }
+
+func (c *captureWriter) WriteHeader(code int) {
+	if c.code == 0 {
+		c.code = code
+	}
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.code == 0 {
+		c.code = http.StatusOK
+	}
+	c.body = append(c.body, b...)
+	return len(b), nil
+}
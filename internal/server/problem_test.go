@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeProblem разбирает ответ problem+json
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	require.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	return p
+}
+
+// TestProblems
+// Проверяет единый формат ошибок маршрутизации:
+// 1) /order/a/b и /order/ - 404 not_found, а не заказ "a/b" и не статика
+// 2) Неподдерживаемый метод - 405 method_not_allowed с заголовком Allow
+// 3) В ответе есть type, title, status, instance и request_id из X-Request-ID
+func TestProblems(t *testing.T) {
+	srv := NewServer(config.HttpServer{Port: 8080}, cachemocks.NewCacheInterface(t), repomocks.NewOrderRepository(t))
+
+	for _, path := range []string{"/order/a/b", "/order/"} {
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.Equal(t, CodeNotFound, decodeProblem(t, w).Code, path)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/order/id1", nil)
+	req.Header.Set(RequestIDHeader, "req-7")
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+	assert.Equal(t, Problem{
+		Type:      "/problems/method_not_allowed",
+		Title:     "Method Not Allowed",
+		Status:    http.StatusMethodNotAllowed,
+		Detail:    "метод DELETE не поддерживается маршрутом",
+		Instance:  "/order/id1",
+		Code:      CodeMethodNotAllowed,
+		RequestID: "req-7",
+	}, decodeProblem(t, w))
+}
+
+// TestProblems_PassThrough проверяет, что ответы найденных маршрутов и редиректы ServeMux не меняются
+func TestProblems_PassThrough(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "свой 404", http.StatusNotFound)
+	})
+	h := Problems(mux)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/x", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "свой 404\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+	assert.True(t, w.Code >= 300 && w.Code < 400, w.Code)
+	assert.Equal(t, "/items/", w.Header().Get("Location"))
+}
+
+// TestStaticFiles
+// Проверяет статику:
+// 1) Существующий файл отдаётся как есть
+// 2) Неизвестный путь под GET / - 404 not_found в problem+json, а не текст FileServer
+func TestStaticFiles(t *testing.T) {
+	h := staticFiles(http.FS(fstest.MapFS{"orders.html": {Data: []byte("<h1>Заказы</h1>")}}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders.html", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<h1>Заказы</h1>", w.Body.String())
+
+	srv := NewServer(config.HttpServer{Port: 8080}, cachemocks.NewCacheInterface(t), repomocks.NewOrderRepository(t))
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing.html", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	p := decodeProblem(t, w)
+	assert.Equal(t, CodeNotFound, p.Code)
+	assert.Equal(t, "/missing.html", p.Instance)
+}
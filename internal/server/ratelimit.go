@@ -0,0 +1,59 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/auth"
+	"github.com/fathersson/wb-demo-service/internal/ratelimit"
+)
+
+// CodeRateLimited - клиент превысил лимит запросов, повторить через Retry-After
+const CodeRateLimited = "rate_limited"
+
+// WithRateLimit включает ограничение частоты запросов по клиенту
+func WithRateLimit(l *ratelimit.Limits) Option {
+	return func(rt *router) {
+		rt.limits = l
+	}
+}
+
+// allow списывает запрос с лимита клиента и ставит заголовки RateLimit-*, при превышении отвечает 429
+// Клиент - имя ключа или sub токена, если аутентификация прошла, иначе IP-адрес
+func (rt *router) allow(w http.ResponseWriter, r *http.Request, p auth.Principal, authErr error) bool {
+	if rt.limits == nil {
+		return true
+	}
+	key := "ip:" + rt.limits.ClientIP(r)
+	if authErr == nil && p.Subject != "" {
+		key = p.Method + ":" + p.Subject
+	}
+
+	route := routeOf(r)
+	res := rt.limits.Allow(route, key)
+	if res.Limit == 0 {
+		return true // у маршрута нет лимита
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.Reset))
+	if res.Allowed {
+		return true
+	}
+
+	httpRateLimited.Inc(route)
+	slog.InfoContext(r.Context(), "Превышен лимит запросов", "route", route, "client", key)
+	h.Set("Retry-After", seconds(max(res.RetryAfter, time.Second)))
+	writeProblem(w, r, http.StatusTooManyRequests, CodeRateLimited, "слишком много запросов, повторите через "+h.Get("Retry-After")+" с")
+	return false
+}
+
+// seconds - длительность в целых секундах с округлением вверх, как ждут Retry-After и RateLimit-Reset
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
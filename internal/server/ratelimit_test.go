@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/ratelimit"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRateLimit
+// Проверяет лимит /order/{id} по IP: первые два запроса проходят с заголовками RateLimit-*,
+// третий - 429 problem+json с Retry-After; запросы с другого адреса и пробы не ограничены
+func TestRateLimit(t *testing.T) {
+	limits, err := ratelimit.New(config.RateLimitConfig{Enabled: true, RPS: 0.5, Burst: 2, MaxClients: 10,
+		Routes: []string{"/healthz=0"}})
+	require.NoError(t, err)
+
+	cache := cachemocks.NewCacheInterface(t)
+	cache.EXPECT().GetCache("id1").Return(models.Order{OrderUID: "id1"}, true).Times(3)
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repomocks.NewOrderRepository(t),
+		WithRateLimit(limits), WithProbes(NewReadiness(0)))
+
+	call := func(path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call("/order/id1", "203.0.113.7:1000")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, call("/order/id1", "203.0.113.7:1001").Code)
+
+	w = call("/order/id1", "203.0.113.7:1002")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), CodeRateLimited)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, call("/order/id1", "198.51.100.1:1000").Code)
+
+	for range 5 {
+		w = call("/healthz", "203.0.113.7:1000")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fathersson/wb-demo-service/internal/auth"
+	"github.com/fathersson/wb-demo-service/internal/kafka"
+	"github.com/fathersson/wb-demo-service/internal/logger"
+)
+
+// Коды ошибок переигрывания
+const (
+	CodeInvalidParameter = "invalid_parameter" // параметр запроса не разобрался
+	CodeReplayRunning    = "replay_running"    // переигрывание уже идёт, параллельно второе не запускается
+	CodeReplayFailed     = "replay_failed"     // переигрывание прервано ошибкой Kafka
+)
+
+// Replayer переигрывает диапазон топика консьюмером сервиса, write=false - dry-run
+type Replayer func(ctx context.Context, opts kafka.ReplayOptions, write bool) (kafka.ReplayReport, error)
+
+// WithReplay добавляет POST /admin/replay - админский режим консьюмера, доступен со скоупом admin
+// Параметры те же, что у cmd/replay: topic (по умолчанию topic), partitions, from-offset, from-time,
+// to-offset и write=true. Ответ - отчёт переигрывания, клиент ждёт его до конца диапазона
+func WithReplay(topic string, replay Replayer) Option {
+	h := &replayHandler{topic: topic, replay: replay}
+	return func(rt *router) {
+		rt.handleFunc("POST /admin/replay", auth.ScopeAdmin, h.serve)
+	}
+}
+
+// replayHandler - хендлер POST /admin/replay
+type replayHandler struct {
+	topic   string
+	replay  Replayer
+	running sync.Mutex // одно переигрывание за раз, иначе два прохода пишут одни и те же заказы
+}
+
+// serve разбирает параметры, запускает переигрывание и отдаёт отчёт
+func (h *replayHandler) serve(w http.ResponseWriter, r *http.Request) {
+	opts, write, err := h.options(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	if !h.running.TryLock() {
+		writeProblem(w, r, http.StatusConflict, CodeReplayRunning, "переигрывание уже идёт, дождитесь его отчёта")
+		return
+	}
+	defer h.running.Unlock()
+
+	slog.InfoContext(r.Context(), "Переигрывание по запросу администратора", "topic", opts.Topic, "write", write)
+	report, err := h.replay(r.Context(), opts, write)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Переигрывание прервано", "report", report, logger.Err(err))
+		writeProblem(w, r, http.StatusBadGateway, CodeReplayFailed, err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, report)
+}
+
+// options переводит параметры запроса в ReplayOptions, по умолчанию - весь топик в dry-run
+func (h *replayHandler) options(r *http.Request) (kafka.ReplayOptions, bool, error) {
+	q := r.URL.Query()
+	opts := kafka.ReplayOptions{Topic: h.topic, FromOffset: -1, ToOffset: -1}
+	if v := q.Get("topic"); v != "" {
+		opts.Topic = v
+	}
+
+	var err error
+	if v := q.Get("partitions"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			p, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return opts, false, fmt.Errorf("partitions: %q не номер партиции", part)
+			}
+			opts.Partitions = append(opts.Partitions, p)
+		}
+	}
+	if v := q.Get("from-offset"); v != "" {
+		if opts.FromOffset, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return opts, false, fmt.Errorf("from-offset: %q не оффсет", v)
+		}
+	}
+	if v := q.Get("to-offset"); v != "" {
+		if opts.ToOffset, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return opts, false, fmt.Errorf("to-offset: %q не оффсет", v)
+		}
+	}
+	if v := q.Get("from-time"); v != "" {
+		if opts.FromTime, err = time.Parse(time.RFC3339, v); err != nil {
+			return opts, false, fmt.Errorf("from-time: %q не время RFC3339", v)
+		}
+	}
+
+	write := false
+	if v := q.Get("write"); v != "" {
+		if write, err = strconv.ParseBool(v); err != nil {
+			return opts, false, fmt.Errorf("write: %q не true/false", v)
+		}
+	}
+	return opts, write, nil
+}
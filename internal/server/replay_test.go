@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/kafka"
+	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReplayEndpoint
+// Проверяет POST /admin/replay:
+// 1) Без параметров - весь топик по умолчанию в dry-run, в ответе отчёт
+// 2) Параметры переходят в ReplayOptions, write=true включает запись
+// 3) Неразборчивый параметр - 400 invalid_parameter, переигрывание не запускается
+// 4) Пока идёт переигрывание, второй запрос получает 409 replay_running
+// 5) Ошибка переигрывания - 502 replay_failed
+func TestReplayEndpoint(t *testing.T) {
+	var (
+		gotOpts  kafka.ReplayOptions
+		gotWrite bool
+		calls    int
+		replay   Replayer
+	)
+	srv := NewServer(config.HttpServer{Port: 8080}, cachemocks.NewCacheInterface(t), repomocks.NewOrderRepository(t),
+		WithReplay("orders", func(ctx context.Context, opts kafka.ReplayOptions, write bool) (kafka.ReplayReport, error) {
+			calls++
+			gotOpts, gotWrite = opts, write
+			return replay(ctx, opts, write)
+		}))
+	post := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, nil))
+		return w
+	}
+
+	replay = func(context.Context, kafka.ReplayOptions, bool) (kafka.ReplayReport, error) {
+		return kafka.ReplayReport{Read: 3, Accepted: 2, Rejected: 1, Reasons: map[string]int{"decode": 1}}, nil
+	}
+	w := post("/admin/replay")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, kafka.ReplayOptions{Topic: "orders", FromOffset: -1, ToOffset: -1}, gotOpts)
+	assert.False(t, gotWrite)
+	var report kafka.ReplayReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 3, report.Read)
+	assert.Equal(t, 1, report.Reasons["decode"])
+
+	w = post("/admin/replay?topic=orders.v2&partitions=0,2&from-time=2025-01-01T00:00:00Z&to-offset=500&write=true")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, kafka.ReplayOptions{
+		Topic:      "orders.v2",
+		Partitions: []int{0, 2},
+		FromOffset: -1,
+		FromTime:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		ToOffset:   500,
+	}, gotOpts)
+	assert.True(t, gotWrite)
+
+	calls = 0
+	for _, target := range []string{"/admin/replay?partitions=0,x", "/admin/replay?from-time=вчера", "/admin/replay?write=да"} {
+		w = post(target)
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_parameter"`, target)
+	}
+	assert.Zero(t, calls)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	replay = func(context.Context, kafka.ReplayOptions, bool) (kafka.ReplayReport, error) {
+		close(started)
+		<-release
+		return kafka.ReplayReport{}, nil
+	}
+	done := make(chan int)
+	go func() { done <- post("/admin/replay").Code }()
+	<-started
+	w = post("/admin/replay")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"replay_running"`)
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+
+	replay = func(context.Context, kafka.ReplayOptions, bool) (kafka.ReplayReport, error) {
+		return kafka.ReplayReport{Read: 1}, errors.New("ошибка чтения партиции 0: broker unavailable")
+	}
+	w = post("/admin/replay")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"replay_failed"`)
+}
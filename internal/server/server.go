@@ -1,101 +1,102 @@
 package server
 
 import (
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
 
+	"github.com/fathersson/wb-demo-service/internal/auth"
 	"github.com/fathersson/wb-demo-service/internal/cache"
 	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/logger"
 	"github.com/fathersson/wb-demo-service/internal/repository"
 )
 
+// Option - дополнительный маршрут или настройка сервера
+type Option func(rt *router)
+
+// WithMetrics добавляет GET /metrics, доступен со скоупом admin
+func WithMetrics(h http.Handler) Option {
+	return func(rt *router) {
+		rt.handle("GET /metrics", auth.ScopeAdmin, h)
+	}
+}
+
 // NewServer — возвращает http.Server
-func NewServer(cfg config.HttpServer, cache cache.CacheInterface, db repository.OrderRepository) *http.Server {
+func NewServer(cfg config.HttpServer, cache cache.CacheInterface, db repository.OrderRepository, opts ...Option) *http.Server {
 	mux := http.NewServeMux()
+	rt := &router{mux: mux}
+	for _, opt := range opts {
+		opt(rt)
+	}
 
-	// Get order
-	mux.HandleFunc("/order/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		// Получаем ID заказа из URL
-		id := strings.TrimPrefix(r.URL.Path, "/order/")
-		if id == "" {
-			http.Error(w, "bad request", http.StatusBadRequest)
-			return
-		}
+	// API заказов; методы и скоупы задаются при регистрации, на остальные методы отвечает Problems
+	orders := &orderHandlers{cache: cache, db: db, dbTimeout: cfg.DBTimeout, policy: rt.policy}
+	orders.routes(rt)
 
-		// Получаем заказ из кэша
-		order, ok := cache.GetCache(id)
-		if ok {
-			// Указываем, что ответ будет в формате JSON
-			w.Header().Set("Content-Type", "application/json")
-			// Код ответа 200 OK
-			w.WriteHeader(http.StatusOK)
-			// Кодируем структуру в JSON и отправляем клиенту
-			json.NewEncoder(w).Encode(order)
-			log.Printf("Заказ %s в кеше найден", id)
-			log.Printf("Тело заказа: %+v", order)
-			return
-		}
-		log.Printf("Заказ %s в кеше не нашли", id)
-
-		// Получаем заказ из БД если в кеше нет
-		order, err := db.GetOrderById(r.Context(), id)
-		if err != nil {
-			log.Printf("Заказ %s в БД не нашли", id)
-
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusNotFound)
-			// http.Error(w, err.Error(), http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "not found",
-			})
-			return
-		}
-		log.Printf("Заказ %s в БД найден", id)
-		log.Printf("Тело заказа: %+v", order)
+	// Раздача статических файлов
+	rt.handle("GET /", public, staticFiles(http.Dir("./web")))
 
-		// Сохраняем заказ в кэш
-		cache.SetCache(id, order)
+	slog.Info("Сервер будет запущен", "port", cfg.Port)
 
-		// Указываем, что ответ будет в формате JSON
-		w.Header().Set("Content-Type", "application/json")
-		// Код ответа 200 OK
-		w.WriteHeader(http.StatusOK)
-		// Кодируем структуру в JSON и отправляем клиенту
-		json.NewEncoder(w).Encode(order)
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: RequestID(Trace(Instrument(CORS(cfg.CORS)(Compress(cfg.Compression)(Problems(mux)))))),
+	}
+}
 
+// staticFiles - http.FileServer, у которого отсутствующий файл отвечает problem+json, как и остальные маршруты
+func staticFiles(root http.FileSystem) http.Handler {
+	files := http.FileServer(root)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files.ServeHTTP(&notFoundWriter{ResponseWriter: w, r: r}, r)
 	})
+}
 
-	// Раздача статических файлов
-	mux.Handle("/", http.FileServer(http.Dir("./web")))
+// notFoundWriter заменяет текстовый 404 от FileServer на problem+json, остальные ответы пропускает
+type notFoundWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	notFound bool // 404 уже отдан, текст FileServer отбрасывается
+}
 
-	log.Println("Сервер будет запущен на", cfg.Port)
+func (n *notFoundWriter) WriteHeader(code int) {
+	if code != http.StatusNotFound {
+		n.ResponseWriter.WriteHeader(code)
+		return
+	}
+	n.notFound = true
+	writeProblem(n.ResponseWriter, n.r, http.StatusNotFound, CodeNotFound, "файл не найден")
+}
 
-	return &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: CORS(mux),
+func (n *notFoundWriter) Write(b []byte) (int, error) {
+	if n.notFound {
+		return len(b), nil
 	}
+	return n.ResponseWriter.Write(b)
 }
 
-// Midleware обработка запроса для браузера
-func CORS(mux http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Разрешаем все источники
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+// RequestIDHeader - заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
 
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
+// RequestID - middleware, который берёт идентификатор запроса из X-Request-ID (или создаёт новый),
+// возвращает его в ответе и кладёт в контекст - он попадает во все логи запроса
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
 		}
-
-		// Возвращаем управление серверу
-		mux.ServeHTTP(w, r)
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
 	})
 }
+
+// newRequestID - случайный идентификатор из 16 байт в hex
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
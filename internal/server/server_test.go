@@ -1,16 +1,30 @@
 package server
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	cachemocks "github.com/fathersson/wb-demo-service/internal/cache/cachemocks"
 	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/metrics"
 	"github.com/fathersson/wb-demo-service/internal/models"
+	"github.com/fathersson/wb-demo-service/internal/repository"
 	repomocks "github.com/fathersson/wb-demo-service/internal/repository/repomocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TestOrderHandler_CacheHit
@@ -64,14 +78,14 @@ func TestOrderHandler_FromDB(t *testing.T) {
 
 // TestOrderHandler_NotFound
 // Проверяет обработку случая, когда заказа нет ни в кэше, ни в БД
-// Repo.GetOrderById возвращает ошибку
-// Ожидаем 404 Not Found
+// Repo.GetOrderById возвращает ErrNotFound
+// Ожидаем 404 Not Found в формате problem+json с кодом order_not_found
 func TestOrderHandler_NotFound(t *testing.T) {
 	cache := cachemocks.NewCacheInterface(t)
 	repo := repomocks.NewOrderRepository(t)
 
 	cache.EXPECT().GetCache("missing").Return(models.Order{}, false)
-	repo.EXPECT().GetOrderById(mock.Anything, "missing").Return(models.Order{}, assert.AnError)
+	repo.EXPECT().GetOrderById(mock.Anything, "missing").Return(models.Order{}, fmt.Errorf("%w: %w", repository.ErrNotFound, sql.ErrNoRows))
 
 	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo)
 	req := httptest.NewRequest(http.MethodGet, "/order/missing", nil)
@@ -80,13 +94,76 @@ func TestOrderHandler_NotFound(t *testing.T) {
 	srv.Handler.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, CodeOrderNotFound, decodeProblem(t, w).Code)
 	cache.AssertExpectations(t)
 	repo.AssertExpectations(t)
 }
 
+// TestOrderHandler_DBErrors
+// Проверяет, что ошибки БД не выдаются за отсутствие заказа:
+// 1) БД недоступна - 503 service_unavailable с Retry-After
+// 2) Любая другая ошибка - 500 internal_error
+// 3) Запрос к БД ограничен таймаутом из конфига
+func TestOrderHandler_DBErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+		want string
+	}{
+		{"недоступна", fmt.Errorf("%w: %w", repository.ErrUnavailable, driver.ErrBadConn), http.StatusServiceUnavailable, CodeUnavailable},
+		{"внутренняя", fmt.Errorf("%w: sql: Scan error", repository.ErrInternal), http.StatusInternalServerError, CodeInternal},
+		{"без типа", assert.AnError, http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := cachemocks.NewCacheInterface(t)
+			repo := repomocks.NewOrderRepository(t)
+			cache.EXPECT().GetCache("id1").Return(models.Order{}, false)
+			repo.EXPECT().GetOrderById(mock.Anything, "id1").
+				RunAndReturn(func(ctx context.Context, _ string) (models.Order, error) {
+					deadline, ok := ctx.Deadline()
+					assert.True(t, ok)
+					assert.WithinDuration(t, time.Now().Add(2*time.Second), deadline, time.Second)
+					return models.Order{}, tt.err
+				})
+
+			srv := NewServer(config.HttpServer{Port: 8080, DBTimeout: 2 * time.Second}, cache, repo)
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/id1", nil))
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.want, decodeProblem(t, w).Code)
+			if tt.code == http.StatusServiceUnavailable {
+				assert.Equal(t, "5", w.Header().Get("Retry-After"))
+			} else {
+				assert.Empty(t, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+// TestOrderHandler_ClientCanceled проверяет, что отмена запроса клиентом не выдаётся за недоступность БД:
+// ни 503 с Retry-After, ни 500
+func TestOrderHandler_ClientCanceled(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	repo := repomocks.NewOrderRepository(t)
+	cache.EXPECT().GetCache("id1").Return(models.Order{}, false)
+	repo.EXPECT().GetOrderById(mock.Anything, "id1").Return(models.Order{}, fmt.Errorf("запрос: %w", context.Canceled))
+
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo)
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/order/id1", nil))
+
+	assert.Equal(t, statusClientClosed, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+	assert.Empty(t, w.Body.String())
+}
+
 // TestOrderHandler_MethodNotAllowed
 // Проверяет, что POST-запрос к /order/{id} запрещён
-// Ожидаем 405
+// Ожидаем 405 и разрешённые методы маршрута в Allow
 // Кэш и репозиторий не должны вызываться
 func TestOrderHandler_MethodNotAllowed(t *testing.T) {
 	cache := cachemocks.NewCacheInterface(t)
@@ -99,6 +176,108 @@ func TestOrderHandler_MethodNotAllowed(t *testing.T) {
 	srv.Handler.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
 	cache.AssertExpectations(t)
 	repo.AssertExpectations(t)
 }
+
+// TestSchemaHandler
+// Проверяет отдачу JSON Schema заказа продюсерам
+// Ожидаем ответ 200, тип application/schema+json и схему с $id /schema/order.json
+func TestSchemaHandler(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	repo := repomocks.NewOrderRepository(t)
+
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo)
+	req := httptest.NewRequest(http.MethodGet, "/schema/order.json", nil)
+	w := httptest.NewRecorder()
+
+	srv.Handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"$id": "/schema/order.json"`)
+}
+
+// TestMetrics проверяет /metrics, подключённый опцией: метрики отдаются в текстовом формате Prometheus
+func TestMetrics(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	repo := repomocks.NewOrderRepository(t)
+
+	registry := metrics.NewRegistry()
+	registry.GaugeFunc("test_up", "Тестовая метрика", func() float64 { return 1 })
+
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo, WithMetrics(registry))
+
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "# TYPE test_up gauge\ntest_up 1\n")
+}
+
+// TestInstrument
+// Проверяет метрики HTTP: запрос считается по шаблону маршрута (/order/{id}), а не по пути, вместе с кодом ответа
+func TestInstrument(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	repo := repomocks.NewOrderRepository(t)
+	cache.EXPECT().GetCache("id1").Return(models.Order{OrderUID: "id1"}, true)
+
+	// Метрики общие для пакета, поэтому сравниваем прирост
+	ok := httpRequests.Value("/order/{id}", http.MethodGet, "200")
+	rejected := httpRequests.Value("unmatched", http.MethodPost, "405")
+	observed := httpDuration.Count("/order/{id}")
+
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo)
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/order/id1", nil))
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/order/id1", nil))
+
+	assert.Equal(t, ok+1, httpRequests.Value("/order/{id}", http.MethodGet, "200"))
+	assert.Equal(t, rejected+1, httpRequests.Value("unmatched", http.MethodPost, "405"))
+	assert.Equal(t, observed+1, httpDuration.Count("/order/{id}"))
+}
+
+// TestRequestID
+// Проверяет, что X-Request-ID из запроса возвращается в ответе, а без него создаётся новый
+func TestRequestID(t *testing.T) {
+	cache := cachemocks.NewCacheInterface(t)
+	repo := repomocks.NewOrderRepository(t)
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/schema/order.json", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	assert.Equal(t, "req-42", w.Header().Get(RequestIDHeader))
+
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schema/order.json", nil))
+	assert.Len(t, w.Header().Get(RequestIDHeader), 32)
+}
+
+// TestTrace
+// Проверяет серверный спан: входящий traceparent продолжается, имя и http.route - шаблон маршрута
+func TestTrace(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	cache := cachemocks.NewCacheInterface(t)
+	repo := repomocks.NewOrderRepository(t)
+	cache.EXPECT().GetCache("id1").Return(models.Order{OrderUID: "id1"}, true)
+	srv := NewServer(config.HttpServer{Port: 8080}, cache, repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/order/id1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /order/{id}", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Contains(t, span.Attributes(), attribute.String("http.route", "/order/{id}"))
+	assert.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+}
@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+	"github.com/fathersson/wb-demo-service/internal/redact"
+)
+
+// Экспортёры трейсов
+const (
+	ExporterNone = "none" // спаны не записываются, но traceparent всё равно передаётся дальше
+	ExporterOTLP = "otlp" // OTLP/HTTP в коллектор (Jaeger, Tempo, otel-collector)
+	ExporterFile = "file" // JSON по спану в строке, для локальной отладки и тестов
+)
+
+// Setup настраивает глобальный TracerProvider и W3C-пропагатор traceparent
+// Возвращает функцию, которая дописывает оставшиеся спаны и закрывает экспортёр
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	closeFile := func() error { return nil }
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exp, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось создать OTLP-экспортёр: %w", err)
+		}
+		exporter = exp
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть файл трейсов: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("не удалось создать файловый экспортёр: %w", err)
+		}
+		exporter, closeFile = exp, f.Close
+	default:
+		return nil, fmt.Errorf("неизвестный экспортёр трейсов %q", cfg.Exporter)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Решение входящего traceparent уважаем, доля применяется только к новым трейсам
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if cerr := closeFile(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+// Tracer - именованный трейсер из глобального провайдера
+// Провайдер можно подменить позже: трейсер глобального провайдера делегирует новому
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// End завершает спан, помечая его ошибкой, если она есть
+// Текст ошибки проходит через redact: в нём бывают персональные данные из заказа
+func End(span trace.Span, err error) {
+	if err != nil {
+		msg := redact.Text(err.Error())
+		span.RecordError(fmt.Errorf("%s", msg))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
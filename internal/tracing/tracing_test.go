@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	"github.com/fathersson/wb-demo-service/internal/config"
+)
+
+// TestSetup_File проверяет файловый экспортёр:
+// 1) Спан пишется в файл при shutdown вместе с service.name
+// 2) Ошибка в статусе спана замаскирована
+func TestSetup_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Exporter:    ExporterFile,
+		File:        path,
+		SampleRatio: 1,
+		ServiceName: "test-service",
+	})
+	require.NoError(t, err)
+
+	_, span := Tracer("test").Start(context.Background(), "test-span")
+	End(span, errors.New("не дозвонились до +79991234567"))
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	out := string(data)
+	assert.Contains(t, out, `"Name":"test-span"`)
+	assert.Contains(t, out, "test-service")
+	assert.NotContains(t, out, "79991234567")
+}
+
+// TestSetup_None проверяет, что без экспортёра traceparent всё равно передаётся дальше
+func TestSetup_None(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: ExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+}
+
+func TestSetup_UnknownExporter(t *testing.T) {
+	_, err := Setup(context.Background(), config.TracingConfig{Exporter: "jaeger"})
+	assert.Error(t, err)
+}